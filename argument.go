@@ -0,0 +1,186 @@
+package lua
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// ArgumentError is an error that indicates that an argument passed to a GoFunc
+// is not valid. When returned from a registered GoFunc, it is raised in Lua as
+//
+//	bad argument #<Arg> to '<function name>' (<Message>)
+type ArgumentError struct {
+	// Arg is the 1-based index of the bad argument.
+	Arg int
+	// Message describes what is wrong with the argument, e.g.
+	// "number expected, got string".
+	Message string
+}
+
+// NewArgumentError creates a new ArgumentError for the argument with the
+// given 1-based index.
+func NewArgumentError(arg int, format string, args ...interface{}) ArgumentError {
+	return ArgumentError{
+		Arg:     arg,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e ArgumentError) Error() string {
+	return fmt.Sprintf("bad argument #%d (%s)", e.Arg, e.Message)
+}
+
+func typeError(arg int, expected string, got Value) ArgumentError {
+	return NewArgumentError(arg, "%s expected, got %s", expected, typeName(got))
+}
+
+// CheckAny returns the argument with the given 1-based index, or an
+// ArgumentError if there is no such argument. An explicitly passed nil
+// is a valid argument.
+func (v Values) CheckAny(arg int) (Value, error) {
+	if arg < 1 || arg > len(v) {
+		return nil, NewArgumentError(arg, "value expected")
+	}
+	return v[arg-1], nil
+}
+
+// CheckString returns the argument with the given 1-based index as String.
+// Like in Lua, a number is converted to a string. For any other argument,
+// an ArgumentError is returned.
+func (v Values) CheckString(arg int) (String, error) {
+	switch val := v.get(arg).(type) {
+	case String:
+		return val, nil
+	case Number:
+		return String(value.NewNumber(float64(val)).String()), nil
+	case Integer:
+		return String(strconv.FormatInt(int64(val), 10)), nil
+	}
+	return "", typeError(arg, "string", v.get(arg))
+}
+
+// CheckNumber returns the argument with the given 1-based index as Number.
+// Like in Lua, a string that can be converted to a number is accepted. For
+// any other argument, an ArgumentError is returned.
+func (v Values) CheckNumber(arg int) (Number, error) {
+	if f, ok := toNumber(v.get(arg)); ok {
+		return Number(f), nil
+	}
+	return 0, typeError(arg, "number", v.get(arg))
+}
+
+// CheckInteger returns the argument with the given 1-based index as Integer.
+// Like in Lua, a float or a string is accepted, if it can be converted to an
+// integer without loss. For any other argument, an ArgumentError is returned.
+func (v Values) CheckInteger(arg int) (Integer, error) {
+	if i, ok := toInteger(v.get(arg)); ok {
+		return Integer(i), nil
+	}
+	if _, ok := toNumber(v.get(arg)); ok {
+		return 0, NewArgumentError(arg, "number has no integer representation")
+	}
+	return 0, typeError(arg, "number", v.get(arg))
+}
+
+// CheckBoolean returns the argument with the given 1-based index as boolean,
+// or an ArgumentError if the argument is not a boolean.
+func (v Values) CheckBoolean(arg int) (bool, error) {
+	switch v.get(arg) {
+	case True:
+		return true, nil
+	case False:
+		return false, nil
+	}
+	return false, typeError(arg, "boolean", v.get(arg))
+}
+
+// CheckTable returns the argument with the given 1-based index as Table,
+// or an ArgumentError if the argument is not a table.
+func (v Values) CheckTable(arg int) (Table, error) {
+	if t, ok := v.get(arg).(Table); ok {
+		return t, nil
+	}
+	return Table{}, typeError(arg, "table", v.get(arg))
+}
+
+// CheckFunction returns the argument with the given 1-based index as Function,
+// or an ArgumentError if the argument is not a function.
+func (v Values) CheckFunction(arg int) (Function, error) {
+	if fn, ok := v.get(arg).(Function); ok {
+		return fn, nil
+	}
+	return Function{}, typeError(arg, "function", v.get(arg))
+}
+
+// CheckUserdata returns the argument with the given 1-based index as Userdata,
+// or an ArgumentError if the argument is not a userdata.
+func (v Values) CheckUserdata(arg int) (Userdata, error) {
+	if u, ok := v.get(arg).(Userdata); ok {
+		return u, nil
+	}
+	return Userdata{}, typeError(arg, "userdata", v.get(arg))
+}
+
+// OptString works like CheckString, but returns the given default value if the
+// argument is absent or nil.
+func (v Values) OptString(arg int, def String) (String, error) {
+	if v.get(arg) == Nil || v.get(arg) == nil {
+		return def, nil
+	}
+	return v.CheckString(arg)
+}
+
+// OptNumber works like CheckNumber, but returns the given default value if the
+// argument is absent or nil.
+func (v Values) OptNumber(arg int, def Number) (Number, error) {
+	if v.get(arg) == Nil || v.get(arg) == nil {
+		return def, nil
+	}
+	return v.CheckNumber(arg)
+}
+
+// OptInteger works like CheckInteger, but returns the given default value if
+// the argument is absent or nil.
+func (v Values) OptInteger(arg int, def Integer) (Integer, error) {
+	if v.get(arg) == Nil || v.get(arg) == nil {
+		return def, nil
+	}
+	return v.CheckInteger(arg)
+}
+
+// get returns the argument with the given 1-based index, or nil (not Nil)
+// if there is no such argument.
+func (v Values) get(arg int) Value {
+	if arg < 1 || arg > len(v) {
+		return nil
+	}
+	return v[arg-1]
+}
+
+// typeName returns the Lua type name of the given value, or "no value" if
+// the value is nil.
+func typeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "no value"
+	case nilType:
+		return "nil"
+	case boolType:
+		return "boolean"
+	case Number, Integer:
+		return "number"
+	case String:
+		return "string"
+	case Function:
+		return "function"
+	case Userdata:
+		return "userdata"
+	case Thread:
+		return "thread"
+	case Table:
+		return "table"
+	}
+	return "<invalid>"
+}
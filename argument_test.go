@@ -0,0 +1,112 @@
+package lua
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValues_Check(t *testing.T) {
+	assert := assert.New(t)
+
+	args := Values{String("a"), Integer(2), String("3.5"), True, Nil}
+
+	s, err := args.CheckString(1)
+	assert.NoError(err)
+	assert.Equal(String("a"), s)
+
+	s, err = args.CheckString(2)
+	assert.NoError(err)
+	assert.Equal(String("2"), s)
+
+	n, err := args.CheckNumber(3)
+	assert.NoError(err)
+	assert.Equal(Number(3.5), n)
+
+	_, err = args.CheckNumber(1)
+	assert.Equal(NewArgumentError(1, "number expected, got string"), err)
+
+	b, err := args.CheckBoolean(4)
+	assert.NoError(err)
+	assert.True(b)
+
+	_, err = args.CheckString(5)
+	assert.Equal(NewArgumentError(5, "string expected, got nil"), err)
+
+	_, err = args.CheckString(6)
+	assert.Equal(NewArgumentError(6, "string expected, got no value"), err)
+
+	v, err := args.CheckAny(5)
+	assert.NoError(err)
+	assert.Equal(Nil, v)
+
+	_, err = args.CheckAny(6)
+	assert.Equal(NewArgumentError(6, "value expected"), err)
+
+	s, err = args.OptString(6, "default")
+	assert.NoError(err)
+	assert.Equal(String("default"), s)
+
+	n, err = args.OptNumber(5, 7)
+	assert.NoError(err)
+	assert.Equal(Number(7), n)
+
+	_, err = args.OptNumber(4, 7)
+	assert.Equal(NewArgumentError(4, "number expected, got boolean"), err)
+}
+
+func TestValues_CheckInteger(t *testing.T) {
+	assert := assert.New(t)
+
+	args := Values{Integer(2), Number(3), String("0x10"), Number(1.5), String("a")}
+
+	i, err := args.CheckInteger(1)
+	assert.NoError(err)
+	assert.Equal(Integer(2), i)
+
+	i, err = args.CheckInteger(2)
+	assert.NoError(err)
+	assert.Equal(Integer(3), i)
+
+	i, err = args.CheckInteger(3)
+	assert.NoError(err)
+	assert.Equal(Integer(16), i)
+
+	_, err = args.CheckInteger(4)
+	assert.Equal(NewArgumentError(4, "number has no integer representation"), err)
+
+	_, err = args.CheckInteger(5)
+	assert.Equal(NewArgumentError(5, "number expected, got string"), err)
+
+	i, err = args.OptInteger(6, 7)
+	assert.NoError(err)
+	assert.Equal(Integer(7), i)
+}
+
+func TestEngine_Register(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+	e.Register("fail", func(args Values) (Values, error) {
+		return nil, fmt.Errorf("something went wrong")
+	})
+	e.Register("check", func(args Values) (Values, error) {
+		_, err := args.CheckNumber(2)
+		return nil, err
+	})
+	e.Register("echo", func(args Values) (Values, error) {
+		return args, nil
+	})
+
+	_, err := e.EvalString(`fail()`)
+	assert.Equal("something went wrong", err.Error())
+	assert.IsType(Error{}, err)
+
+	_, err = e.EvalString(`check(1, "x")`)
+	assert.Equal("bad argument #2 to 'check' (number expected, got string)", err.Error())
+
+	results, err := e.EvalString(`return echo(1, "a", true, nil)`)
+	assert.NoError(err)
+	assert.Equal(Values{Integer(1), String("a"), True, Nil}, results)
+}
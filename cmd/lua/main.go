@@ -35,7 +35,13 @@ var (
 				lua.WithWorkingDirectory(wd),
 			)
 
-			_, err = e.EvalFile(script)
+			// the remaining arguments are passed to the script
+			var scriptArgs lua.Values
+			for _, arg := range args[1:] {
+				scriptArgs = append(scriptArgs, lua.String(arg))
+			}
+
+			_, err = e.EvalFile(script, scriptArgs...)
 			if err != nil {
 				return err
 			}
@@ -45,6 +51,11 @@ var (
 	}
 )
 
+func init() {
+	// flags after the script are arguments of the script
+	rootCmd.Flags().SetInterspersed(false)
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "%s", err)
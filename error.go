@@ -1,22 +1,65 @@
 package lua
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/tsatke/lua/internal/engine"
 	"github.com/tsatke/lua/internal/engine/value"
 )
 
 type Error struct {
 	Message string
-	Stack   []StackFrame
+	// Value is the value that was passed to Lua's error function. If it is
+	// neither a string nor a number, Message only describes the value.
+	Value Value
+	Stack []StackFrame
 }
 
 type StackFrame struct {
 	Name string
 }
 
+// ExitError is returned by the evaluation, if os.exit was called, and the
+// function set with WithExit returned.
+type ExitError struct {
+	Code int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// errorFromEngine converts errors of type engine.Error into an Error, and
+// errors of type engine.ExitError into an ExitError. All other errors are
+// returned unchanged.
+func errorFromEngine(err error) error {
+	var exitErr engine.ExitError
+	if errors.As(err, &exitErr) {
+		return ExitError{
+			Code: exitErr.Code,
+		}
+	}
+	var luaErr engine.Error
+	if errors.As(err, &luaErr) {
+		return errorFromInternal(luaErr)
+	}
+	return err
+}
+
 func errorFromInternal(err engine.Error) Error {
 	e := Error{}
-	e.Message = err.Message.(value.String).String()
+	e.Value = valueFromInternal(err.Message)
+	switch msg := err.Message.(type) {
+	case value.String:
+		e.Message = msg.String()
+	case value.Number:
+		e.Message = msg.String()
+	case value.Integer:
+		e.Message = msg.String()
+	default:
+		e.Message = fmt.Sprintf("(error object is a %s value)", typeName(e.Value))
+	}
 	e.Stack = make([]StackFrame, len(err.Stack))
 	for i, frame := range err.Stack {
 		e.Stack[i] = StackFrame{
@@ -31,3 +31,92 @@ func ExampleEngine_Eval() {
 	}
 	// Output: Hello, World!
 }
+
+func ExampleEngine_Register() {
+	e := NewEngine(
+		WithStdout(os.Stdout),
+	)
+	e.Register("greet", func(args Values) (Values, error) {
+		name, err := args.CheckString(1)
+		if err != nil {
+			return nil, err
+		}
+		return Values{String("Hello, " + name + "!")}, nil
+	})
+	_, err := e.EvalString(`print(greet("World"))
+print(pcall(greet))`)
+	if err != nil {
+		panic(err)
+	}
+	// Output:
+	// Hello, World!
+	// false	bad argument #1 to 'greet' (string expected, got no value)
+}
+
+func ExampleEngine_RegisterModule() {
+	e := NewEngine(
+		WithStdout(os.Stdout),
+	)
+	e.RegisterModule("calc", map[string]GoFunc{
+		"add": func(args Values) (Values, error) {
+			a, err := args.CheckInteger(1)
+			if err != nil {
+				return nil, err
+			}
+			b, err := args.CheckInteger(2)
+			if err != nil {
+				return nil, err
+			}
+			return Values{a + b}, nil
+		},
+	})
+	_, err := e.EvalString(`print(calc.add(1, "2"))`)
+	if err != nil {
+		panic(err)
+	}
+	// Output: 3
+}
+
+func ExampleEngine_RegisterNativeModule() {
+	e := NewEngine(
+		WithStdout(os.Stdout),
+	)
+	e.RegisterNativeModule("calc", map[string]GoFunc{
+		"double": func(args Values) (Values, error) {
+			n, err := args.CheckInteger(1)
+			if err != nil {
+				return nil, err
+			}
+			return Values{n * 2}, nil
+		},
+	})
+	_, err := e.EvalString(`local calc = require("calc")
+print(calc.double(21), calc == require("calc"), _G.calc)`)
+	if err != nil {
+		panic(err)
+	}
+	// Output: 42	true	nil
+}
+
+func ExampleToValue() {
+	type Request struct {
+		Method string `lua:"method"`
+		Path   string `lua:"path"`
+	}
+
+	e := NewEngine(
+		WithStdout(os.Stdout),
+	)
+	req, err := ToValue(&Request{Method: "GET", Path: "/"})
+	if err != nil {
+		panic(err)
+	}
+	e.Register("request", func(args Values) (Values, error) {
+		return Values{req}, nil
+	})
+	_, err = e.EvalString(`print(request().method, request().path)`)
+	if err != nil {
+		panic(err)
+	}
+	// Output: GET	/
+}
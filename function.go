@@ -0,0 +1,119 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tsatke/lua/internal/engine"
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// GoFunc is a function implemented in Go, that can be called from Lua.
+// The arguments of the call are passed as args, and the returned values
+// are passed back to Lua. If an error is returned, it is raised as Lua
+// error, which means that it can be caught with pcall.
+type GoFunc func(args Values) (Values, error)
+
+// Register makes the given function available as global variable with the
+// given name.
+//
+//	e.Register("greet", func(args lua.Values) (lua.Values, error) {
+//		name, err := args.CheckString(1)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return lua.Values{lua.String("Hello, " + name + "!")}, nil
+//	})
+func (e Engine) Register(name string, fn GoFunc) {
+	e.engine.Register(newFunction(name, fn))
+}
+
+// RegisterModule creates a new table with the given functions as fields, and
+// makes it available as global variable with the given name. The functions
+// can then be called from Lua like any other library function.
+//
+//	e.RegisterModule("http", map[string]lua.GoFunc{
+//		"get":  httpGet,
+//		"post": httpPost,
+//	})
+func (e Engine) RegisterModule(name string, fns map[string]GoFunc) {
+	var wrapped []*value.Function
+	for fnName, fn := range fns {
+		wrapped = append(wrapped, newFunction(fnName, fn))
+	}
+	e.engine.RegisterModule(name, wrapped...)
+}
+
+// RegisterNativeModule makes a module with the given functions as fields
+// available to require. Other than with RegisterModule, the module is not a
+// global variable, and is only created when a script requires it. Native
+// modules are found before modules in the file system.
+//
+//	e.RegisterNativeModule("http", map[string]lua.GoFunc{
+//		"get": httpGet,
+//	})
+//	e.EvalString(`local http = require("http")`)
+func (e Engine) RegisterNativeModule(name string, fns map[string]GoFunc) {
+	var wrapped []*value.Function
+	for fnName, fn := range fns {
+		wrapped = append(wrapped, newFunction(fnName, fn))
+	}
+	e.engine.RegisterNativeModule(name, wrapped...)
+}
+
+// NewFunction creates a new Lua function from the given GoFunc. The name is
+// used in error messages, e.g. if the function returns an ArgumentError.
+// Other than with Register, the function is not made available as global
+// variable, but can be stored in a table or passed to a Lua function.
+func (e Engine) NewFunction(name string, fn GoFunc) Function {
+	return Function{newFunction(name, fn)}
+}
+
+// Call calls the given function with the given arguments, and returns the
+// values that the function returned. If the function raises a Lua error,
+// the returned error will be of type Error.
+func (e Engine) Call(fn Function, args ...Value) (Values, error) {
+	if fn.fn == nil {
+		return nil, fmt.Errorf("uninitialized function")
+	}
+	internalArgs, err := valuesToInternal(args...)
+	if err != nil {
+		return nil, err
+	}
+	results, err := e.engine.Call(fn.fn, internalArgs...)
+	if err != nil {
+		return nil, errorFromEngine(err)
+	}
+	return valuesFromInternal(results...), nil
+}
+
+func newFunction(name string, fn GoFunc) *value.Function {
+	return value.NewFunction(name, func(args ...value.Value) ([]value.Value, error) {
+		results, err := fn(valuesFromInternal(args...))
+		if err != nil {
+			return nil, engine.Error{Message: errorValue(name, err)}
+		}
+		internal, err := valuesToInternal(results...)
+		if err != nil {
+			return nil, engine.Error{Message: value.NewString(fmt.Sprintf("%s: %v", name, err))}
+		}
+		return internal, nil
+	})
+}
+
+// errorValue returns the value that the given error should be raised with in
+// Lua. Argument errors are completed with the name of the function that they
+// occurred in, and errors of type Error keep the value they were raised with.
+func errorValue(fnName string, err error) value.Value {
+	var argErr ArgumentError
+	if errors.As(err, &argErr) {
+		return value.NewString(fmt.Sprintf("bad argument #%d to '%s' (%s)", argErr.Arg, fnName, argErr.Message))
+	}
+	var luaErr Error
+	if errors.As(err, &luaErr) && luaErr.Value != nil {
+		if val, convErr := valueToInternal(luaErr.Value); convErr == nil {
+			return val
+		}
+	}
+	return value.NewString(err.Error())
+}
@@ -1,6 +1,10 @@
 package ast
 
-import "github.com/tsatke/lua/internal/token"
+import (
+	"strings"
+
+	"github.com/tsatke/lua/internal/token"
+)
 
 type (
 	// Statement is a Lua statement.
@@ -103,6 +107,18 @@ type (
 		ExpList  []Exp
 	}
 
+	// Goto is a Lua goto statement, which jumps to the label with the
+	// given name.
+	Goto struct {
+		Label token.Token
+	}
+
+	// Label is a Lua label, e.g. ::continue::, which is the target of a
+	// goto statement.
+	Label struct {
+		Name token.Token
+	}
+
 	// LastStatement is a Lua last statement in a Block.
 	LastStatement struct {
 		ExpList []Exp
@@ -110,6 +126,22 @@ type (
 	}
 )
 
+// String returns the name as it appears in the source, e.g. a.b.c:d.
+func (n FuncName) String() string {
+	var buf strings.Builder
+	for i, name := range n.Name1 {
+		if i != 0 {
+			buf.WriteByte('.')
+		}
+		buf.WriteString(name.Value())
+	}
+	if n.Name2 != nil {
+		buf.WriteByte(':')
+		buf.WriteString(n.Name2.Value())
+	}
+	return buf.String()
+}
+
 func (Assignment) _stmt()    {}
 func (FunctionCall) _stmt()  {}
 func (DoBlock) _stmt()       {}
@@ -121,4 +153,6 @@ func (ForInBlock) _stmt()    {}
 func (Function) _stmt()      {}
 func (LocalFunction) _stmt() {}
 func (Local) _stmt()         {}
+func (Goto) _stmt()          {}
+func (Label) _stmt()         {}
 func (LastStatement) _stmt() {}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// argError creates an error for a bad argument, in the format that Lua uses.
+// n is the 1-based index of the argument, fnName the name of the function that
+// the argument was passed to.
+func argError(n int, fnName, msg string) error {
+	return Error{
+		Message: value.NewString(fmt.Sprintf("bad argument #%d to '%s' (%s)", n, fnName, msg)),
+	}
+}
+
+func typeArgError(n int, fnName, expected string, args []value.Value) error {
+	got := "no value"
+	if n <= len(args) {
+		got = args[n-1].Type().Name()
+	}
+	return argError(n, fnName, fmt.Sprintf("%s expected, got %s", expected, got))
+}
+
+// isNoneOrNil reports whether the n-th (1-based) argument is absent or nil.
+func isNoneOrNil(args []value.Value, n int) bool {
+	return n > len(args) || args[n-1] == nil || args[n-1] == value.Nil
+}
+
+// checkString returns the n-th (1-based) argument as string. Like in Lua,
+// numbers are converted to strings.
+func checkString(fnName string, args []value.Value, n int) (string, error) {
+	if n <= len(args) {
+		switch arg := args[n-1].(type) {
+		case value.String:
+			return string(arg), nil
+		case value.Number:
+			return arg.String(), nil
+		case value.Integer:
+			return arg.String(), nil
+		}
+	}
+	return "", typeArgError(n, fnName, "string", args)
+}
+
+// optString works like checkString, but returns def if the argument is
+// absent or nil.
+func optString(fnName string, args []value.Value, n int, def string) (string, error) {
+	if isNoneOrNil(args, n) {
+		return def, nil
+	}
+	return checkString(fnName, args, n)
+}
+
+// checkNumber returns the n-th (1-based) argument as number. Like in Lua,
+// strings that can be converted to a number are accepted.
+func checkNumber(fnName string, args []value.Value, n int) (float64, error) {
+	if n <= len(args) {
+		if num, ok := toNumber(args[n-1]); ok {
+			return num, nil
+		}
+	}
+	return 0, typeArgError(n, fnName, "number", args)
+}
+
+// optNumber works like checkNumber, but returns def if the argument is
+// absent or nil.
+func optNumber(fnName string, args []value.Value, n int, def float64) (float64, error) {
+	if isNoneOrNil(args, n) {
+		return def, nil
+	}
+	return checkNumber(fnName, args, n)
+}
+
+// checkNumberValue works like checkNumber, but keeps the subtype of the
+// number, so that the result is either a value.Integer or a value.Number.
+func checkNumberValue(fnName string, args []value.Value, n int) (value.Value, error) {
+	if n <= len(args) {
+		if num, ok := toNumberValue(args[n-1]); ok {
+			return num, nil
+		}
+	}
+	return nil, typeArgError(n, fnName, "number", args)
+}
+
+// checkInteger returns the n-th (1-based) argument as integer. The argument
+// must be a number (or a string convertible to one) with an integer
+// representation.
+func checkInteger(fnName string, args []value.Value, n int) (int64, error) {
+	if n <= len(args) {
+		if i, ok := toInteger(args[n-1]); ok {
+			return i, nil
+		}
+		if _, ok := toNumberValue(args[n-1]); ok {
+			return 0, argError(n, fnName, "number has no integer representation")
+		}
+	}
+	return 0, typeArgError(n, fnName, "number", args)
+}
+
+// optInteger works like checkInteger, but returns def if the argument is
+// absent or nil.
+func optInteger(fnName string, args []value.Value, n int, def int64) (int64, error) {
+	if isNoneOrNil(args, n) {
+		return def, nil
+	}
+	return checkInteger(fnName, args, n)
+}
+
+// toNumberValue converts the given value to a number, if it is a number or a
+// string that can be converted to a number. The result is either a
+// value.Integer or a value.Number.
+func toNumberValue(val value.Value) (value.Value, bool) {
+	switch v := val.(type) {
+	case value.Number, value.Integer:
+		return v, true
+	case value.String:
+		return value.ParseNumber(string(v))
+	}
+	return nil, false
+}
+
+// toNumber converts the given value to a float, if it is a number or a
+// string that can be converted to a number.
+func toNumber(val value.Value) (float64, bool) {
+	num, ok := toNumberValue(val)
+	if !ok {
+		return 0, false
+	}
+	return value.ToFloat(num)
+}
+
+// toInteger converts the given value to an integer, if it is a number or a
+// string that can be converted to a number, and has an exact integer
+// representation.
+func toInteger(val value.Value) (int64, bool) {
+	num, ok := toNumberValue(val)
+	if !ok {
+		return 0, false
+	}
+	switch n := num.(type) {
+	case value.Integer:
+		return int64(n), true
+	case value.Number:
+		return value.FloatToInteger(float64(n))
+	}
+	return 0, false
+}
@@ -4,50 +4,57 @@ import (
 	"fmt"
 	. "github.com/tsatke/lua/internal/engine/value"
 	"math"
-	"strconv"
 )
 
 func (e *Engine) add(left, right Value) ([]Value, error) {
-	return e.binaryFloatingPointOperation("__add", left, right, func(left, right float64) float64 {
+	return e.arithmetic("__add", left, right, func(left, right int64) (int64, error) {
+		return left + right, nil
+	}, func(left, right float64) float64 {
 		return left + right
 	})
 }
 
 func (e *Engine) subtract(left, right Value) ([]Value, error) {
-	return e.binaryFloatingPointOperation("__sub", left, right, func(left, right float64) float64 {
+	return e.arithmetic("__sub", left, right, func(left, right int64) (int64, error) {
+		return left - right, nil
+	}, func(left, right float64) float64 {
 		return left - right
 	})
 }
 
 func (e *Engine) multiply(left, right Value) ([]Value, error) {
-	return e.binaryFloatingPointOperation("__mul", left, right, func(left, right float64) float64 {
+	return e.arithmetic("__mul", left, right, func(left, right int64) (int64, error) {
+		return left * right, nil
+	}, func(left, right float64) float64 {
 		return left * right
 	})
 }
 
 func (e *Engine) divide(left, right Value) ([]Value, error) {
-	return e.binaryFloatingPointOperation("__div", left, right, func(left, right float64) float64 {
+	return e.arithmetic("__div", left, right, nil, func(left, right float64) float64 {
 		return left / right
 	})
 }
 
 func (e *Engine) floorDivide(left, right Value) ([]Value, error) {
-	return e.binaryFloatingPointOperation("__idiv", left, right, func(left, right float64) float64 {
+	return e.arithmetic("__idiv", left, right, func(left, right int64) (int64, error) {
+		if right == 0 {
+			_, err := e.error(NewString("attempt to perform 'n//0'"))
+			return 0, err
+		}
+		q := left / right
+		if left%right != 0 && (left < 0) != (right < 0) {
+			// round towards minus infinity instead of zero
+			q--
+		}
+		return q, nil
+	}, func(left, right float64) float64 {
 		return math.Floor(left / right)
 	})
 }
 
 func (e *Engine) power(left, right Value) ([]Value, error) {
-	return e.binaryFloatingPointOperation("__pow", left, right, func(left, right float64) float64 {
-		return math.Pow(left, right)
-	})
-}
-
-func (e *Engine) and(left, right Value) ([]Value, error) {
-	if !e.valueIsLogicallyTrue(left) {
-		return values(left), nil
-	}
-	return values(right), nil
+	return e.arithmetic("__pow", left, right, nil, math.Pow)
 }
 
 func (e *Engine) bitwiseOr(left, right Value) ([]Value, error) {
@@ -69,124 +76,151 @@ func (e *Engine) bitwiseXor(left, right Value) ([]Value, error) {
 }
 
 func (e *Engine) bitwiseLeftShift(left, right Value) ([]Value, error) {
-	return e.binaryIntegralOperation("__shl", left, right, func(left, right int64) int64 {
-		return left << right
-	})
+	return e.binaryIntegralOperation("__shl", left, right, shiftLeft)
 }
 
 func (e *Engine) bitwiseRightShift(left, right Value) ([]Value, error) {
 	return e.binaryIntegralOperation("__shr", left, right, func(left, right int64) int64 {
-		return left >> right
+		return shiftLeft(left, -right)
 	})
 }
 
-func (e *Engine) concatenation(left, right Value) ([]Value, error) {
-	if e.isNil(left) || e.isNil(right) {
-		return e.error(NewString("attempt to concatenate a nil value"))
+// shiftLeft shifts x by n bits to the left, or by -n bits to the right if n
+// is negative. Both shifts are logical, and shifting by 64 or more bits results
+// in 0.
+func shiftLeft(x, n int64) int64 {
+	switch {
+	case n <= -64 || n >= 64:
+		return 0
+	case n < 0:
+		return int64(uint64(x) >> uint64(-n))
 	}
+	return int64(uint64(x) << uint64(n))
+}
 
-	if (left.Type() != TypeString && left.Type() != TypeNumber) &&
-		(right.Type() != TypeString && right.Type() != TypeNumber) {
+func (e *Engine) concatenation(left, right Value) ([]Value, error) {
+	leftVal, leftOk := concatOperand(left)
+	rightVal, rightOk := concatOperand(right)
+	if !leftOk || !rightOk {
 		results, ok, err := e.binaryMetaMethodOperation("__concat", left, right)
 		if !ok {
 			if err != nil {
 				return nil, err
 			}
-			if left.Type() != TypeString && left.Type() != TypeNumber {
-				return e.error(NewString("attempt to concatenate a " + left.Type().String() + " value"))
-			}
-			if right.Type() != TypeString && right.Type() != TypeNumber {
-				return e.error(NewString("attempt to concatenate a " + right.Type().String() + " value"))
+			culprit := left
+			if leftOk {
+				culprit = right
 			}
+			return e.error(NewString("attempt to concatenate a " + culprit.Type().Name() + " value"))
 		}
 		return results, nil
 	}
 
-	var leftVal string
-	if left.Type() == TypeString {
-		leftVal = left.(String).String()
-	} else {
-		leftVal = strconv.FormatFloat(left.(Number).Value(), 'G', -1, 64)
-	}
-	var rightVal string
-	if right.Type() == TypeString {
-		rightVal = right.(String).String()
-	} else {
-		rightVal = strconv.FormatFloat(right.(Number).Value(), 'G', -1, 64)
-	}
-
 	return values(NewString(leftVal + rightVal)), nil
 }
 
-func (e *Engine) modulo(left, right Value) ([]Value, error) {
-	return e.binaryFloatingPointOperation("__mod", left, right, math.Mod)
-}
-
-func attemptConversionToNumber(left, right Value) (float64, float64, error) {
-	if left.Type() != TypeNumber && left.Type() != TypeString {
-		return 0, 0, fmt.Errorf("left is not a number")
-	}
-	if right.Type() != TypeNumber && right.Type() != TypeString {
-		return 0, 0, fmt.Errorf("right is not a number")
+// concatOperand returns the string that the given value contributes to a
+// concatenation. Only strings and numbers can be concatenated.
+func concatOperand(val Value) (string, bool) {
+	switch v := val.(type) {
+	case String:
+		return v.String(), true
+	case Number:
+		return v.String(), true
+	case Integer:
+		return v.String(), true
 	}
+	return "", false
+}
 
-	var leftVal float64
-	if left.Type() == TypeNumber {
-		leftVal = left.(Number).Value()
-	} else {
-		res, err := strconv.ParseFloat(left.(String).String(), 64)
-		if err != nil {
-			return 0, 0, fmt.Errorf("cannot convert '%s' to a number", left.(String).String())
+func (e *Engine) modulo(left, right Value) ([]Value, error) {
+	return e.arithmetic("__mod", left, right, func(left, right int64) (int64, error) {
+		if right == 0 {
+			_, err := e.error(NewString("attempt to perform 'n%0'"))
+			return 0, err
 		}
-		leftVal = res
-	}
-	var rightVal float64
-	if right.Type() == TypeNumber {
-		rightVal = right.(Number).Value()
-	} else {
-		res, err := strconv.ParseFloat(right.(String).String(), 64)
-		if err != nil {
-			return 0, 0, fmt.Errorf("cannot convert '%s' to a number", right.(String).String())
+		m := left % right
+		if m != 0 && (m < 0) != (right < 0) {
+			// the result has the sign of the divisor
+			m += right
 		}
-		rightVal = res
-	}
-	return leftVal, rightVal, nil
+		return m, nil
+	}, func(left, right float64) float64 {
+		m := math.Mod(left, right)
+		if m != 0 && (m < 0) != (right < 0) {
+			m += right
+		}
+		return m
+	})
 }
 
-func (e *Engine) binaryFloatingPointOperation(event string, left, right Value, operator func(left, right float64) float64) ([]Value, error) {
-	leftVal, rightVal, err := attemptConversionToNumber(left, right)
-	if err != nil {
-		results, ok, metaErr := e.binaryMetaMethodOperation(event, left, right)
+// arithmetic performs an arithmetic operation. Strings are converted to
+// numbers. If both operands are integers and intOp is not nil, the operation
+// is performed on integers, which wrap around on overflow. Otherwise, both
+// operands are converted to floats. If an operand can't be converted to a
+// number, the metamethod for the given event is called.
+func (e *Engine) arithmetic(event string, left, right Value, intOp func(left, right int64) (int64, error), floatOp func(left, right float64) float64) ([]Value, error) {
+	leftNum, leftOk := toNumberValue(left)
+	rightNum, rightOk := toNumberValue(right)
+	if !leftOk || !rightOk {
+		results, ok, err := e.binaryMetaMethodOperation(event, left, right)
 		if !ok {
-			if metaErr != nil {
-				return nil, metaErr
+			if err != nil {
+				return nil, err
 			}
-			return nil, err
+			culprit := left
+			if leftOk {
+				culprit = right
+			}
+			return e.error(NewString("attempt to perform arithmetic on a " + culprit.Type().Name() + " value"))
 		}
 		return results, nil
 	}
 
-	computationResult := operator(leftVal, rightVal)
-	resultValue := NewNumber(computationResult)
-	return values(resultValue), nil
+	if intOp != nil {
+		leftInt, leftIsInt := leftNum.(Integer)
+		rightInt, rightIsInt := rightNum.(Integer)
+		if leftIsInt && rightIsInt {
+			result, err := intOp(int64(leftInt), int64(rightInt))
+			if err != nil {
+				return nil, err
+			}
+			return values(NewInteger(result)), nil
+		}
+	}
+
+	leftFloat, _ := ToFloat(leftNum)
+	rightFloat, _ := ToFloat(rightNum)
+	return values(NewNumber(floatOp(leftFloat, rightFloat))), nil
 }
 
+// binaryIntegralOperation performs a bitwise operation. Both operands are
+// converted to integers. If an operand can't be converted to a number, the
+// metamethod for the given event is called.
 func (e *Engine) binaryIntegralOperation(event string, left, right Value, operator func(left, right int64) int64) ([]Value, error) {
-	leftVal, rightVal, err := attemptConversionToNumber(left, right)
-	if err != nil {
-		results, ok, metaErr := e.binaryMetaMethodOperation(event, left, right)
+	leftNum, leftOk := toNumberValue(left)
+	rightNum, rightOk := toNumberValue(right)
+	if !leftOk || !rightOk {
+		results, ok, err := e.binaryMetaMethodOperation(event, left, right)
 		if !ok {
-			if metaErr != nil {
-				return nil, metaErr
+			if err != nil {
+				return nil, err
 			}
-			return nil, err
+			culprit := left
+			if leftOk {
+				culprit = right
+			}
+			return e.error(NewString("attempt to perform bitwise operation on a " + culprit.Type().Name() + " value"))
 		}
 		return results, nil
 	}
 
-	computationResult := operator(int64(leftVal), int64(rightVal))
-	resultValue := NewNumber(float64(computationResult))
-	return values(resultValue), nil
+	leftInt, leftOk := toInteger(leftNum)
+	rightInt, rightOk := toInteger(rightNum)
+	if !leftOk || !rightOk {
+		return e.error(NewString("number has no integer representation"))
+	}
+	return values(NewInteger(operator(leftInt, rightInt))), nil
 }
 
 // binaryMetaMethodOperation attempts to perform the meta method operation for the given event
@@ -7,3 +7,12 @@ type mockClock struct{}
 func (m mockClock) Now() time.Time {
 	return time.Unix(1606850863, 419123456) // 2020-12-01 19:27:43.419123456 +0000 UTC
 }
+
+// utcClock is fixed at the same time as mockClock, but in UTC, so that
+// tests that format the local time don't depend on the time zone of the
+// machine they are running on.
+type utcClock struct{}
+
+func (utcClock) Now() time.Time {
+	return mockClock{}.Now().UTC()
+}
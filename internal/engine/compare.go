@@ -2,11 +2,15 @@ package engine
 
 import (
 	"fmt"
+	"math"
+
 	. "github.com/tsatke/lua/internal/engine/value"
 )
 
 func (e *Engine) cmpEqual(left, right Value) ([]Value, error) {
-	if left.Type() == TypeTable && right.Type() == TypeTable {
+	bothTables := left.Type() == TypeTable && right.Type() == TypeTable
+	bothUserdata := left.Type() == TypeUserdata && right.Type() == TypeUserdata
+	if bothTables || bothUserdata {
 		if left == right {
 			// primitive equal check
 			return values(True), nil
@@ -27,56 +31,59 @@ func (e *Engine) cmpEqual(left, right Value) ([]Value, error) {
 		}
 	}
 
+	return values(Boolean(rawEqual(left, right))), nil
+}
+
+// rawEqual reports whether the two values are primitively equal, without
+// invoking the __eq metamethod.
+func rawEqual(left, right Value) bool {
 	if left.Type() != right.Type() {
-		return values(False), nil
+		return false
 	}
-	switch left.Type() {
-	case TypeNil:
-		return values(Boolean(left == right)), nil
-	case TypeNumber:
-		leftNum := left.(Number).Value()
-		rightNum := right.(Number).Value()
-		return values(Boolean(leftNum == rightNum)), nil
-	case TypeString:
-		leftStr := left.(String).String()
-		rightStr := right.(String).String()
-		return values(Boolean(leftStr == rightStr)), nil
-	case TypeBoolean:
-		leftBool := left.(Boolean)
-		rightBool := right.(Boolean)
-		return values(Boolean(leftBool == rightBool)), nil
+	if left.Type() == TypeNumber {
+		return numEqual(left, right)
 	}
-	return nil, fmt.Errorf("cannot compare %s", left.Type())
+	// strings and booleans are equal by value, all other values are only
+	// equal if they are the same object
+	return left == right
 }
 
 func (e *Engine) less(left, right Value) (bool, error) {
-	if left.Type() != right.Type() {
-		return false, nil
-	}
-	switch left.Type() {
-	case TypeNumber:
-		leftVal, rightVal := left.(Number).Value(), right.(Number).Value()
-		return leftVal < rightVal, nil
-	case TypeString:
-		leftVal, rightVal := left.(String).String(), right.(String).String()
-		return leftVal < rightVal, nil
+	if left.Type() == right.Type() {
+		switch left.Type() {
+		case TypeNumber:
+			return numLess(left, right), nil
+		case TypeString:
+			leftVal, rightVal := left.(String).String(), right.(String).String()
+			return leftVal < rightVal, nil
+		}
 	}
-	return false, fmt.Errorf("%s is not comparable", left.Type())
+	return false, e.compareError(left, right)
 }
 
 func (e *Engine) lessEqual(left, right Value) (bool, error) {
-	if left.Type() != right.Type() {
-		return false, nil
+	if left.Type() == right.Type() {
+		switch left.Type() {
+		case TypeNumber:
+			return numLessEqual(left, right), nil
+		case TypeString:
+			leftVal, rightVal := left.(String).String(), right.(String).String()
+			return leftVal <= rightVal, nil
+		}
 	}
-	switch left.Type() {
-	case TypeNumber:
-		leftVal, rightVal := left.(Number).Value(), right.(Number).Value()
-		return leftVal <= rightVal, nil
-	case TypeString:
-		leftVal, rightVal := left.(String).String(), right.(String).String()
-		return leftVal <= rightVal, nil
+	return false, e.compareError(left, right)
+}
+
+// compareError creates the error for an attempt to compare two values that
+// can't be compared.
+func (e *Engine) compareError(left, right Value) error {
+	leftType, rightType := left.Type().Name(), right.Type().Name()
+	if leftType == rightType {
+		_, err := e.error(NewString(fmt.Sprintf("attempt to compare two %s values", leftType)))
+		return err
 	}
-	return false, fmt.Errorf("%s is not comparable", left.Type())
+	_, err := e.error(NewString(fmt.Sprintf("attempt to compare %s with %s", leftType, rightType)))
+	return err
 }
 
 func (e *Engine) equal(left, right Value) (bool, error) {
@@ -84,10 +91,134 @@ func (e *Engine) equal(left, right Value) (bool, error) {
 		return false, nil
 	}
 	switch left.Type() {
-	case TypeNumber,
-		TypeString,
+	case TypeNumber:
+		return numEqual(left, right), nil
+	case TypeString,
 		TypeBoolean:
 		return left == right, nil
 	}
 	return false, fmt.Errorf("%s can not be checked for equality", left.Type())
 }
+
+// The following functions compare two numbers, each of which may be an
+// integer or a float. An integer and a float are compared by their
+// mathematical values, without converting the integer to a float, which
+// could lose precision.
+
+// twoTo63 is 2^63, the smallest float that is greater than any integer.
+const twoTo63 = -float64(math.MinInt64)
+
+func numEqual(left, right Value) bool {
+	switch l := left.(type) {
+	case Integer:
+		switch r := right.(type) {
+		case Integer:
+			return l == r
+		case Number:
+			i, ok := FloatToInteger(float64(r))
+			return ok && Integer(i) == l
+		}
+	case Number:
+		switch r := right.(type) {
+		case Integer:
+			return numEqual(r, l)
+		case Number:
+			return l == r
+		}
+	}
+	return false
+}
+
+func numLess(left, right Value) bool {
+	switch l := left.(type) {
+	case Integer:
+		switch r := right.(type) {
+		case Integer:
+			return l < r
+		case Number:
+			return intLessFloat(int64(l), float64(r))
+		}
+	case Number:
+		switch r := right.(type) {
+		case Integer:
+			return floatLessInt(float64(l), int64(r))
+		case Number:
+			return l < r
+		}
+	}
+	return false
+}
+
+func numLessEqual(left, right Value) bool {
+	switch l := left.(type) {
+	case Integer:
+		switch r := right.(type) {
+		case Integer:
+			return l <= r
+		case Number:
+			return intLessEqualFloat(int64(l), float64(r))
+		}
+	case Number:
+		switch r := right.(type) {
+		case Integer:
+			return floatLessEqualInt(float64(l), int64(r))
+		case Number:
+			return l <= r
+		}
+	}
+	return false
+}
+
+// intLessFloat reports whether i < f. This holds exactly if i < ceil(f).
+func intLessFloat(i int64, f float64) bool {
+	switch {
+	case math.IsNaN(f):
+		return false
+	case f >= twoTo63:
+		return true
+	case f < math.MinInt64:
+		return false
+	}
+	return i < int64(math.Ceil(f))
+}
+
+// intLessEqualFloat reports whether i <= f. This holds exactly if
+// i <= floor(f).
+func intLessEqualFloat(i int64, f float64) bool {
+	switch {
+	case math.IsNaN(f):
+		return false
+	case f >= twoTo63:
+		return true
+	case f < math.MinInt64:
+		return false
+	}
+	return i <= int64(math.Floor(f))
+}
+
+// floatLessInt reports whether f < i. This holds exactly if floor(f) < i.
+func floatLessInt(f float64, i int64) bool {
+	switch {
+	case math.IsNaN(f):
+		return false
+	case f >= twoTo63:
+		return false
+	case f < math.MinInt64:
+		return true
+	}
+	return int64(math.Floor(f)) < i
+}
+
+// floatLessEqualInt reports whether f <= i. This holds exactly if
+// ceil(f) <= i.
+func floatLessEqualInt(f float64, i int64) bool {
+	switch {
+	case math.IsNaN(f):
+		return false
+	case f >= twoTo63:
+		return false
+	case f < math.MinInt64:
+		return true
+	}
+	return int64(math.Ceil(f)) <= i
+}
@@ -5,3 +5,9 @@ import "github.com/tsatke/lua/internal/engine/value"
 type Return struct {
 	Values []value.Value
 }
+
+// Goto is the panic of a goto statement. It is recovered by the block that
+// holds the label. The parser ensures, that such a block exists.
+type Goto struct {
+	Label string
+}
@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+type coroutineStatus uint8
+
+const (
+	statusSuspended coroutineStatus = iota
+	statusRunning
+	statusNormal
+	statusDead
+)
+
+func (s coroutineStatus) String() string {
+	switch s {
+	case statusSuspended:
+		return "suspended"
+	case statusRunning:
+		return "running"
+	case statusNormal:
+		return "normal"
+	case statusDead:
+		return "dead"
+	}
+	return "<invalid>"
+}
+
+// Coroutine is a Lua thread. Every coroutine is backed by a goroutine, but
+// only one of them is ever running at a time. Control is handed from the
+// resuming goroutine to the coroutine and back through channels, so that
+// the coroutine can yield from anywhere, even from inside a pcall.
+//
+// If a suspended coroutine becomes unreachable, its goroutine is unwound as
+// soon as the engine doesn't evaluate anything else. For this, the
+// goroutine only references the state of the coroutine, but never the
+// Coroutine itself, which has a finalizer. However, the goroutine does
+// reference the engine, so a suspended coroutine that is reachable from the
+// engine, e.g. through a global variable, keeps the engine alive.
+type Coroutine struct {
+	*coroutineState
+}
+
+type coroutineState struct {
+	fn      *value.Function
+	status  coroutineStatus
+	started bool
+	// abandoned is set when the coroutine is unwound, because it became
+	// unreachable while it was suspended.
+	abandoned bool
+
+	// resume is used to pass the arguments of coroutine.resume into
+	// the coroutine.
+	resume chan []value.Value
+	// transfer is used to pass the values of coroutine.yield, the return
+	// values or an error out of the coroutine.
+	transfer chan transfer
+
+	// scopes and stack hold the state of the coroutine while it is not
+	// running.
+	scopes []*value.Scope
+	stack  *callStack
+	// resumerScopes and resumerStack hold the state of the engine while
+	// an abandoned coroutine is unwound.
+	resumerScopes []*value.Scope
+	resumerStack  *callStack
+}
+
+// errCoroutineAbandoned is returned by coroutine.yield in a coroutine that
+// is unwound, because it became unreachable while it was suspended.
+var errCoroutineAbandoned = errors.New("coroutine abandoned")
+
+type transfer struct {
+	values []value.Value
+	err    error
+	done   bool
+}
+
+func newCoroutine(fn *value.Function, maxStackSize int) *Coroutine {
+	stack := newCallStack()
+	stack.maxSize = maxStackSize
+	co := &Coroutine{&coroutineState{
+		fn:       fn,
+		status:   statusSuspended,
+		resume:   make(chan []value.Value),
+		transfer: make(chan transfer),
+		stack:    stack,
+	}}
+	runtime.SetFinalizer(co, (*Coroutine).abandon)
+	return co
+}
+
+// abandon is the finalizer of a coroutine. Closing the resume channel lets
+// a suspended coroutine unwind, see yieldCoroutine. A coroutine that is
+// running or normal is always reachable, and a coroutine that wasn't
+// started or is dead has no goroutine that waits for it.
+func (co *Coroutine) abandon() {
+	close(co.resume)
+}
+
+func (*Coroutine) Type() value.Type { return value.TypeThread }
+
+func (c *Coroutine) String() string {
+	return fmt.Sprintf("thread: %p", c)
+}
+
+// resumeCoroutine resumes the given coroutine with the given arguments, and
+// blocks until the coroutine yields, returns or errors.
+func (e *Engine) resumeCoroutine(co *Coroutine, args ...value.Value) ([]value.Value, error) {
+	if co.status == statusDead {
+		return e.error(value.NewString("cannot resume dead coroutine"))
+	}
+	if co.status != statusSuspended {
+		return e.error(value.NewString("cannot resume non-suspended coroutine"))
+	}
+
+	resumer := e.coroutine
+	resumerScopes, resumerStack := e.scopes, e.stack
+
+	resumer.status = statusNormal
+	co.status = statusRunning
+	e.coroutine = co
+	e.scopes, e.stack = co.scopes, co.stack
+
+	if !co.started {
+		co.started = true
+		go e.runCoroutine(co.coroutineState, args)
+	} else {
+		co.resume <- args
+	}
+	result := <-co.transfer
+
+	co.scopes, co.stack = e.scopes, e.stack
+	e.scopes, e.stack = resumerScopes, resumerStack
+	e.coroutine = resumer
+	resumer.status = statusRunning
+
+	if result.done {
+		co.status = statusDead
+	} else {
+		co.status = statusSuspended
+	}
+	return result.values, result.err
+}
+
+func (e *Engine) runCoroutine(co *coroutineState, args []value.Value) {
+	result := e.callCoroutine(co, args)
+	if co.abandoned {
+		// nobody waits for the result of an abandoned coroutine
+		e.scopes, e.stack = co.resumerScopes, co.resumerStack
+		e.idle.Unlock()
+		return
+	}
+	result.done = true
+	co.transfer <- result
+}
+
+func (e *Engine) callCoroutine(co *coroutineState, args []value.Value) (result transfer) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = transfer{
+				err: fmt.Errorf("%v", r),
+			}
+		}
+	}()
+
+	results, err := e.call(co.fn, args...)
+	return transfer{
+		values: results,
+		err:    err,
+	}
+}
+
+// yieldCoroutine passes the given values to the resumer of the currently
+// running coroutine, and blocks until the coroutine is resumed again. The
+// arguments of that resume are returned.
+func (e *Engine) yieldCoroutine(args ...value.Value) ([]value.Value, error) {
+	if e.coroutine == e.mainCoroutine {
+		return e.error(value.NewString("attempt to yield from outside a coroutine"))
+	}
+	// co must not reference the Coroutine, otherwise it never becomes
+	// unreachable while this goroutine waits
+	co := e.coroutine.coroutineState
+
+	co.transfer <- transfer{
+		values: args,
+	}
+	results, ok := <-co.resume
+	if !ok {
+		// The coroutine became unreachable, so it is unwound by returning
+		// an error that Lua code can't catch. While it is unwound, it uses
+		// the engine like a running coroutine, so it has to wait until
+		// the engine is idle.
+		e.idle.Lock()
+		co.abandoned = true
+		co.resumerScopes, co.resumerStack = e.scopes, e.stack
+		e.scopes, e.stack = co.scopes, co.stack
+		return nil, errCoroutineAbandoned
+	}
+	return results, nil
+}
+
+func (e *Engine) coroutineCreate(args ...value.Value) ([]value.Value, error) {
+	if len(args) == 0 {
+		return nil, typeArgError(1, "create", "function", args)
+	}
+	fn, ok := args[0].(*value.Function)
+	if !ok {
+		return nil, typeArgError(1, "create", "function", args)
+	}
+	return values(newCoroutine(fn, e.stack.maxSize)), nil
+}
+
+func (e *Engine) coroutineResume(args ...value.Value) ([]value.Value, error) {
+	if len(args) == 0 {
+		return nil, typeArgError(1, "resume", "thread", args)
+	}
+	co, ok := args[0].(*Coroutine)
+	if !ok {
+		return nil, typeArgError(1, "resume", "thread", args)
+	}
+
+	results, err := e.resumeCoroutine(co, args[1:]...)
+	if err != nil {
+		// only errors that Lua code can catch are returned, all other
+		// errors, like an exit, abort the evaluation
+		var luaErr Error
+		if errors.As(err, &luaErr) {
+			return values(value.False, luaErr.Message), nil
+		}
+		return nil, err
+	}
+	return append(values(value.True), results...), nil
+}
+
+func (e *Engine) coroutineYield(args ...value.Value) ([]value.Value, error) {
+	return e.yieldCoroutine(args...)
+}
+
+func (e *Engine) coroutineStatus(args ...value.Value) ([]value.Value, error) {
+	if len(args) == 0 {
+		return nil, typeArgError(1, "status", "thread", args)
+	}
+	co, ok := args[0].(*Coroutine)
+	if !ok {
+		return nil, typeArgError(1, "status", "thread", args)
+	}
+	return values(value.NewString(co.status.String())), nil
+}
+
+func (e *Engine) coroutineWrap(args ...value.Value) ([]value.Value, error) {
+	if len(args) == 0 {
+		return nil, typeArgError(1, "wrap", "function", args)
+	}
+	fn, ok := args[0].(*value.Function)
+	if !ok {
+		return nil, typeArgError(1, "wrap", "function", args)
+	}
+
+	co := newCoroutine(fn, e.stack.maxSize)
+	return values(value.NewFunction("wrap", func(args ...value.Value) ([]value.Value, error) {
+		results, err := e.resumeCoroutine(co, args...)
+		if err != nil {
+			var luaErr Error
+			if errors.As(err, &luaErr) {
+				return nil, luaErr
+			}
+			return nil, err
+		}
+		return results, nil
+	})), nil
+}
+
+func (e *Engine) coroutineIsyieldable(args ...value.Value) ([]value.Value, error) {
+	return values(value.Boolean(e.coroutine != e.mainCoroutine)), nil
+}
+
+func (e *Engine) coroutineRunning(args ...value.Value) ([]value.Value, error) {
+	return values(e.coroutine, value.Boolean(e.coroutine == e.mainCoroutine)), nil
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/tsatke/lua/internal/ast"
@@ -13,6 +16,10 @@ import (
 	"github.com/tsatke/lua/internal/parser"
 )
 
+// envName is the name of the variable, that holds the environment in which
+// free names are resolved.
+const envName = "_ENV"
+
 type Namer interface {
 	Name() string
 }
@@ -44,9 +51,34 @@ type Engine struct {
 
 	// clock is the clock that the engine will use if it requires a timestamp.
 	clock Clock
-
-	_G     *value.Table
-	scopes []*value.Table
+	// started is the time at which the engine was created, according to
+	// its clock.
+	started time.Time
+	// env provides the environment variables for os.getenv.
+	env Env
+	// exit is called by os.exit with the exit code.
+	exit func(code int)
+	// random generates the pseudo-random numbers for math.random.
+	random *rand.Rand
+
+	_G *value.Table
+	// pkg is the package library, which holds the configuration of
+	// require, and loaded is package.loaded, which holds all modules
+	// that have been loaded.
+	pkg    *value.Table
+	loaded *value.Table
+	// nativeModules holds the modules registered with
+	// RegisterNativeModule, which require can load.
+	nativeModules map[string][]*value.Function
+	// fileMetatable is the metatable of all file handles, and input and
+	// output are the default files of io.read and io.write.
+	fileMetatable *value.Table
+	input         *value.Userdata
+	output        *value.Userdata
+	// scopes are the scopes of the function that is currently being
+	// evaluated, innermost scope first. Globals are not part of the
+	// scopes, but live in _G.
+	scopes []*value.Scope
 
 	metaTables metaTables
 
@@ -54,6 +86,18 @@ type Engine struct {
 	gcpercent int
 
 	stack *callStack
+
+	// coroutine is the coroutine that is currently running. If no
+	// coroutine was resumed, this is the main coroutine.
+	coroutine     *Coroutine
+	mainCoroutine *Coroutine
+	// idle is locked while the engine evaluates code, and depth is the
+	// number of nested calls to Eval and Call. Abandoned coroutines lock
+	// idle before they are unwound, see yieldCoroutine.
+	idle  sync.Mutex
+	depth int
+
+	finalizers *finalizerQueue
 }
 
 // New creates a new, ready to use Engine, already applying all given options.
@@ -68,21 +112,34 @@ func New(opts ...Option) *Engine {
 		stdout: os.Stdout,
 		stderr: os.Stderr,
 		clock:  sysClock{},
+		env:    sysEnv{},
+		exit:   os.Exit,
 
-		_G:     global,
-		scopes: []*value.Table{global},
+		_G: global,
 
 		stack: newCallStack(),
+
+		finalizers: &finalizerQueue{},
 	}
 	for _, opt := range opts {
 		opt(e)
 	}
+	e.started = e.clock.Now()
+	if e.random == nil {
+		e.random = rand.New(rand.NewSource(e.clock.Now().UnixNano()))
+	}
+	e.mainCoroutine = &Coroutine{&coroutineState{
+		status: statusRunning,
+	}}
+	e.coroutine = e.mainCoroutine
 	e.initStdlib()
 	e.initMetatables()
 	return e
 }
 
-func (e *Engine) EvalFile(path string) ([]value.Value, error) {
+// EvalFile evaluates the file with the given path as chunk. The given
+// arguments are passed to the chunk, which can access them with '...'.
+func (e *Engine) EvalFile(path string, args ...value.Value) ([]value.Value, error) {
 	file, err := e.fs.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open %s: %w", path, err)
@@ -90,15 +147,37 @@ func (e *Engine) EvalFile(path string) ([]value.Value, error) {
 	defer func() {
 		_ = file.Close()
 	}()
-	return e.Eval(file)
+	return e.Eval(file, args...)
+}
+
+// Eval evaluates the given source as chunk. The given arguments are passed
+// to the chunk, which can access them with '...'.
+func (e *Engine) Eval(source io.Reader, args ...value.Value) ([]value.Value, error) {
+	chunk, err := e.parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	e.enter()
+	defer e.leave()
+
+	e.runFinalizers()
+
+	results, err := e.evaluateChunk(chunk, args...)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
-func (e *Engine) Eval(source io.Reader) ([]value.Value, error) {
+// parse parses the given source as chunk. If the source can't be parsed,
+// the returned error holds all parse errors.
+func (e *Engine) parse(source io.Reader) (ast.Chunk, error) {
 	p, err := parser.New(source)
 	if err != nil {
-		return nil, fmt.Errorf("create parser: %w", err)
+		return ast.Chunk{}, fmt.Errorf("create parser: %w", err)
 	}
-	ast, ok := p.Parse()
+	chunk, ok := p.Parse()
 	if !ok {
 		var errString bytes.Buffer
 		errString.WriteString("errors occurred while parsing")
@@ -108,30 +187,71 @@ func (e *Engine) Eval(source io.Reader) ([]value.Value, error) {
 		for _, err := range p.Errors() {
 			errString.WriteString("\n\t" + err.Error())
 		}
-		return nil, fmt.Errorf(errString.String())
+		return ast.Chunk{}, fmt.Errorf(errString.String())
 	}
+	return chunk, nil
+}
 
-	results, err := e.evaluateChunk(ast)
-	if err != nil {
-		return nil, err
+// Register makes the given function available as global variable, with the
+// name of the function as variable name.
+func (e *Engine) Register(fn *value.Function) {
+	e.assign(e._G, fn.Name, fn)
+}
+
+// RegisterModule creates a new table with the given functions as fields, and
+// makes it available as global variable with the given name. The module is
+// also added to package.loaded, so that require can load it.
+func (e *Engine) RegisterModule(name string, fns ...*value.Function) {
+	module := value.NewTable()
+	for _, fn := range fns {
+		e.assign(module, fn.Name, fn)
 	}
-	return results, nil
+	e.assign(e._G, name, module)
+	e.assign(e.loaded, name, module)
 }
 
-func (e *Engine) currentScope() *value.Table {
+// Call calls the given function with the given arguments, and returns the
+// values that the function returned.
+func (e *Engine) Call(fn *value.Function, args ...value.Value) ([]value.Value, error) {
+	e.enter()
+	defer e.leave()
+
+	e.runFinalizers()
+
+	return e.call(fn, args...)
+}
+
+// enter marks the engine as busy until the matching call to leave. Calls
+// may be nested, e.g. if a Go function that was called by Lua code calls
+// a Lua function.
+func (e *Engine) enter() {
+	if e.depth == 0 {
+		e.idle.Lock()
+	}
+	e.depth++
+}
+
+func (e *Engine) leave() {
+	e.depth--
+	if e.depth == 0 {
+		e.idle.Unlock()
+	}
+}
+
+func (e *Engine) currentScope() *value.Scope {
 	return e.scopes[0]
 }
 
-func (e Engine) dumpState() {
+func (e *Engine) dumpState() {
 	fmt.Printf("clock: %T\n", e.clock)
 	fmt.Println("global scope:")
-	for name, value := range e._G.Fields {
-		fmt.Printf("%-15s = %s\n", name, value)
+	for name, val, _ := e._G.Next(value.Nil); name != value.Nil; name, val, _ = e._G.Next(name) {
+		fmt.Printf("%-15s = %s\n", name, val)
 	}
-	if e.currentScope() != e._G {
+	if len(e.scopes) > 0 {
 		fmt.Println("current scope:")
-		for name, value := range e.currentScope().Fields {
-			fmt.Printf("%-15s = %s\n", name, value)
+		for name, variable := range e.currentScope().Variables {
+			fmt.Printf("%-15s = %s\n", name, variable.Value)
 		}
 	}
 }
@@ -140,8 +260,13 @@ func (e *Engine) assign(scope *value.Table, name string, val value.Value) {
 	scope.Set(value.NewString(name), val)
 }
 
+// declare declares a local variable with the given name in the current scope.
+func (e *Engine) declare(name string, val value.Value) {
+	e.currentScope().Declare(name, val)
+}
+
 func (e *Engine) enterNewScope() {
-	e.scopes = append([]*value.Table{value.NewTable()}, e.scopes...)
+	e.scopes = append([]*value.Scope{value.NewScope()}, e.scopes...)
 }
 
 func (e *Engine) leaveScope() {
@@ -149,21 +274,65 @@ func (e *Engine) leaveScope() {
 	e.scopes = e.scopes[1:]
 }
 
+// captureScopes returns the scopes of a closure that is created in the
+// current scopes. The closure shares the variables that are currently
+// declared with the enclosing function, but doesn't see variables that are
+// declared after it was created.
+func (e *Engine) captureScopes() []*value.Scope {
+	scopes := make([]*value.Scope, len(e.scopes))
+	for i, scope := range e.scopes {
+		scopes[i] = scope.Capture()
+	}
+	return scopes
+}
+
+// scopeOf returns the innermost scope that holds a local variable with the given
+// Name, or nil if there is no such local variable.
+func (e *Engine) scopeOf(name string) *value.Scope {
+	for _, scope := range e.scopes {
+		if _, ok := scope.Get(name); ok {
+			return scope
+		}
+	}
+	return nil
+}
+
 // variable searches for a variable with the given Name, starting in the current
 // scope and always visiting the parent scope if there is no such variable.
-func (e *Engine) variable(name string) (value.Value, bool) {
-	varName := value.NewString(name)
-	for i := 0; i < len(e.scopes); i++ {
-		if val, ok := e.scopes[i].Fields[varName]; ok {
-			return val, true
-		}
+// If no scope holds such a variable, the name is a free name, which refers
+// to the field with that name in the environment _ENV.
+func (e *Engine) variable(name string) (value.Value, error) {
+	if scope := e.scopeOf(name); scope != nil {
+		val, _ := scope.Get(name)
+		return val, nil
 	}
-	return value.Nil, false
+
+	results, err := e.performIndexOperation(e.environment(), value.NewString(name))
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// environment returns the value of the variable _ENV, which holds the global
+// variables. Every chunk declares _ENV as a local in its outermost scope, but
+// it can be rebound like any other variable.
+func (e *Engine) environment() value.Value {
+	if scope := e.scopeOf(envName); scope != nil {
+		env, _ := scope.Get(envName)
+		return env
+	}
+	return e._G
 }
 
 func (e *Engine) call(fn *value.Function, args ...value.Value) (vs []value.Value, err error) {
-	e.enterNewScope()
-	defer e.leaveScope()
+	// a function is evaluated in the scopes it closes over, not in the
+	// scopes of the caller
+	callerScopes := e.scopes
+	e.scopes = append([]*value.Scope{value.NewScope()}, fn.Scopes...)
+	defer func() {
+		e.scopes = callerScopes
+	}()
 
 	if ok := e.stack.Push(StackFrame{
 		Name: fn.Name,
@@ -187,6 +356,12 @@ func (e *Engine) call(fn *value.Function, args ...value.Value) (vs []value.Value
 		if err != nil {
 			var luaErr Error
 			if errors.As(err, &luaErr) {
+				if luaErr.e == nil {
+					// the error was created outside of the engine, e.g. by a
+					// function implemented in Go, as Error{Message: ...}
+					luaErr.e = e
+					luaErr.Stack = e.stack.Slice()
+				}
 				return nil, luaErr
 			}
 			return nil, fmt.Errorf("error while calling '%s': %w", fn.Name, err)
@@ -200,12 +375,22 @@ func (e *Engine) createCallable(parameters ast.ParList, block ast.Block) (value.
 	return func(args ...value.Value) ([]value.Value, error) {
 		// this assumes, that we are already in a separate function scope
 
-		// assign all arguments to the parameters in the current scope
-		for i, arg := range args {
-			if i >= len(parameters.NameList) {
-				break
+		// declare all parameters in the current scope, parameters without
+		// a matching argument are nil
+		for i, param := range parameters.NameList {
+			if i < len(args) {
+				e.declare(param.Value(), args[i])
+			} else {
+				e.declare(param.Value(), value.Nil)
+			}
+		}
+		if parameters.Ellipsis {
+			// the arguments without a matching parameter are the varargs
+			varargs := []value.Value{}
+			if len(args) > len(parameters.NameList) {
+				varargs = args[len(parameters.NameList):]
 			}
-			e.assign(e.currentScope(), parameters.NameList[i].Value(), arg)
+			e.currentScope().Varargs = varargs
 		}
 
 		results, err := e.evaluateBlock(block)
@@ -222,17 +407,26 @@ func (e *Engine) isNil(val value.Value) bool {
 
 func (e *Engine) performIndexOperation(obj, key value.Value) ([]value.Value, error) {
 	event := "__index"
+
+	// the meta method of a table is only consulted if the key
+	// is not present in the table itself
+	table, isTable := obj.(*value.Table)
+	if isTable {
+		if result, ok := table.Get(key); ok {
+			return values(result), nil
+		}
+	}
+
 	indexMetaMethod, err := e.metaMethod(obj, event)
 	if err != nil {
 		return nil, fmt.Errorf("unable to obtain %s: %w", event, err)
 	}
 
 	if e.isNil(indexMetaMethod) {
-		result, _ := obj.(*value.Table).Get(key)
-		if result == nil {
-			result = value.Nil
+		if !isTable {
+			return e.error(value.NewString(fmt.Sprintf("attempt to index a %s value", obj.Type().Name())))
 		}
-		return values(result), nil
+		return values(value.Nil), nil
 	} else {
 		switch metaMethod := indexMetaMethod.(type) {
 		case *value.Function:
@@ -241,9 +435,9 @@ func (e *Engine) performIndexOperation(obj, key value.Value) ([]value.Value, err
 				return nil, fmt.Errorf("call %s: %w", event, err)
 			}
 			if len(metaMethodResults) == 0 {
-				return nil, fmt.Errorf("%s did not return any value", event)
+				return values(value.Nil), nil
 			}
-			return metaMethodResults, nil
+			return metaMethodResults[:1], nil
 		case *value.Table:
 			return e.performIndexOperation(metaMethod, key)
 		default:
@@ -255,20 +449,31 @@ func (e *Engine) performIndexOperation(obj, key value.Value) ([]value.Value, err
 func (e *Engine) performCreateIndex(tbl, key, val value.Value) error {
 	event := "__newindex"
 
-	table := tbl.(*value.Table)
+	// the meta method of a table is only consulted if the key
+	// is not present in the table yet
+	table, isTable := tbl.(*value.Table)
+	if isTable {
+		if _, ok := table.Get(key); ok {
+			table.Set(key, val)
+			return nil
+		}
+	}
 
-	indexMetaMethod, err := e.metaMethod(table, event)
+	indexMetaMethod, err := e.metaMethod(tbl, event)
 	if err != nil {
 		return fmt.Errorf("meta method: %w", err)
 	}
 
 	if e.isNil(indexMetaMethod) {
-		table.Set(key, val)
-		return nil
+		if !isTable {
+			_, err := e.error(value.NewString(fmt.Sprintf("attempt to index a %s value", tbl.Type().Name())))
+			return err
+		}
+		return e.rawSet(table, key, val)
 	}
 	switch metaMethod := indexMetaMethod.(type) {
 	case *value.Function:
-		_, err := e.call(metaMethod, table, key, val)
+		_, err := e.call(metaMethod, tbl, key, val)
 		if err != nil {
 			return fmt.Errorf("call %s: %w", event, err)
 		}
@@ -283,6 +488,17 @@ func (e *Engine) performCreateIndex(tbl, key, val value.Value) error {
 	}
 }
 
+// rawSet stores the given value under the given key in the table, without
+// invoking any meta methods. Like in Lua, nil and NaN keys raise an error.
+func (e *Engine) rawSet(table *value.Table, key, val value.Value) error {
+	if err := value.CheckKey(key); err != nil {
+		_, err := e.error(value.NewString(err.Error()))
+		return err
+	}
+	table.Set(key, val)
+	return nil
+}
+
 func (e *Engine) attemptCall(obj value.Value, args ...value.Value) ([]value.Value, error) {
 	if fn, ok := obj.(*value.Function); ok {
 		return e.call(fn, args...)
@@ -292,6 +508,9 @@ func (e *Engine) attemptCall(obj value.Value, args ...value.Value) ([]value.Valu
 	if err != nil {
 		return nil, fmt.Errorf("meta method __call: %w", err)
 	}
+	if metaMethod == nil {
+		return e.error(value.NewString(fmt.Sprintf("attempt to call a %s value", obj.Type().Name())))
+	}
 
 	arguments := make([]value.Value, len(args)+1)
 	arguments[0] = obj
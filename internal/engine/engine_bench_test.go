@@ -0,0 +1,29 @@
+package engine
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func Benchmark_sequence(b *testing.B) {
+	const program = `
+local t = {}
+for i = 1, 1000 do
+	t[#t + 1] = i
+end
+local sum = 0
+for _, v in ipairs(t) do
+	sum = sum + v
+end
+`
+	e := New(WithStdout(ioutil.Discard))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := e.Eval(strings.NewReader(program)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -102,6 +102,13 @@ func (suite *EngineSuite) TestFor() {
 			"0\n1\n2\n3\n4\n5\nend\n",
 			"",
 		},
+		{
+			"for05.lua",
+			nil,
+			"",
+			"1\n2\n3\n1\n2\nfalse\tattempt to call a nil value\n",
+			"",
+		},
 	})
 }
 
@@ -204,6 +211,48 @@ func (suite *EngineSuite) TestTable() {
 			"foobar\n",
 			"",
 		},
+		{
+			"table06.lua",
+			nil,
+			"",
+			"5\t0,1,2,3,4\n4\t0\t1,2,3\nnil\t3\nfalse\tbad argument #2 to 'insert' (position out of bounds)\nfalse\twrong number of arguments to 'insert'\nfalse\tbad argument #1 to 'insert' (table expected, got nil)\n1-a-2.5\tbc\t\nfalse\tinvalid value (at index 2) in table for 'concat'\n1\t2\t3\n2\t3\n2\t3\tnil\tnil\n3\t1\tnil\t3\n1,2,1,2,3\n2,3,4,5,5\n1,2,3\n",
+			"",
+		},
+		{
+			"table07.lua",
+			nil,
+			"",
+			"1 2 3 4 5 6 7 8 9 10\n10 9 8 7 6 5 4 3 2 1\napple banana cherry\nfalse\tattempt to compare string with number\nfalse\tbad argument #2 to 'sort' (function expected, got number)\nfalse\tinvalid order function for sorting\n1\t2\t3\nfalse\ttrue\n",
+			"",
+		},
+		{
+			"table08.lua",
+			nil,
+			"",
+			"3\t10,20,30\n10,20,30,40\t4\n10\t20,30,40\n20\t30\t40\n40,30,20\n",
+			"",
+		},
+		{
+			"table09.lua",
+			nil,
+			"",
+			"0\t3\t2\t0\t0\n100\t1\t100\tnil\n50\t50\tnil\n10\t1\t100\n385\n4\t2\n5\t5\n3\t0\t-1\t1000\t1.5\n1 2 3 0 -1 1000 1.5\n",
+			"",
+		},
+		{
+			"table10.lua",
+			nil,
+			"",
+			"a\tb\t2\tinteger\nbig\tbig\tbig\nzero\tzero\nfloat\ta\na\tb\t2\nc\t3\nx\ty\t2\nnil\tnil\nfalse\ttable index is nil\nfalse\ttable index is NaN\nfalse\ttable index is nil\nfalse\ttable index is nil\nfalse\ttable index is NaN\nfalse\ttable index is nil\nfalse\ttable index is NaN\n2\tnumber\tnil\n",
+			"",
+		},
+		{
+			"table11.lua",
+			nil,
+			"",
+			"false\ttoo many results to unpack\nfalse\ttoo many results to unpack\nfalse\ttoo many results to unpack\n1000000\nnil\tnil\n",
+			"",
+		},
 	})
 }
 
@@ -242,6 +291,13 @@ func (suite *EngineSuite) TestBinop() {
 			"3\n-1\n2\n0.5\n0\n-1\n",
 			"",
 		},
+		{
+			"binop03.lua",
+			nil,
+			"",
+			"nil\tdefault\nfalse\tnil\ttrue\t1\n2\tnil\tnil\tfalse\nfalse\tevaluated\n",
+			"",
+		},
 	})
 }
 
@@ -346,6 +402,20 @@ func (suite *EngineSuite) TestSelect() {
 			"3\n",
 			"",
 		},
+		{
+			"select02.lua",
+			nil,
+			"",
+			"b\tc\nc\n\n",
+			"",
+		},
+		{
+			"select03.lua",
+			nil,
+			"",
+			"false\tbad argument #1 to 'select' (index out of range)\nfalse\tbad argument #1 to 'select' (index out of range)\nfalse\tbad argument #1 to 'select' (number has no integer representation)\nfalse\tbad argument #1 to 'select' (number expected, got string)\nfalse\tbad argument #1 to 'select' (number expected, got no value)\nb\n\n",
+			"",
+		},
 	})
 }
 
@@ -358,6 +428,27 @@ func (suite *EngineSuite) TestIf() {
 			"Hello\tWorld\n",
 			"",
 		},
+		{
+			"if02.lua",
+			nil,
+			"",
+			"negative\nzero\nsmall\nlarge\n",
+			"",
+		},
+		{
+			"if03.lua",
+			nil,
+			"",
+			"a and b\na and not b\nb and a is nil\nb and a is false\n",
+			"",
+		},
+		{
+			"if04.lua",
+			nil,
+			"",
+			"if\nelseif 1\nelseif 1\nouter\n",
+			"",
+		},
 	})
 }
 
@@ -425,6 +516,407 @@ func (suite *EngineSuite) TestErrors() {
 	})
 }
 
+func (suite *EngineSuite) TestClosure() {
+	suite.runFileTests("closure", []fileTest{
+		{
+			"closure01.lua",
+			nil,
+			"",
+			"1\n2\n1\n3\n",
+			"",
+		},
+		{
+			"closure02.lua",
+			nil,
+			"",
+			"1\n5\n",
+			"",
+		},
+		{
+			"closure03.lua",
+			nil,
+			"",
+			"1\n2\n3\n",
+			"",
+		},
+		{
+			"closure04.lua",
+			nil,
+			"",
+			"outer\nchanged\n",
+			"",
+		},
+		{
+			"closure05.lua",
+			nil,
+			"",
+			"1\t2\nnil\n2\t2\n1\t3\t30\n120\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestMethod() {
+	suite.runFileTests("method", []fileTest{
+		{
+			"method01.lua",
+			nil,
+			"",
+			"Hello, World\n",
+			"",
+		},
+		{
+			"method02.lua",
+			nil,
+			"",
+			"9\n",
+			"",
+		},
+		{
+			"method03.lua",
+			nil,
+			"",
+			"Cat says meow\nDog says woof\nDog fetches\n",
+			"",
+		},
+		{
+			"method04.lua",
+			nil,
+			"",
+			"nested\nlocal\nexplicit self\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestArgs() {
+	suite.runFileTests("args", []fileTest{
+		{
+			"args01.lua",
+			nil,
+			"",
+			"x:button:first\ny:label:second\nz:positional:positional\n",
+			"",
+		},
+		{
+			"args02.lua",
+			nil,
+			"",
+			"pre-fix\nab\ntable\n",
+			"",
+		},
+		{
+			"args03.lua",
+			nil,
+			"",
+			"box with large\nhello world\ndone\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestEqual() {
+	suite.runFileTests("equal", []fileTest{
+		{
+			"equal01.lua",
+			nil,
+			"",
+			"true\tfalse\ttrue\ttrue\ntrue\tfalse\nfalse\tfalse\tfalse\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestCoroutine() {
+	suite.runFileTests("coroutine", []fileTest{
+		{
+			"coroutine01.lua",
+			nil,
+			"",
+			"suspended\nstart\t1\t2\ntrue\t3\nsuspended\ngot\t10\ntrue\t20\ngot\tx\ty\ntrue\tfinished\ndead\nfalse\tcannot resume dead coroutine\n",
+			"",
+		},
+		{
+			"coroutine02.lua",
+			nil,
+			"",
+			"1\n2\n3\n",
+			"",
+		},
+		{
+			"coroutine03.lua",
+			nil,
+			"",
+			"true\tinside pcall\nfalse\tfailure after yield\ntrue\tafter pcall\nfalse\tunprotected\ndead\n",
+			"",
+		},
+		{
+			"coroutine04.lua",
+			nil,
+			"",
+			"false\nthread\ntrue\ntrue\ntrue\nfalse\nrunning\nnormal\nfalse\tattempt to yield from outside a coroutine\n",
+			"",
+		},
+		{
+			"coroutine05.lua",
+			nil,
+			"",
+			"false\tbad argument #1 to 'create' (function expected, got no value)\nfalse\tbad argument #1 to 'create' (function expected, got number)\nfalse\tbad argument #1 to 'resume' (thread expected, got no value)\nfalse\tbad argument #1 to 'resume' (thread expected, got number)\nfalse\tbad argument #1 to 'status' (thread expected, got table)\nfalse\tbad argument #1 to 'wrap' (function expected, got string)\nfalse\tcannot resume dead coroutine\nfalse\tcannot resume dead coroutine\ntrue\tfalse\tcannot resume non-suspended coroutine\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestMetatable() {
+	suite.runFileTests("metatable", []fileTest{
+		{
+			"metatable01.lua",
+			nil,
+			"",
+			"locked\nfalse\tcannot change a protected metatable\nnil\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestString() {
+	suite.runFileTests("string", []fileTest{
+		{
+			"string01.lua",
+			nil,
+			"",
+			"3\t3\tABC\tabc\nababab\tab,ab,ab\t\ncba\tbcde\tabc\n97\t97\t98\t99\nHi\tfalse\tbad argument #1 to 'char' (value out of range)\nello\thello\n",
+			"",
+		},
+		{
+			"string02.lua",
+			nil,
+			"",
+			"7\t9\n8\t8\n1\t11\thello\tworld\n3\t4\nnil\nnil\nfrom\tlua\nhello\n3\t5\nkey\tvalue\n(a(b)c)\n2024\t01\t15\na\tb\n",
+			"",
+		},
+		{
+			"string03.lua",
+			nil,
+			"",
+			"one\ntwo\nthree\na\t1\nb\t2\nhell0 w0rld\t2\nhell0 world\t1\n<hello> <world>\t2\naabbcc\t3\n-a-b-c-\t4\nbob is $age\t2\nA.B.C.\t3\nW (W) W\t3\nfalse\tinvalid capture index %2\n",
+			"",
+		},
+		{
+			"string04.lua",
+			nil,
+			"",
+			" 3.14|42|ff|FF|10|x|   ab|cd   |\n1e+20 0.1 100000 1.234568e+04\n\"a\\\nb\\\"c\\\\\"\n1 0x1p-1\nLua\n0x1.8p+0\n%|00042|+5|abc\nfalse\tbad argument #2 to 'format' (number has no integer representation)\nfalse\tbad argument #2 to 'format' (no value)\nfalse\tinvalid option '%y' to 'format'\n",
+			"",
+		},
+		{
+			"string05.lua",
+			nil,
+			"",
+			"false\tmalformed pattern (missing ']')\nfalse\tmalformed pattern (ends with '%')\nfalse\tunfinished capture\ntrue\tnil\nfalse\tinvalid capture index %1\nfalse\tbad argument #1 to 'rep' (string expected, got no value)\nfalse\tbad argument #2 to 'sub' (number expected, got table)\n",
+			"",
+		},
+		{
+			"string06.lua",
+			nil,
+			"",
+			"4\t100\t0\t0\t0\n100\t5\n1\t2\n-2\t3\n12\t16\t12\nhello\t7\nabc\t5\n1.5\t9\n0.5\t5\ntrue\n",
+			"",
+		},
+		{
+			"string07.lua",
+			nil,
+			"",
+			"false\tbad argument #2 to 'pack' (integer overflow)\nfalse\tbad argument #2 to 'pack' (unsigned overflow)\nfalse\tintegral size (17) out of limits [1,16]\nfalse\tinvalid format option 'y'\nfalse\tmissing size for format option 'c'\nfalse\tbad argument #1 to 'packsize' (variable-length format)\nfalse\tbad argument #2 to 'unpack' (data string too short)\nfalse\tbad argument #2 to 'unpack' (unfinished string for format 'z')\nfalse\tbad argument #1 to 'pack' (format asks for alignment not power of 2)\nfalse\tbad argument #1 to 'pack' (invalid next option for option 'X')\n-3\t17\nfalse\t9-byte integer does not fit into Lua Integer\n8\t200\t2\n1\t2\t5\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestMath() {
+	suite.runFileTests("math", []fileTest{
+		{
+			"math01.lua",
+			nil,
+			"",
+			"3\t4\t-4\t3\n1\t-1\t1.5\nfalse\tbad argument #2 to 'fmod' (zero)\n3.00 0.75\n-3.00 -0.75\ntrue\ttrue\ttrue\ttrue\ttrue\n1.0000 1.0000 0.0000\n1.5708 0.0000 0.7854 2.3562\n7\t1\t-1\nfalse\tbad argument #1 to 'max' (value expected)\nfalse\tbad argument #2 to 'min' (number expected, got string)\ntrue\ttrue\n3\tnil\t8\tnil\ninteger\tfloat\tnil\ntrue\tfalse\ttrue\ntrue\ttrue\n",
+			"",
+		},
+		{
+			"math02.lua",
+			nil,
+			"",
+			"true\ntrue\n5\nfalse\tbad argument #2 to 'random' (interval is empty)\nfalse\tbad argument #1 to 'random' (interval is empty)\nfalse\twrong number of arguments\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestNumber() {
+	suite.runFileTests("number", []fileTest{
+		{
+			"number01.lua",
+			nil,
+			"",
+			"1\t1.0\t-0.0\t1.5\t2.0\t3\t3.0\t-4\n1\t2\t-2\t1.5\tinf\t-inf\n9.007199254741e+15\ttrue\t-9223372036854775808\n1e+15\t1e+100\t0.1\t0.33333333333333\t100000000000000\t9.2233720368548e+18\n16\t255\t21.0\t-1\t100.0\t0.03\ntrue\tinteger\tfloat\tinteger\tfloat\nfalse\ttrue\ttrue\n7\t1\t6\t-1\t-9223372036854775808\t0\t9223372036854775807\t3\n7\t7.0\t32\t1\t1.0\t3|5.0\n1\n2\n1.0\n2.0\n9223372036854775806\n9223372036854775807\n",
+			"",
+		},
+		{
+			"number02.lua",
+			nil,
+			"",
+			"false\tattempt to perform 'n//0'\nfalse\tattempt to perform 'n%0'\nfalse\tnumber has no integer representation\nfalse\tnumber has no integer representation\nfalse\tattempt to perform arithmetic on a table value\nfalse\tattempt to perform bitwise operation on a string value\nfalse\tattempt to compare number with string\nfalse\t'for' limit must be a number\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestPairs() {
+	suite.runFileTests("pairs", []fileTest{
+		{
+			"pairs01.lua",
+			nil,
+			"",
+			"1=10 2=20 3=30 4=40 x=a y=b z=c\n7\t7\nnil\n1\ta\nnil\n1\t10\t2\t20\nfalse\tinvalid key to 'next'\nfalse\tbad argument #1 to 'next' (table expected, got no value)\nfalse\tbad argument #1 to 'pairs' (table expected, got nil)\nfunction\t3\n4\tnil\n",
+			"",
+		},
+		{
+			"pairs02.lua",
+			nil,
+			"",
+			"1\t1\n2\t4\n3\t9\n3\tnil\n1\tx\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestRawset() {
+	suite.runFileTests("rawset", []fileTest{
+		{
+			"rawset01.lua",
+			nil,
+			"",
+			"true\tnil\t2\t1\nnil\nfalse\tbad argument #1 to 'rawset' (table expected, got string)\nfalse\tbad argument #3 to 'rawset' (value expected)\n42\t0\t3\t4\nfalse\tbad argument #1 to 'rawlen' (table or string expected)\ntrue\tfalse\ttrue\ntrue\ttrue\tfalse\tfalse\nfalse\tbad argument #2 to 'rawequal' (value expected)\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestTonumber() {
+	suite.runFileTests("tonumber", []fileTest{
+		{
+			"tonumber01.lua",
+			nil,
+			"",
+			"10\t10.0\t-7\t100.0\t0.5\n16\t21.0\t-16\tnil\tnil\n42\t4.5\tnil\tnil\tnil\tnil\n255\t255\t-255\t511\t1295\n5\tnil\t11\tnil\tnil\nfalse\tbad argument #1 to 'tonumber' (value expected)\nfalse\tbad argument #1 to 'tonumber' (string expected, got number)\nfalse\tbad argument #2 to 'tonumber' (base out of range)\nfalse\tbad argument #2 to 'tonumber' (base out of range)\ninteger\tfloat\tinteger\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestVarargs() {
+	suite.runFileTests("varargs", []fileTest{
+		{
+			"varargs01.lua",
+			nil,
+			"",
+			"0\t1\t3\t2\n1\t2\t3\n\n1\n1\t0\n1\t2\n3\t1\t2\t3\n0\n3\t1\tnil\t3\n3\tb\tc\n0\t10\n0\t2\n0\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestAdjust() {
+	suite.runFileTests("adjust", []fileTest{
+		{
+			"adjust01.lua",
+			nil,
+			"",
+			"1\t10\n10\t1\t2\t3\nnil\t10\n10\n1\n1\t0\n1\t2\t3\tnil\n0\t1\t2\t3\n1\t0\tnil\tnil\n1\t2\nnil\tnil\nnil\tnil\tnil\n1\t2\t3\n1\t10\tnil\n1\t2\nnil\n6\t5\n1\t10\n10\t1\t2\t3\n1\n4\t1\t1\t3\n2\n0\n1\t1\n1\n2\t-1\ttrue\ttrue\nfalse\tattempt to perform arithmetic on a nil value\nx\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestGoto() {
+	suite.runFileTests("goto", []fileTest{
+		{
+			"goto01.lua",
+			nil,
+			"",
+			"2\t1\n4\t2\n1\n3\n4\nthree\nfound\t2\t2\n1\t2\t3\t30\n3\tnil\npositive\tnot positive\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestLoad() {
+	suite.runFileTests("load", []fileTest{
+		{
+			"load01.lua",
+			nil,
+			"",
+			"function\tnil\n2\t3\n1\nenv\nglobal\tnil\t2\t3\t2\n4\n3\nnil\tstring\nnil\tattempt to load a text chunk (mode is 'b')\nnil\treader function must return a string\nnil\treader failed\nfalse\tattempt to index a nil value\n",
+			"",
+		},
+		{
+			"load02.lua",
+			nil,
+			"",
+			"10\tnil\tinclude\nnil\tcannot open missing.lua\nnil\tattempt to load a text chunk (mode is 'b')\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestEnv() {
+	suite.runFileTests("env", []fileTest{
+		{
+			"env01.lua",
+			nil,
+			"",
+			"true\ttrue\ttrue\n1\t1\t1\n3\t2\t3\n1\tnil\na\tb\n5\t5\tnil\nnil\t10\nfalse\tassignment to undeclared global undeclared\n4\tnil\n1\tnil\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestRequire() {
+	suite.runFileTests("require", []fileTest{
+		{
+			"require01.lua",
+			nil,
+			"",
+			"mod\t./mod.lua\t1\ntrue\t1\ttrue\npkg init\tsub.mod\ntrue\ttrue\ttrue\npreloaded preloaded\ntrue\ttrue\ttrue\n./sub/mod.lua\nnil\t\n\tno file './a_b.x'\n\tno file 'a_b/y'\nfalse\tmodule 'missing' not found:\n\tno field package.preload['missing']\n\tno native module 'missing'\n\tno file './missing.lua'\n\tno file './missing/init.lua'\nfalse\ttrue\n",
+			"",
+		},
+	})
+}
+
+func (suite *EngineSuite) TestOs() {
+	suite.runFileTests("os", []fileTest{
+		{
+			"os01.lua",
+			nil,
+			"",
+			"1606850863\tinteger\t0.0\n2020-12-01 19:27:43\tTue Dec  1 19:27:43 2020\tTue Dec  1 19:27:43 2020\nTue Tuesday Dec December Dec 01  1 336 12 20 2020 20\n12/01/20|2020-12-01|12/01/20|19:27:43|19:27:43|19:27|07:27:43 PM|07|PM|+0000|UTC|%|20|01\n2 2 48 48 49 2020 20\n1970-01-01 00:00:00\t01.01.1971\ntrue\n2020\t12\t1\t19\t27\t43\t3\t336\tfalse\ntrue\t-26863\n1612134000\t2021\t1\t31\t23\t1\t31\n90.0\t5.0\t1.5\nfalse\tbad argument #1 to 'date' (invalid conversion specifier '%Q is not valid')\nfalse\tbad argument #1 to 'date' (invalid conversion specifier '%E')\nfalse\tfield 'day' missing in date table\nfalse\tfield 'month' is not an integer\n",
+			"",
+		},
+	}, WithClock(utcClock{}))
+}
+
+func (suite *EngineSuite) TestIo() {
+	suite.runFileTests("io", []fileTest{
+		{
+			"io01.lua",
+			nil,
+			"",
+			"file\tfile\tnil\nfirst line\nsecond line\n\n42\t3.5\t16\tnil\nn\tope\nla\t\tst\nnil\tnil\t\n6\nline\n10\n44\nfalse\tbad argument #2 to 'seek' (invalid option 'middle')\ntrue\nnil\tBad file descriptor\t9\ntrue\nclosed file\tfile (closed)\nfalse\tattempt to use a closed file\n[first line][second line][42 3.5 0x10 nope][last]\nfirst \tline\nsecond\t line\n42 3.5\t 0x10 nope\nlast\tnil\nnil\tmissing.txt: No such file or directory\t2\nfalse\tbad argument #2 to 'open' (invalid mode)\nfalse\tmissing.txt: No such file or directory\n1 2.5\ntrue\nfalse\tbad argument #1 to 'write' (string expected, got table)\nnil\tcannot close standard file\ntrue\ttrue\n",
+			"",
+		},
+	})
+}
+
 type fileTest struct {
 	file        string
 	wantResults []value.Value
@@ -433,20 +925,20 @@ type fileTest struct {
 	wantStderr  string
 }
 
-func (suite *EngineSuite) runFileTests(basePath string, tests []fileTest) {
+func (suite *EngineSuite) runFileTests(basePath string, tests []fileTest, opts ...Option) {
 	for _, test := range tests {
 		suite.Run("file="+test.file, func() {
 			stdin := new(bytes.Buffer)
 			stdout := new(bytes.Buffer)
 			stderr := new(bytes.Buffer)
 
-			engine := New(
+			engine := New(append([]Option{
 				WithStdin(stdin),
 				WithStdout(stdout),
 				WithStderr(stderr),
 				WithClock(mockClock{}),
 				WithFs(afero.NewBasePathFs(suite.testdata, basePath)),
-			)
+			}, opts...)...)
 
 			file, err := suite.testdata.Open(filepath.Join(basePath, test.file))
 			suite.Require().NoError(err)
@@ -478,8 +970,8 @@ func (suite *EngineSuite) runFileTests(basePath string, tests []fileTest) {
 					suite.EqualValues(expected.(value.String), got.(value.String))
 					suite.Equal(expected.(value.String).String(), got.(value.String).String())
 				case value.TypeNumber:
-					suite.EqualValues(expected.(value.Number), got.(value.Number))
-					suite.Equal(expected.(value.Number).Value(), got.(value.Number).Value())
+					// the subtypes of the numbers must match as well
+					suite.Equal(expected, got)
 				case value.TypeBoolean:
 					suite.EqualValues(expected.(value.Boolean), got.(value.Boolean))
 					suite.Equal(expected.(value.Boolean).String(), got.(value.Boolean).String())
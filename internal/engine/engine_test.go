@@ -2,9 +2,11 @@ package engine
 
 import (
 	"bytes"
+	"errors"
 	"github.com/spf13/afero"
 	"github.com/tsatke/lua/internal/engine/value"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 )
@@ -76,6 +78,231 @@ infiniteRecursion()
 	suite.EqualError(err, "Stack overflow while calling 'infiniteRecursion'")
 }
 
+func (suite *EngineSuite) TestChunkVarargs() {
+	results, err := suite.engine.Eval(strings.NewReader(`
+return select("#", ...), ...
+`), value.NewString("x"), value.Nil, value.NewInteger(3))
+	suite.NoError(err)
+	suite.Equal([]value.Value{value.NewInteger(3), value.NewString("x"), value.Nil, value.NewInteger(3)}, results)
+
+	_, err = suite.engine.Eval(strings.NewReader(`
+function f()
+	return ...
+end
+`))
+	suite.Error(err)
+	suite.Contains(err.Error(), "cannot use '...' outside a vararg function")
+}
+
+func (suite *EngineSuite) TestOsHooks() {
+	fs := afero.NewMemMapFs()
+	suite.Require().NoError(afero.WriteFile(fs, "a.txt", []byte("a"), 0644))
+	exitCode := -1
+
+	e := New(
+		WithStdout(suite.stdout),
+		WithClock(mockClock{}),
+		WithFs(fs),
+		WithEnv(mockEnv{"HOME": "/home/lua"}),
+		WithExit(func(code int) { exitCode = code }),
+	)
+	results, err := e.Eval(strings.NewReader(`
+print(os.getenv("HOME"), os.getenv("MISSING"))
+print(os.rename("a.txt", "b.txt"))
+print(os.remove("a.txt"))
+print(os.remove("b.txt"))
+return os.tmpname()
+`))
+	suite.NoError(err)
+	suite.Equal("/home/lua\tnil\ntrue\nnil\ta.txt: No such file or directory\t2\ntrue\n", suite.stdout.String())
+	exists, err := afero.Exists(fs, "b.txt")
+	suite.NoError(err)
+	suite.False(exists)
+	suite.Require().Len(results, 1)
+	exists, err = afero.Exists(fs, results[0].(value.String).String())
+	suite.NoError(err)
+	suite.True(exists)
+
+	// os.exit can't be caught, and aborts the evaluation, if the exit
+	// function returns
+	suite.stdout.Reset()
+	_, err = e.Eval(strings.NewReader(`
+print(1)
+pcall(os.exit, 3)
+print(2)
+`))
+	var exitErr ExitError
+	suite.True(errors.As(err, &exitErr))
+	suite.Equal(3, exitErr.Code)
+	suite.Equal(3, exitCode)
+	suite.Equal("1\n", suite.stdout.String())
+
+	// os.exit inside a coroutine aborts the evaluation as well
+	for i, code := range []string{
+		`print(coroutine.resume(coroutine.create(function() os.exit(4) end)))`,
+		`print(pcall(coroutine.wrap(function() os.exit(5) end)))`,
+	} {
+		suite.stdout.Reset()
+		_, err = e.Eval(strings.NewReader(code + "\nprint('after')"))
+		suite.True(errors.As(err, &exitErr))
+		suite.Equal(4+i, exitErr.Code)
+		suite.Equal(4+i, exitCode)
+		suite.Empty(suite.stdout.String())
+	}
+
+	_, err = e.Eval(strings.NewReader(`os.exit(false)`))
+	suite.Error(err)
+	suite.Equal(1, exitCode)
+	_, err = e.Eval(strings.NewReader(`os.exit(true)`))
+	suite.Error(err)
+	suite.Equal(0, exitCode)
+}
+
+func (suite *EngineSuite) TestIoHooks() {
+	fs := afero.NewMemMapFs()
+	suite.Require().NoError(afero.WriteFile(fs, "/sandbox/a.txt", []byte("hello\nworld\n"), 0644))
+	suite.Require().NoError(afero.WriteFile(fs, "/secret.txt", []byte("secret"), 0644))
+
+	e := New(
+		WithStdin(strings.NewReader("12 line\nrest")),
+		WithStdout(suite.stdout),
+		WithFs(afero.NewBasePathFs(fs, "/sandbox")),
+	)
+	_, err := e.Eval(strings.NewReader(`
+print(io.read("n", "l", "a"))
+print(io.read("l"), io.read("a"))
+
+local f = assert(io.open("a.txt", "r+"))
+print(f:read("l"))
+f:write("WORLD")
+f:close()
+
+io.output("b.txt")
+io.write("first\n")
+io.close()
+f = assert(io.open("b.txt", "a"))
+f:write("second\n")
+f:close()
+for line in io.lines("b.txt") do print(line) end
+
+print(io.open("../secret.txt"))
+`))
+	suite.NoError(err)
+	suite.Equal("12\t line\trest\nnil\t\nhello\nfirst\nsecond\nnil\t../secret.txt: No such file or directory\t2\n", suite.stdout.String())
+
+	content, err := afero.ReadFile(fs, "/sandbox/a.txt")
+	suite.NoError(err)
+	suite.Equal("hello\nWORLD\n", string(content))
+}
+
+func (suite *EngineSuite) TestDiscardedEngineIsCollected() {
+	collected := make(chan struct{})
+	func() {
+		stdout := &bytes.Buffer{}
+		runtime.SetFinalizer(stdout, func(*bytes.Buffer) { close(collected) })
+		e := New(WithStdout(stdout))
+		_, err := e.Eval(strings.NewReader(`io.write("hello")`))
+		suite.NoError(err)
+	}()
+	suite.True(closedAfterGC(collected), "engine was not collected")
+}
+
+func (suite *EngineSuite) TestDiscardedEngineWithUserdataIsCollected() {
+	type handle struct{ name string }
+
+	collected := make(chan struct{})
+	func() {
+		h := &handle{"handle"}
+		runtime.SetFinalizer(h, func(*handle) { close(collected) })
+
+		mt := value.NewTable()
+		mt.Set(value.NewString("__gc"), value.NewFunction("__gc", func(...value.Value) ([]value.Value, error) {
+			return nil, nil
+		}))
+		e := New(WithStdout(suite.stdout))
+		e.assign(e._G, "handle", e.NewUserdata(h, mt))
+		_, err := e.Eval(strings.NewReader(`print(type(handle))`))
+		suite.NoError(err)
+	}()
+	suite.True(closedAfterGC(collected), "engine was not collected")
+	suite.Equal("userdata\n", suite.stdout.String())
+}
+
+func (suite *EngineSuite) TestAbandonedCoroutinesUnwind() {
+	goroutines := runtime.NumGoroutine()
+
+	e := New(WithStdout(suite.stdout))
+	_, err := e.Eval(strings.NewReader(`
+for i = 1, 10 do
+	local gen = coroutine.wrap(function()
+		while true do
+			coroutine.yield(i)
+			pcall(coroutine.yield, i)
+		end
+	end)
+	gen()
+	if i % 2 == 0 then gen() end
+end`))
+	suite.NoError(err)
+	suite.True(trueAfterGC(func() bool {
+		return runtime.NumGoroutine() <= goroutines
+	}), "%d goroutines are still running", runtime.NumGoroutine()-goroutines)
+
+	_, err = e.Eval(strings.NewReader(`
+local co = coroutine.create(function(a) print(a, coroutine.yield(a + 1)) end)
+print(coroutine.resume(co, 1))
+print(coroutine.resume(co, 3))`))
+	suite.NoError(err)
+	suite.Equal("true\t2\n1\t3\ntrue\n", suite.stdout.String())
+}
+
+func (suite *EngineSuite) TestDiscardedEngineWithCoroutinesIsCollected() {
+	goroutines := runtime.NumGoroutine()
+
+	collected := make(chan struct{})
+	func() {
+		stdout := &bytes.Buffer{}
+		runtime.SetFinalizer(stdout, func(*bytes.Buffer) { close(collected) })
+		e := New(WithStdout(stdout))
+		_, err := e.Eval(strings.NewReader(`
+for i = 1, 10 do
+	local gen = coroutine.wrap(function() coroutine.yield(i) end)
+	gen()
+end`))
+		suite.NoError(err)
+	}()
+	suite.True(closedAfterGC(collected), "engine was not collected")
+	suite.True(trueAfterGC(func() bool {
+		return runtime.NumGoroutine() <= goroutines
+	}), "%d goroutines are still running", runtime.NumGoroutine()-goroutines)
+}
+
+// closedAfterGC runs the garbage collector until the given channel is
+// closed, and reports whether that happened.
+func closedAfterGC(ch <-chan struct{}) bool {
+	return trueAfterGC(func() bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	})
+}
+
+// trueAfterGC runs the garbage collector until the given condition is
+// true, and reports whether that happened.
+func trueAfterGC(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
 func (suite *EngineSuite) TestLuaSuite() {
 	basePath := "suite"
 	mainFile := "main.lua"
@@ -112,6 +339,6 @@ func (suite *EngineSuite) TestLuaSuite() {
 	if !suite.T().Failed() {
 		suite.Require().Len(results, 1)
 		rc := results[0]
-		suite.EqualValues(rc.(value.Number).Value(), 0, "RC != 0")
+		suite.Equal(value.NewInteger(0), rc, "RC != 0")
 	}
 }
@@ -0,0 +1,12 @@
+package engine
+
+import "os"
+
+// Env provides the environment variables, that os.getenv looks up.
+type Env interface {
+	LookupEnv(key string) (string, bool)
+}
+
+type sysEnv struct{}
+
+func (sysEnv) LookupEnv(key string) (string, bool) { return os.LookupEnv(key) }
@@ -0,0 +1,8 @@
+package engine
+
+type mockEnv map[string]string
+
+func (m mockEnv) LookupEnv(key string) (string, bool) {
+	val, ok := m[key]
+	return val, ok
+}
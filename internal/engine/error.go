@@ -7,6 +7,9 @@ import (
 )
 
 // Error represents a value originating from Lua's error() function.
+// Functions implemented in Go can raise a Lua error, that can be caught
+// with pcall, by returning Error{Message: ...}. The engine completes such
+// an error with the current call stack.
 type Error struct {
 	e       *Engine
 	Message value.Value
@@ -20,9 +23,17 @@ func (e Error) Is(target error) bool {
 }
 
 func (e Error) Error() string {
+	return e.message()
+}
+
+func (e Error) message() string {
 	if e.Message == nil {
 		return "error called with <nil>"
 	}
+	if e.e == nil {
+		// the error was not raised by the engine yet
+		return fmt.Sprint(e.Message)
+	}
 	res, err := e.e.tostring(e.Message)
 	if err != nil {
 		panic(err)
@@ -31,19 +42,14 @@ func (e Error) Error() string {
 }
 
 func (e Error) String() string {
-	if e.Message == nil {
-		return "error called with <nil>"
-	}
-	res, err := e.e.tostring(e.Message)
-	if err != nil {
-		panic(err)
-	}
-	msg := string(res[0].(value.String))
+	msg := e.message()
 
 	var buf bytes.Buffer
 	var level int
 	if e.Level != nil {
-		level = int(e.Level.(value.Number))
+		if l, ok := toInteger(e.Level); ok {
+			level = int(l)
+		}
 	}
 	if len(e.Stack) > level {
 		buf.WriteString(e.Stack[level].Name)
@@ -3,21 +3,19 @@ package engine
 import (
 	"errors"
 	"fmt"
-	"strconv"
+	"math"
 
 	"github.com/tsatke/lua/internal/ast"
 	"github.com/tsatke/lua/internal/engine/value"
 	"github.com/tsatke/lua/internal/token"
 )
 
-func (e *Engine) evaluateChunk(chunk ast.Chunk) (vs []value.Value, err error) {
-	luaFn, err := e.createCallable(ast.ParList{}, chunk.Block)
+func (e *Engine) evaluateChunk(chunk ast.Chunk, args ...value.Value) (vs []value.Value, err error) {
+	fn, err := e.newChunkFunction(chunk, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create callable: %w", err)
+		return nil, err
 	}
-
-	fn := value.NewFunction(chunk.Name, luaFn)
-	results, err := e.call(fn)
+	results, err := e.call(fn, args...)
 
 	var luaErr Error
 	if errors.As(err, &luaErr) {
@@ -29,25 +27,116 @@ func (e *Engine) evaluateChunk(chunk ast.Chunk) (vs []value.Value, err error) {
 	return results, nil
 }
 
+// newChunkFunction creates the function that evaluates the given chunk. The
+// given environment is the initial value of the local _ENV of the chunk,
+// which defaults to _G if env is nil.
+func (e *Engine) newChunkFunction(chunk ast.Chunk, env value.Value) (*value.Function, error) {
+	// a chunk is a vararg function
+	luaFn, err := e.createCallable(ast.ParList{Ellipsis: true}, chunk.Block)
+	if err != nil {
+		return nil, fmt.Errorf("create callable: %w", err)
+	}
+	if env == nil {
+		env = e._G
+	}
+
+	scope := value.NewScope()
+	scope.Declare(envName, env)
+	return value.NewClosure(chunk.Name, luaFn, []*value.Scope{scope}), nil
+}
+
 func (e *Engine) evaluateBlock(block ast.Block) ([]value.Value, error) {
 	e.enterNewScope()
-	defer e.leaveScope()
+	depth := len(e.scopes)
+	defer func() {
+		// labels open scopes as well, which are left together with the
+		// scope of the block
+		for len(e.scopes) >= depth {
+			e.leaveScope()
+		}
+	}()
 
-	for _, stmt := range block.StatementsWithoutLast() {
-		if _, err := e.evaluateStatement(stmt); err != nil {
-			return nil, fmt.Errorf("stmt: %w", err)
+	// labels holds the number of scopes before every evaluated label of
+	// the block opened its own scope
+	var labels map[int]int
+	if hasLabel(block) {
+		labels = make(map[int]int)
+	}
+
+	for start := 0; ; {
+		results, target, err := e.evaluateStatements(block, start, labels)
+		if target < 0 {
+			return results, err
 		}
+		// a goto that jumps back to a label leaves all locals that were
+		// declared after the label
+		if scopes, ok := labels[target]; ok {
+			for len(e.scopes) > scopes {
+				e.leaveScope()
+			}
+		}
+		start = target
 	}
+}
 
-	lastStatement, ok := block.LastStatement()
-	if !ok {
-		return nil, nil
+// evaluateStatements evaluates the statements of the given block, beginning
+// with the statement at index start. If a goto jumps to a label of the block,
+// the index of the label is returned as target, otherwise target is -1.
+func (e *Engine) evaluateStatements(block ast.Block, start int, labels map[int]int) (results []value.Value, target int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if g, ok := r.(Goto); ok {
+				if index, found := labelIndex(block, g.Label); found {
+					results, target, err = nil, index, nil
+					return
+				}
+			}
+			panic(r)
+		}
+	}()
+
+	for i := start; i < len(block); i++ {
+		switch stmt := block[i].(type) {
+		case ast.Label:
+			// the statements after a label get a scope of their own, so
+			// that a goto that jumps back to the label can leave their
+			// locals
+			labels[i] = len(e.scopes)
+			e.enterNewScope()
+		case ast.LastStatement:
+			results, err := e.evaluateStatement(stmt)
+			if err != nil {
+				return nil, -1, fmt.Errorf("last statement %T: %w", stmt, err)
+			}
+			return results, -1, nil
+		default:
+			if _, err := e.evaluateStatement(stmt); err != nil {
+				return nil, -1, fmt.Errorf("stmt: %w", err)
+			}
+		}
 	}
-	results, err := e.evaluateStatement(lastStatement)
-	if err != nil {
-		return nil, fmt.Errorf("last statement %T: %w", lastStatement, err)
+	return nil, -1, nil
+}
+
+// hasLabel returns whether the given block holds a label.
+func hasLabel(block ast.Block) bool {
+	for _, stmt := range block {
+		if _, ok := stmt.(ast.Label); ok {
+			return true
+		}
 	}
-	return results, nil
+	return false
+}
+
+// labelIndex returns the index of the label with the given name in the
+// given block, or false if the block holds no such label.
+func labelIndex(block ast.Block, name string) (int, bool) {
+	for i, stmt := range block {
+		if label, ok := stmt.(ast.Label); ok && label.Name.Value() == name {
+			return i, true
+		}
+	}
+	return 0, false
 }
 
 func (e *Engine) evaluateStatement(stmt ast.Statement) ([]value.Value, error) {
@@ -76,6 +165,10 @@ func (e *Engine) evaluateStatement(stmt ast.Statement) ([]value.Value, error) {
 		return e.evaluateForBlock(s)
 	case ast.ForInBlock:
 		return e.evaluateForInBlock(s)
+	case ast.Goto:
+		panic(Goto{
+			Label: s.Label.Value(),
+		})
 	}
 	return nil, fmt.Errorf("%T unsupported", stmt)
 }
@@ -86,11 +179,11 @@ func (e *Engine) evaluateForInBlock(block ast.ForInBlock) ([]value.Value, error)
 		return nil, fmt.Errorf("explist: %w", err)
 	}
 
-	if len(exps) != 3 {
-		return nil, fmt.Errorf("explist didn't evaluate to 3 expressions, iter, state and initial, only got %d", len(exps))
-	}
+	// the explist is adjusted to three values, the iterator function,
+	// the state and the initial value
+	exps = adjust(exps, 3)
 
-	iter := exps[0].(*value.Function)
+	iter := exps[0]
 	state := exps[1]
 	init := exps[2]
 
@@ -98,10 +191,8 @@ func (e *Engine) evaluateForInBlock(block ast.ForInBlock) ([]value.Value, error)
 	defer e.leaveScope()
 	defer recoverBreak()
 
-	forScope := e.currentScope()
-
 	for {
-		vars, err := e.call(iter, state, init)
+		vars, err := e.attemptCall(iter, state, init)
 		if err != nil {
 			return nil, fmt.Errorf("call iter: %w", err)
 		}
@@ -110,11 +201,14 @@ func (e *Engine) evaluateForInBlock(block ast.ForInBlock) ([]value.Value, error)
 		}
 		init = vars[0]
 
+		// every iteration gets a fresh scope for the loop variables, so that
+		// closures created in the loop body don't share them
+		e.scopes[0] = value.NewScope()
 		for i, name := range block.NameList {
 			if len(vars) > i {
-				e.assign(forScope, name.Value(), vars[i])
+				e.declare(name.Value(), vars[i])
 			} else {
-				e.assign(forScope, name.Value(), value.Nil)
+				e.declare(name.Value(), value.Nil)
 			}
 		}
 
@@ -128,78 +222,117 @@ func (e *Engine) evaluateForInBlock(block ast.ForInBlock) ([]value.Value, error)
 }
 
 func (e *Engine) evaluateForBlock(block ast.ForBlock) ([]value.Value, error) {
-	var from, to, step float64
-
-	results, err := e.evaluateExpression(block.From)
-	if err != nil {
-		return nil, fmt.Errorf("exp (from): %w", err)
-	}
-	if len(results) == 0 {
-		return nil, fmt.Errorf("expression didn't evaluate to any value")
-	}
-
-	fromNums, err := e.tonumber(results[0])
-	if err != nil {
-		return nil, fmt.Errorf("need a number as from-argument in for loop")
-	}
-	from = fromNums[0].(value.Number).Value()
-
-	results, err = e.evaluateExpression(block.To)
+	from, err := e.evaluateForValue(block.From, "initial")
 	if err != nil {
-		return nil, fmt.Errorf("exp (to): %w", err)
-	}
-	if len(results) == 0 {
-		return nil, fmt.Errorf("expression didn't evaluate to any value")
+		return nil, err
 	}
-
-	toNums, err := e.tonumber(results[0])
+	to, err := e.evaluateForValue(block.To, "limit")
 	if err != nil {
-		return nil, fmt.Errorf("need a number as to-argument in for loop")
+		return nil, err
 	}
-	to = toNums[0].(value.Number).Value()
-
+	var step value.Value = value.NewInteger(1) // default value for step
 	if block.Step != nil {
-		results, err = e.evaluateExpression(block.Step)
+		step, err = e.evaluateForValue(block.Step, "step")
 		if err != nil {
-			return nil, fmt.Errorf("exp (step): %w", err)
-		}
-		if len(results) == 0 {
-			return nil, fmt.Errorf("expression didn't evaluate to any value")
-		}
-
-		stepNums, err := e.tonumber(results[0])
-		if err != nil {
-			return nil, fmt.Errorf("need a number as step-argument in for loop")
+			return nil, err
 		}
-		step = stepNums[0].(value.Number).Value()
-	} else {
-		step = 1 // default value for step
 	}
 
 	e.enterNewScope()
 	defer e.leaveScope()
 	defer recoverBreak()
 
-	forScope := e.currentScope()
-
-	// begin implementation as stated in the documentation
-
-	from -= step
-	for {
-		from += step
-		if (step >= 0 && from > to) || (step < 0 && from < to) {
-			break
+	// the loop is an integer loop if the initial value and the step are
+	// integers, otherwise all values are converted to floats
+	fromInt, fromIsInt := from.(value.Integer)
+	stepInt, stepIsInt := step.(value.Integer)
+	if fromIsInt && stepIsInt {
+		limit, skip := forLimit(to, int64(stepInt))
+		if skip {
+			return nil, nil
 		}
-		e.assign(forScope, block.Name.Value(), value.NewNumber(from))
+		for i := int64(fromInt); ; {
+			if (stepInt > 0 && i > limit) || (stepInt <= 0 && i < limit) {
+				break
+			}
+			if err := e.evaluateForIteration(block, value.NewInteger(i)); err != nil {
+				return nil, err
+			}
+			next := i + int64(stepInt)
+			if (stepInt > 0 && next < i) || (stepInt < 0 && next > i) {
+				break // don't wrap around
+			}
+			i = next
+		}
+		return nil, nil
+	}
 
-		_, err = e.evaluateBlock(block.Do)
-		if err != nil {
-			return nil, fmt.Errorf("block: %w", err)
+	fromFloat, _ := value.ToFloat(from)
+	toFloat, _ := value.ToFloat(to)
+	stepFloat, _ := value.ToFloat(step)
+	for i := fromFloat; (stepFloat > 0 && i <= toFloat) || (stepFloat <= 0 && i >= toFloat); i += stepFloat {
+		if err := e.evaluateForIteration(block, value.NewNumber(i)); err != nil {
+			return nil, err
 		}
 	}
 	return nil, nil
 }
 
+// evaluateForValue evaluates one of the control expressions of a numeric for
+// loop, which must result in a number. what is used in the error message.
+func (e *Engine) evaluateForValue(exp ast.Exp, what string) (value.Value, error) {
+	result, err := e.evaluateSingleExpression(exp)
+	if err != nil {
+		return nil, fmt.Errorf("exp (%s): %w", what, err)
+	}
+	if num, ok := toNumberValue(result); ok {
+		return num, nil
+	}
+	if what == "initial" {
+		what = "initial value"
+	}
+	_, err = e.error(value.NewString(fmt.Sprintf("'for' %s must be a number", what)))
+	return nil, err
+}
+
+// forLimit converts the limit of an integer for loop to an integer. A float
+// limit is rounded towards the initial value, and clipped to the range of the
+// integers. skip is set, if the loop must not run at all, because the limit
+// lies outside of the range of the integers.
+func forLimit(limit value.Value, step int64) (result int64, skip bool) {
+	if i, ok := limit.(value.Integer); ok {
+		return int64(i), false
+	}
+	f, _ := value.ToFloat(limit)
+	if math.IsNaN(f) {
+		return 0, true
+	}
+	if step < 0 {
+		f = math.Ceil(f)
+	} else {
+		f = math.Floor(f)
+	}
+	if i, ok := value.FloatToInteger(f); ok {
+		return i, false
+	}
+	if f > 0 {
+		return math.MaxInt64, step < 0
+	}
+	return math.MinInt64, step > 0
+}
+
+func (e *Engine) evaluateForIteration(block ast.ForBlock, i value.Value) error {
+	// every iteration gets a fresh scope for the loop variable, so that
+	// closures created in the loop body don't share it
+	e.scopes[0] = value.NewScope()
+	e.declare(block.Name.Value(), i)
+
+	if _, err := e.evaluateBlock(block.Do); err != nil {
+		return fmt.Errorf("block: %w", err)
+	}
+	return nil
+}
+
 func (e *Engine) evaluateLocalFunction(fn ast.LocalFunction) ([]value.Value, error) {
 	fnName := fn.Name.Value()
 
@@ -208,8 +341,11 @@ func (e *Engine) evaluateLocalFunction(fn ast.LocalFunction) ([]value.Value, err
 		return nil, fmt.Errorf("create callable: %w", err)
 	}
 
-	functionValue := value.NewFunction(fnName, luaFn)
-	e.assign(e.currentScope(), fnName, functionValue)
+	// the local is declared before the function is created, so that
+	// the function can refer to itself
+	e.declare(fnName, value.Nil)
+	functionValue := value.NewClosure(fnName, luaFn, e.captureScopes())
+	e.currentScope().Set(fnName, functionValue)
 	return nil, nil
 }
 
@@ -217,14 +353,10 @@ func (e *Engine) evaluateWhileBlock(block ast.WhileBlock) ([]value.Value, error)
 	defer recoverBreak()
 
 	for {
-		results, err := e.evaluateExpression(block.While)
+		result, err := e.evaluateSingleExpression(block.While)
 		if err != nil {
 			return nil, fmt.Errorf("exp: %w", err)
 		}
-		if len(results) == 0 {
-			return nil, fmt.Errorf("expression didn't evaluate to any value")
-		}
-		result := results[0]
 		if !e.valueIsLogicallyTrue(result) {
 			break
 		}
@@ -246,14 +378,10 @@ func (e *Engine) evaluateRepeatBlock(block ast.RepeatBlock) ([]value.Value, erro
 			return nil, fmt.Errorf("block: %w", err)
 		}
 
-		results, err := e.evaluateExpression(block.Until)
+		result, err := e.evaluateSingleExpression(block.Until)
 		if err != nil {
 			return nil, fmt.Errorf("exp: %w", err)
 		}
-		if len(results) == 0 {
-			return nil, fmt.Errorf("expression didn't evaluate to any value")
-		}
-		result := results[0]
 		if e.valueIsLogicallyTrue(result) {
 			break
 		}
@@ -277,37 +405,26 @@ func (e *Engine) evaluateLastStatement(stmt ast.LastStatement) ([]value.Value, e
 
 func (e *Engine) evaluateIfBlock(block ast.IfBlock) ([]value.Value, error) {
 	// if
-	ifConds, err := e.evaluateExpression(block.If)
+	ifCond, err := e.evaluateSingleExpression(block.If)
 	if err != nil {
 		return nil, fmt.Errorf("expression: %w", err)
 	}
-	var ifCond value.Value
-	if len(ifConds) > 0 {
-		ifCond = ifConds[0]
-	}
 	if e.valueIsLogicallyTrue(ifCond) {
 		return e.evaluateBlock(block.Then)
 	}
 
 	// elseif (all of them)
 	for i, elseIf := range block.ElseIf {
-		conds, err := e.evaluateExpression(elseIf.If)
+		cond, err := e.evaluateSingleExpression(elseIf.If)
 		if err != nil {
 			return nil, fmt.Errorf("elseif[%d] expression: %w", i, err)
 		}
-		var cond value.Value
-		if len(conds) > 0 {
-			cond = conds[0]
-		}
-		if !(cond == nil || cond == value.False || cond == value.Nil) {
+		if e.valueIsLogicallyTrue(cond) {
 			return e.evaluateBlock(elseIf.Then)
 		}
 	}
 
 	// else
-	if len(block.ElseIf) > 0 {
-		panic("elseif is not supported yet")
-	}
 	if block.Else != nil {
 		return e.evaluateBlock(block.Else)
 	}
@@ -324,44 +441,63 @@ func (e *Engine) evaluateDoBlock(block ast.DoBlock) ([]value.Value, error) {
 
 func (e *Engine) evaluateFunction(decl ast.Function) ([]value.Value, error) {
 	fnName := "<anonymous>"
+	parameters := decl.FuncBody.ParList
 	isAnonymous := decl.FuncName == nil
 	if !isAnonymous {
+		fnName = decl.FuncName.String()
+
 		if decl.FuncName.Name2 != nil {
-			return nil, fmt.Errorf("function with ':' not supported")
+			// a method has an implicit first parameter 'self'
+			self := token.New("self", decl.FuncName.Name2.Pos(), token.Name)
+			parameters.NameList = append([]token.Token{self}, parameters.NameList...)
 		}
-		if len(decl.FuncName.Name1) != 1 {
-			return nil, fmt.Errorf("only plain functions supported")
-		}
-
-		fnName = decl.FuncName.Name1[0].Value()
 	}
 
-	luaFn, err := e.createCallable(decl.FuncBody.ParList, decl.FuncBody.Block)
+	luaFn, err := e.createCallable(parameters, decl.FuncBody.Block)
 	if err != nil {
 		return nil, fmt.Errorf("create callable: %w", err)
 	}
 
-	functionValue := value.NewFunction(fnName, luaFn)
+	functionValue := value.NewClosure(fnName, luaFn, e.captureScopes())
 	if isAnonymous {
 		return values(functionValue), nil
 	}
-	e.assign(e._G, fnName, functionValue) // change the function Name when we support more than just one Name fragment
+
+	// function a.b.c:m() end is the same as a.b.c.m = function(self) end
+	target := ast.Var{
+		PrefixExp: ast.PrefixExp{
+			Name: decl.FuncName.Name1[0],
+		},
+	}
+	for _, name := range decl.FuncName.Name1[1:] {
+		target.Fragments = append(target.Fragments, ast.PrefixExpFragment{
+			Name: name,
+		})
+	}
+	if decl.FuncName.Name2 != nil {
+		target.Fragments = append(target.Fragments, ast.PrefixExpFragment{
+			Name: decl.FuncName.Name2,
+		})
+	}
+	if err := e.evaluateAssign(target, functionValue); err != nil {
+		return nil, fmt.Errorf("assign: %w", err)
+	}
 	return nil, nil
 }
 
 func (e *Engine) evaluateLocal(local ast.Local) error {
-	nameAmount, expAmount := len(local.NameList), len(local.ExpList)
-	amount := nameAmount
-	if expAmount < nameAmount {
-		amount = expAmount
+	vals, err := e.evaluateExpList(local.ExpList)
+	if err != nil {
+		return fmt.Errorf("explist: %w", err)
 	}
+	vals = adjust(vals, len(local.NameList))
 
-	for i := 0; i < amount; i++ {
-		if err := e.evaluateAssignLocal(local.NameList[i], local.ExpList[i]); err != nil {
-			return fmt.Errorf("assign: %w", err)
-		}
+	// the variables are declared after all expressions were evaluated, so
+	// that the expressions still refer to the variables of the enclosing
+	// scopes, e.g. in 'local x = x'
+	for i, name := range local.NameList {
+		e.declare(name.Value(), vals[i])
 	}
-
 	return nil
 }
 
@@ -380,7 +516,11 @@ func (e *Engine) evaluateFunctionCall(call ast.FunctionCall) (vs []value.Value,
 
 func (e *Engine) evaluateArgs(args ast.Args) ([]value.Value, error) {
 	if args.TableConstructor != nil {
-		return nil, fmt.Errorf("table constructors unsupported")
+		tbl, err := e.evaluateExpression(args.TableConstructor)
+		if err != nil {
+			return nil, fmt.Errorf("table constructor: %w", err)
+		}
+		return tbl, nil
 	}
 
 	if args.String != nil {
@@ -396,9 +536,21 @@ func (e *Engine) evaluateArgs(args ast.Args) ([]value.Value, error) {
 	return nil, nil
 }
 
+// evaluateExpList evaluates the given expressions, and adjusts their values
+// like Lua does. All expressions except the last one are adjusted to a single
+// value, while all values of the last expression are kept.
 func (e *Engine) evaluateExpList(explist []ast.Exp) ([]value.Value, error) {
-	var vals []value.Value
-	for _, exp := range explist {
+	vals := make([]value.Value, 0, len(explist))
+	for i, exp := range explist {
+		if i < len(explist)-1 {
+			val, err := e.evaluateSingleExpression(exp)
+			if err != nil {
+				return nil, fmt.Errorf("expression: %w", err)
+			}
+			vals = append(vals, val)
+			continue
+		}
+
 		results, err := e.evaluateExpression(exp)
 		if err != nil {
 			return nil, fmt.Errorf("expression: %w", err)
@@ -408,55 +560,50 @@ func (e *Engine) evaluateExpList(explist []ast.Exp) ([]value.Value, error) {
 	return vals, nil
 }
 
-func (e *Engine) evaluateAssignment(assignment ast.Assignment) error {
-	if len(assignment.ExpList) == 1 {
-		values, err := e.evaluateExpression(assignment.ExpList[0])
-		if err != nil {
-			return fmt.Errorf("explist: %w", err)
-		}
-
-		for i := 0; i < len(values) && i < len(assignment.VarList); i++ {
-			if err := e.evaluateAssign(assignment.VarList[i], values[i]); err != nil {
-				return fmt.Errorf("assign: %w", err)
-			}
-		}
-		return nil
+// adjust adjusts the given values to exactly n values, by discarding extra
+// values or padding with nil.
+func adjust(vals []value.Value, n int) []value.Value {
+	if len(vals) >= n {
+		return vals[:n]
 	}
-
-	varAmount, expAmount := len(assignment.VarList), len(assignment.ExpList)
-	amount := varAmount
-	if expAmount < varAmount {
-		amount = expAmount
+	adjusted := make([]value.Value, n)
+	copy(adjusted, vals)
+	for i := len(vals); i < n; i++ {
+		adjusted[i] = value.Nil
 	}
+	return adjusted
+}
 
-	expressions, err := e.evaluateExpList(assignment.ExpList)
+func (e *Engine) evaluateAssignment(assignment ast.Assignment) error {
+	vals, err := e.evaluateExpList(assignment.ExpList)
 	if err != nil {
 		return fmt.Errorf("explist: %w", err)
 	}
+	vals = adjust(vals, len(assignment.VarList))
 
-	for i := 0; i < amount; i++ {
-		if err := e.evaluateAssign(assignment.VarList[i], expressions[i]); err != nil {
+	for i, v := range assignment.VarList {
+		if err := e.evaluateAssign(v, vals[i]); err != nil {
 			return fmt.Errorf("assign: %w", err)
 		}
 	}
-
 	return nil
 }
 
 func (e *Engine) evaluateAssign(v ast.Var, val value.Value) error {
 	if len(v.Fragments) == 0 {
 		name := v.Name.Value()
-		scope := e._G
 
-		// find a scope where the variable might already exist
-		varName := value.NewString(name)
-		for i := 0; i < len(e.scopes); i++ {
-			if _, ok := e.scopes[i].Fields[varName]; ok {
-				scope = e.scopes[i]
-			}
+		// assign to the local variable if there is one, otherwise
+		// this is a global variable
+		if scope := e.scopeOf(name); scope != nil {
+			scope.Set(name, val)
+			return nil
 		}
 
-		e.assign(scope, name, val)
+		// a free name refers to a field of the environment
+		if err := e.performCreateIndex(e.environment(), value.NewString(name), val); err != nil {
+			return fmt.Errorf("create index: %w", err)
+		}
 		return nil
 	}
 
@@ -482,45 +629,23 @@ func (e *Engine) evaluateAssign(v ast.Var, val value.Value) error {
 	}
 	target := targets[0]
 
-	table, ok := target.(*value.Table)
-	if !ok {
-		return fmt.Errorf("cannot index variable of type %s", target.Type())
-	}
-
 	if lastFragment.Name != nil {
-		e.assign(table, lastFragment.Name.Value(), val)
-	} else {
-		results, err := e.evaluateExpression(lastFragment.Exp)
-		if err != nil {
-			return fmt.Errorf("index exp: %w", err)
-		}
-		if len(results) == 0 {
-			return fmt.Errorf("index exp didn't evaluate to any value")
-		}
-		return e.performCreateIndex(table, results[0], val)
-	}
-	return nil
-}
-
-func (e *Engine) evaluateAssignLocal(tk token.Token, exp ast.Exp) error {
-	if !tk.Is(token.Name) {
-		return fmt.Errorf("expected a Name token, but got %s (parser broken?)", tk)
+		return e.performCreateIndex(target, value.NewString(lastFragment.Name.Value()), val)
 	}
 
-	name := tk.Value()
-
-	val, err := e.evaluateExpression(exp)
+	key, err := e.evaluateSingleExpression(lastFragment.Exp)
 	if err != nil {
-		return fmt.Errorf("expression: %w", err)
+		return fmt.Errorf("index exp: %w", err)
 	}
-	// assign in current scope, since it is a local assignment
-	e.assign(e.currentScope(), name, val[0])
-	return nil
+	return e.performCreateIndex(target, key, val)
 }
 
 func (e *Engine) evaluateExpression(exp ast.Exp) ([]value.Value, error) {
 	switch ex := exp.(type) {
 	case ast.SimpleExp:
+		if ex.Ellipsis != nil {
+			return e.evaluateVarargs()
+		}
 		evaluated, err := e.evaluateSimpleExpression(ex)
 		if err != nil {
 			return nil, err
@@ -533,6 +658,34 @@ func (e *Engine) evaluateExpression(exp ast.Exp) ([]value.Value, error) {
 	}
 }
 
+// evaluateSingleExpression evaluates the given expression, adjusted to a
+// single value. An expression without any values, like the call of a
+// function that doesn't return anything, results in nil.
+func (e *Engine) evaluateSingleExpression(exp ast.Exp) (value.Value, error) {
+	results, err := e.evaluateExpression(exp)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return value.Nil, nil
+	}
+	return results[0], nil
+}
+
+// evaluateVarargs evaluates the vararg expression '...' to the varargs of
+// the function that is currently being called.
+func (e *Engine) evaluateVarargs() ([]value.Value, error) {
+	for _, scope := range e.scopes {
+		if scope.Varargs != nil {
+			// the varargs are copied, so that the caller can't modify them
+			varargs := make([]value.Value, len(scope.Varargs))
+			copy(varargs, scope.Varargs)
+			return varargs, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot use '...' outside a vararg function")
+}
+
 func (e *Engine) evaluateComplexExpression(exp ast.ComplexExp) ([]value.Value, error) {
 	switch ex := exp.(type) {
 	case ast.PrefixExp:
@@ -551,58 +704,67 @@ func (e *Engine) evaluateComplexExpression(exp ast.ComplexExp) ([]value.Value, e
 }
 
 func (e *Engine) evaluateTableConstructor(tblCtor ast.TableConstructor) ([]value.Value, error) {
-	tbl := value.NewTable()
+	arraySize := 0
+	for _, field := range tblCtor.Fields {
+		if field.Anonymous() {
+			arraySize++
+		}
+	}
+	tbl := value.NewTableSize(arraySize, len(tblCtor.Fields)-arraySize)
 
 	anonymousFieldIndex := 1
-	for _, field := range tblCtor.Fields {
+	for i, field := range tblCtor.Fields {
+		if field.Anonymous() && i == len(tblCtor.Fields)-1 {
+			// all values of the last positional field are added to the
+			// table, e.g. all varargs in {...}
+			vals, err := e.evaluateExpression(field.RightExp)
+			if err != nil {
+				return nil, fmt.Errorf("right exp: %w", err)
+			}
+			for j, val := range vals {
+				tbl.Set(value.NewInteger(int64(anonymousFieldIndex+j)), val)
+			}
+			break
+		}
+
 		var key value.Value
 		if field.Anonymous() {
-			key = value.NewNumber(float64(anonymousFieldIndex))
+			key = value.NewInteger(int64(anonymousFieldIndex))
 			anonymousFieldIndex++
 		} else if field.LeftName != nil {
 			key = value.NewString(field.LeftName.Value())
 		} else {
-			vals, err := e.evaluateExpression(field.LeftExp)
+			k, err := e.evaluateSingleExpression(field.LeftExp)
 			if err != nil {
 				return nil, fmt.Errorf("left exp: %w", err)
 			}
-			if len(vals) == 0 {
-				return nil, fmt.Errorf("left exp didn't evaluate to any value")
-			}
-			key = vals[0]
+			key = k
 		}
 
-		vals, err := e.evaluateExpression(field.RightExp)
+		val, err := e.evaluateSingleExpression(field.RightExp)
 		if err != nil {
 			return nil, fmt.Errorf("right exp: %w", err)
 		}
-		if len(vals) == 0 {
-			return nil, fmt.Errorf("right exp didn't evaluate to any value")
+		if err := e.rawSet(tbl, key, val); err != nil {
+			return nil, err
 		}
-		val := vals[0]
-		tbl.Set(key, val)
 	}
 	return values(tbl), nil
 }
 
 func (e *Engine) evaluateUnopExpression(exp ast.UnopExp) ([]value.Value, error) {
-	operands, err := e.evaluateExpression(exp.Exp)
+	operand, err := e.evaluateSingleExpression(exp.Exp)
 	if err != nil {
 		return nil, fmt.Errorf("operand: %w", err)
 	}
-	operand := operands[0]
 
 	var event string
 	var metaMethod *value.Function
 
 	switch exp.Unop.Value() {
 	case "-":
-		if num, ok := operand.(value.Number); ok {
-			results, err := e.multiply(value.NewNumber(-1), num)
-			if err != nil {
-				return nil, fmt.Errorf("arithmetic unary negation: %w", err)
-			}
-			return results, nil
+		if _, ok := toNumberValue(operand); ok {
+			return e.negate(operand)
 		}
 		event = "__unm"
 	case "not":
@@ -617,12 +779,8 @@ func (e *Engine) evaluateUnopExpression(exp ast.UnopExp) ([]value.Value, error)
 		}
 		return values(value.True), nil
 	case "~":
-		if num, ok := operand.(value.Number); ok {
-			results, err := e.evaluateBitwiseNot(num)
-			if err != nil {
-				return nil, err
-			}
-			return results, nil
+		if _, ok := toInteger(operand); ok {
+			return e.evaluateBitwiseNot(operand)
 		}
 		event = "__bnot"
 	case "#":
@@ -644,6 +802,15 @@ func (e *Engine) evaluateUnopExpression(exp ast.UnopExp) ([]value.Value, error)
 	}
 
 	if metaMethod == nil {
+		// without a metamethod, the operations fail with the appropriate error
+		switch exp.Unop.Value() {
+		case "-":
+			return e.negate(operand)
+		case "~":
+			return e.evaluateBitwiseNot(operand)
+		case "#":
+			return e.evaluateLen(operand)
+		}
 		return nil, fmt.Errorf("unsupported unary operator '%s' on %s", exp.Unop.Value(), operand.Type())
 	}
 
@@ -656,42 +823,49 @@ func (e *Engine) evaluateUnopExpression(exp ast.UnopExp) ([]value.Value, error)
 
 func (e *Engine) evaluateBinopExpression(exp ast.BinopExp) ([]value.Value, error) {
 	switch exp.Binop.Value() {
-	case "or":
+	case "and", "or":
 		return e.evaluateBinopLazy(exp)
 	}
 	return e.evaluateBinopEager(exp)
 }
 
+// evaluateBinopLazy evaluates the logical operators, which only evaluate
+// their right operand if the left operand doesn't determine the result.
 func (e *Engine) evaluateBinopLazy(exp ast.BinopExp) ([]value.Value, error) {
+	left, err := e.evaluateSingleExpression(exp.Left)
+	if err != nil {
+		return nil, fmt.Errorf("left exp: %w", err)
+	}
+
 	switch exp.Binop.Value() {
-	case "or":
-		lefts, err := e.evaluateExpression(exp.Left)
-		if err != nil {
-			return nil, fmt.Errorf("left exp: %w", err)
+	case "and":
+		if !e.valueIsLogicallyTrue(left) {
+			return values(left), nil
 		}
-		if e.valueIsLogicallyTrue(lefts[0]) {
-			return values(lefts[0]), nil
+	case "or":
+		if e.valueIsLogicallyTrue(left) {
+			return values(left), nil
 		}
+	default:
+		return nil, fmt.Errorf("unsupported binary operator %s", exp.Binop.Value())
+	}
 
-		rights, err := e.evaluateExpression(exp.Right)
-		if err != nil {
-			return nil, fmt.Errorf("right exp: %w", err)
-		}
-		return values(rights[0]), nil
+	right, err := e.evaluateSingleExpression(exp.Right)
+	if err != nil {
+		return nil, fmt.Errorf("right exp: %w", err)
 	}
-	return nil, fmt.Errorf("unsupported binary operator %s", exp.Binop.Value())
+	return values(right), nil
 }
 
 func (e *Engine) evaluateBinopEager(exp ast.BinopExp) ([]value.Value, error) {
-	lefts, err := e.evaluateExpression(exp.Left)
+	left, err := e.evaluateSingleExpression(exp.Left)
 	if err != nil {
 		return nil, fmt.Errorf("left exp: %w", err)
 	}
-	rights, err := e.evaluateExpression(exp.Right)
+	right, err := e.evaluateSingleExpression(exp.Right)
 	if err != nil {
 		return nil, fmt.Errorf("right exp: %w", err)
 	}
-	left, right := lefts[0], rights[0]
 
 	var operator func(left, right value.Value) ([]value.Value, error)
 
@@ -722,8 +896,6 @@ func (e *Engine) evaluateBinopEager(exp ast.BinopExp) ([]value.Value, error) {
 		operator = e.evaluateGreater
 	case ">=":
 		operator = e.evaluateGreaterOrEqual
-	case "and":
-		operator = e.evaluateAnd
 	case "|":
 		operator = e.evaluateBitwiseOr
 	case "&":
@@ -815,14 +987,6 @@ func (e *Engine) evaluateBitwiseRightShift(left, right value.Value) ([]value.Val
 	return results, nil
 }
 
-func (e *Engine) evaluateAnd(left, right value.Value) ([]value.Value, error) {
-	results, err := e.and(left, right)
-	if err != nil {
-		return nil, fmt.Errorf("and: %w", err)
-	}
-	return results, nil
-}
-
 func (e *Engine) evaluateAddition(left, right value.Value) ([]value.Value, error) {
 	results, err := e.add(left, right)
 	if err != nil {
@@ -913,7 +1077,7 @@ func (e *Engine) evaluateLess(left, right value.Value) ([]value.Value, error) {
 
 	less, err := e.less(left, right)
 	if err != nil {
-		return nil, fmt.Errorf("compare: %w", err)
+		return nil, err
 	}
 	if less {
 		return values(value.True), nil
@@ -958,7 +1122,7 @@ func (e *Engine) evaluateLessOrEqual(left, right value.Value) ([]value.Value, er
 
 	lessEq, err := e.lessEqual(left, right)
 	if err != nil {
-		return nil, fmt.Errorf("compare: %w", err)
+		return nil, err
 	}
 	if lessEq {
 		return values(value.True), nil
@@ -966,26 +1130,14 @@ func (e *Engine) evaluateLessOrEqual(left, right value.Value) ([]value.Value, er
 	return values(value.False), nil
 }
 
+// evaluateGreater evaluates left > right, which Lua defines as right < left.
 func (e *Engine) evaluateGreater(left, right value.Value) ([]value.Value, error) {
-	greater, err := e.less(right, left)
-	if err != nil {
-		return nil, fmt.Errorf("compare: %w", err)
-	}
-	if greater {
-		return values(value.True), nil
-	}
-	return values(value.False), nil
+	return e.evaluateLess(right, left)
 }
 
+// evaluateGreaterOrEqual evaluates left >= right, which Lua defines as right <= left.
 func (e *Engine) evaluateGreaterOrEqual(left, right value.Value) ([]value.Value, error) {
-	greaterEq, err := e.lessEqual(right, left)
-	if err != nil {
-		return nil, fmt.Errorf("compare: %w", err)
-	}
-	if greaterEq {
-		return values(value.True), nil
-	}
-	return values(value.False), nil
+	return e.evaluateLessOrEqual(right, left)
 }
 
 func (e *Engine) evaluatePrefixExpression(exp ast.PrefixExp) ([]value.Value, error) {
@@ -995,21 +1147,21 @@ func (e *Engine) evaluatePrefixExpression(exp ast.PrefixExp) ([]value.Value, err
 	if exp.Exp != nil {
 		currentName = "(<exp>)"
 
-		results, err := e.evaluateExpression(exp.Exp)
+		// if an expression is in parenthesis, which is the case here,
+		// the values it evaluates to are cut down to one, meaning that all
+		// elements except the first one are discarded
+		result, err := e.evaluateSingleExpression(exp.Exp)
 		if err != nil {
 			return nil, fmt.Errorf("expression: %w", err)
 		}
-		if len(results) == 0 {
-			current = nil
-		} else {
-			// if an expression is in parenthesis, which is the case here,
-			// the values it evaluates to are cut down to one, meaning that all
-			// elements except the first one are discarded
-			current = results[0]
-		}
+		current = result
 	} else {
 		name := exp.Name.Value()
-		current, _ = e.variable(name) // we don't care whether or not the variable exists, and in case of fragments, the loop below will handle nil values
+		val, err := e.variable(name)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", name, err)
+		}
+		current = val
 		currentName = name
 	}
 
@@ -1019,33 +1171,23 @@ func (e *Engine) evaluatePrefixExpression(exp ast.PrefixExp) ([]value.Value, err
 
 	var results []value.Value
 
-	for i, fragment := range exp.Fragments {
-		isLast := i < len(exp.Fragments)-1
-
-		if current == nil {
-			if fragment.Args != nil {
-				return nil, fmt.Errorf("cannot call nil value of variable '%s'", currentName)
+	for _, fragment := range exp.Fragments {
+		if e.isNil(current) {
+			if fragment.Args != nil && fragment.Name == nil {
+				return e.error(value.NewString(fmt.Sprintf("attempt to call a nil value (variable '%s')", currentName)))
 			}
-			return nil, fmt.Errorf("cannot index nil value of variable '%s'", currentName)
+			return e.error(value.NewString(fmt.Sprintf("attempt to index a nil value (variable '%s')", currentName)))
 		}
 
 		results = nil
 
 		if fragment.Exp != nil {
-			table, ok := current.(*value.Table)
-			if !ok {
-				return nil, fmt.Errorf("cannot index variable of type %s", current.Type())
-			}
-			vals, err := e.evaluateExpression(fragment.Exp)
+			indexKey, err := e.evaluateSingleExpression(fragment.Exp)
 			if err != nil {
 				return nil, fmt.Errorf("index exp: %w", err)
 			}
-			if len(vals) == 0 {
-				return nil, fmt.Errorf("index exp didn't evaluate to any value")
-			}
-			indexKey := vals[0]
 
-			indexResults, err := e.performIndexOperation(table, indexKey)
+			indexResults, err := e.performIndexOperation(current, indexKey)
 			if err != nil {
 				return nil, fmt.Errorf("index: %w", err)
 			}
@@ -1053,16 +1195,20 @@ func (e *Engine) evaluatePrefixExpression(exp ast.PrefixExp) ([]value.Value, err
 			current = indexResults[0]
 			currentName = currentName + "[<index>]"
 		} else {
+			var self value.Value
 			if fragment.Name != nil {
-				table, ok := current.(*value.Table)
-				if !ok {
-					return nil, fmt.Errorf("cannot index variable of type %s", current.Type())
+				if fragment.Args != nil {
+					// this fragment is a method call, obj:name(args) is
+					// the same as obj.name(obj, args)
+					self = current
 				}
-				current, ok = table.Get(value.NewString(fragment.Name.Value()))
-				if !ok {
-					return nil, fmt.Errorf("variable '%s' has no field '%s'", currentName, fragment.Name.Value())
+
+				indexResults, err := e.performIndexOperation(current, value.NewString(fragment.Name.Value()))
+				if err != nil {
+					return nil, fmt.Errorf("index: %w", err)
 				}
-				results = values(current)
+				results = indexResults
+				current = indexResults[0]
 				currentName = fragment.Name.Value()
 			}
 
@@ -1073,21 +1219,22 @@ func (e *Engine) evaluatePrefixExpression(exp ast.PrefixExp) ([]value.Value, err
 				if err != nil {
 					return nil, fmt.Errorf("args: %w", err)
 				}
+				if self != nil {
+					args = append(values(self), args...)
+				}
 
 				res, err := e.attemptCall(current, args...)
 				if err != nil {
 					return nil, fmt.Errorf("call '%s': %w", currentName, err)
 				}
 				results = res
-				if len(res) == 0 && isLast {
-					// One fragment function call can only return nil, if it's the last fragment. Otherwise,
-					// subsequent calls would attempt to call something on nil.
-					return nil, fmt.Errorf("calling '%s' returned nil, but it is not the last call in the chain", currentName)
-				}
+				// if more fragments follow, the results are adjusted to
+				// a single value
+				current = value.Nil
 				if len(res) > 0 {
 					current = res[0]
-					currentName += "(...)"
 				}
+				currentName += "(...)"
 			}
 		}
 	}
@@ -1099,11 +1246,11 @@ func (e *Engine) evaluateSimpleExpression(exp ast.SimpleExp) (value.Value, error
 	case exp.String != nil:
 		return value.NewString(exp.String.Value()), nil
 	case exp.Number != nil:
-		val, err := strconv.ParseFloat(exp.Number.Value(), 64)
-		if err != nil {
-			return nil, fmt.Errorf("cannot parse value '%s' as number", exp.Number.Value())
+		val, ok := value.ParseNumber(exp.Number.Value())
+		if !ok {
+			return nil, fmt.Errorf("malformed number near '%s'", exp.Number.Value())
 		}
-		return value.NewNumber(val), nil
+		return val, nil
 	case exp.True != nil:
 		return value.True, nil
 	case exp.False != nil:
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// finalizerQueue holds the userdata values that became unreachable, but whose
+// __gc metamethod was not called yet. Go runs finalizers on a separate
+// goroutine, so instead of calling into the engine from there, unreachable
+// userdata values are queued and finalized by the engine itself, whenever it
+// is safe to do so.
+//
+// The queue is a separate object that doesn't reference the engine, since
+// the finalizers registered with the queue are never collected. If they
+// referenced the engine, the engine would never be collected either.
+type finalizerQueue struct {
+	mu      sync.Mutex
+	pending []*value.Userdata
+}
+
+func (q *finalizerQueue) push(u *value.Userdata) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, u)
+}
+
+func (q *finalizerQueue) drain() []*value.Userdata {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending := q.pending
+	q.pending = nil
+	return pending
+}
+
+// NewUserdata creates a new full userdata that wraps the given Go value and
+// has the given metatable, which may be nil. Like in Lua, the userdata is
+// only marked for finalization if the metatable has a __gc field when the
+// userdata is created. If a marked userdata becomes unreachable, and its
+// metatable has a __gc metamethod at that time, the metamethod is called
+// with the userdata as argument.
+//
+// Like with Go finalizers, a userdata that is part of a reference cycle
+// with other finalized objects might never be finalized.
+func (e *Engine) NewUserdata(v interface{}, metatable *value.Table) *value.Userdata {
+	u := value.NewUserdata(v)
+	u.Metatable = metatable
+	if metatable != nil {
+		if gc, ok := metatable.Get(value.NewString("__gc")); ok && !e.isNil(gc) {
+			runtime.SetFinalizer(u, e.finalizers.push)
+		}
+	}
+	return u
+}
+
+// runFinalizers calls the __gc metamethods of all userdata values that became
+// unreachable since the last call. Errors in a __gc metamethod can't be
+// propagated to anyone, so they are written to stderr.
+func (e *Engine) runFinalizers() {
+	for _, u := range e.finalizers.drain() {
+		gc, err := e.metaMethod(u, "__gc")
+		if err != nil || e.isNil(gc) {
+			continue
+		}
+		if _, err := e.attemptCall(gc, u); err != nil {
+			_, _ = fmt.Fprintf(e.stderr, "error in __gc metamethod (%v)\n", err)
+		}
+	}
+}
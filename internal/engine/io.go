@@ -0,0 +1,671 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// file is the Go value of a file handle. Reads are buffered, so that lines
+// and numerals can be read without consuming more than they need. Writes
+// are not buffered, so that they are in order with the output of print.
+type file struct {
+	r      io.Reader
+	w      io.Writer
+	seeker io.Seeker
+	// closer is nil for the standard files, which can't be closed.
+	closer io.Closer
+
+	buf    *bufio.Reader
+	closed bool
+}
+
+func (f *file) reader() (*bufio.Reader, error) {
+	if f.r == nil {
+		return nil, syscall.EBADF
+	}
+	if f.buf == nil {
+		f.buf = bufio.NewReader(f.r)
+	}
+	return f.buf, nil
+}
+
+// discardBuffer discards the data that was read ahead into the buffer,
+// and moves the position of the underlying file back accordingly, so
+// that writes and seeks start at the position that was read up to.
+func (f *file) discardBuffer() error {
+	if f.buf == nil || f.buf.Buffered() == 0 {
+		return nil
+	}
+	if f.seeker == nil {
+		return nil
+	}
+	if _, err := f.seeker.Seek(-int64(f.buf.Buffered()), io.SeekCurrent); err != nil {
+		return err
+	}
+	f.buf.Reset(f.r)
+	return nil
+}
+
+func (f *file) write(s string) error {
+	if f.w == nil {
+		return syscall.EBADF
+	}
+	if err := f.discardBuffer(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(f.w, s)
+	return err
+}
+
+func (f *file) seek(offset int64, whence int) (int64, error) {
+	if f.seeker == nil {
+		return 0, syscall.ESPIPE
+	}
+	if err := f.discardBuffer(); err != nil {
+		return 0, err
+	}
+	return f.seeker.Seek(offset, whence)
+}
+
+func (f *file) flush() error {
+	if flusher, ok := f.w.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+func (f *file) close() error {
+	f.closed = true
+	return f.closer.Close()
+}
+
+// initIO creates the io library and the metatable of file handles. The
+// standard files read from and write to the engine's stdin, stdout and
+// stderr, and all other files are opened in the engine's file system.
+func (e *Engine) initIO() {
+	methods := value.NewTable()
+	for _, fn := range []*value.Function{
+		value.NewFunction("close", e.fileClose),
+		value.NewFunction("flush", e.fileFlush),
+		value.NewFunction("lines", e.fileLines),
+		value.NewFunction("read", e.fileRead),
+		value.NewFunction("seek", e.fileSeek),
+		value.NewFunction("setvbuf", e.fileSetvbuf),
+		value.NewFunction("write", e.fileWrite),
+	} {
+		e.assign(methods, fn.Name, fn)
+	}
+
+	e.fileMetatable = value.NewTable()
+	e.assign(e.fileMetatable, "__index", methods)
+	e.assign(e.fileMetatable, "__name", value.NewString("FILE*"))
+	e.assign(e.fileMetatable, "__gc", value.NewFunction("__gc", e.fileGC))
+	e.assign(e.fileMetatable, "__tostring", value.NewFunction("__tostring", e.fileTostring))
+
+	stdin := e.stdFile(&file{r: e.stdin})
+	stdout := e.stdFile(&file{w: e.stdout})
+	stderr := e.stdFile(&file{w: e.stderr})
+	e.input, e.output = stdin, stdout
+
+	e.RegisterModule("io",
+		value.NewFunction("close", e.ioClose),
+		value.NewFunction("flush", e.ioFlush),
+		value.NewFunction("input", e.ioInput),
+		value.NewFunction("lines", e.ioLines),
+		value.NewFunction("open", e.ioOpen),
+		value.NewFunction("output", e.ioOutput),
+		value.NewFunction("read", e.ioRead),
+		value.NewFunction("type", e.ioType),
+		value.NewFunction("write", e.ioWrite),
+	)
+	lib := e.field(e._G, "io").(*value.Table)
+	e.assign(lib, "stdin", stdin)
+	e.assign(lib, "stdout", stdout)
+	e.assign(lib, "stderr", stderr)
+}
+
+// stdFile creates the file handle of a standard file. Unlike the handles
+// of opened files, it has no finalizer, since the standard files are never
+// closed by __gc and live as long as the engine.
+func (e *Engine) stdFile(f *file) *value.Userdata {
+	u := value.NewUserdata(f)
+	u.Metatable = e.fileMetatable
+	return u
+}
+
+// toFile returns the n-th (1-based) argument as file. If the file is
+// closed, an error is returned.
+func (e *Engine) toFile(fnName string, args []value.Value, n int) (*file, error) {
+	if n <= len(args) {
+		if u, ok := args[n-1].(*value.Userdata); ok {
+			if f, ok := u.Value.(*file); ok {
+				if f.closed {
+					_, err := e.error(value.NewString("attempt to use a closed file"))
+					return nil, err
+				}
+				return f, nil
+			}
+		}
+	}
+	return nil, typeArgError(n, fnName, "FILE*", args)
+}
+
+// defaultFile returns the given default input or output file, which must
+// not be closed.
+func (e *Engine) defaultFile(u *value.Userdata, kind string) (*file, error) {
+	f := u.Value.(*file)
+	if f.closed {
+		_, err := e.error(value.NewString(fmt.Sprintf("default %s file is closed", kind)))
+		return nil, err
+	}
+	return f, nil
+}
+
+// openFile opens the file with the given name in the engine's file system.
+// The mode is one of the modes that the C function fopen accepts.
+func (e *Engine) openFile(filename, mode string) (*value.Userdata, error) {
+	flag := 0
+	switch strings.TrimRight(mode, "b") {
+	case "r":
+		flag = os.O_RDONLY
+	case "w":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case "a":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	case "r+":
+		flag = os.O_RDWR
+	case "w+":
+		flag = os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	case "a+":
+		flag = os.O_RDWR | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := e.fs.OpenFile(filename, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	lf := &file{
+		seeker: f,
+		closer: f,
+	}
+	if flag&os.O_WRONLY == 0 {
+		lf.r = f
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		lf.w = f
+	}
+	return e.NewUserdata(lf, e.fileMetatable), nil
+}
+
+// checkMode reports whether the given mode is a valid mode for io.open.
+func checkMode(mode string) bool {
+	if mode == "" || strings.IndexByte("rwa", mode[0]) < 0 {
+		return false
+	}
+	mode = mode[1:]
+	if strings.HasPrefix(mode, "+") {
+		mode = mode[1:]
+	}
+	return strings.Trim(mode, "b") == ""
+}
+
+func (e *Engine) ioClose(args ...value.Value) ([]value.Value, error) {
+	if isNoneOrNil(args, 1) {
+		return e.fileClose(e.output)
+	}
+	return e.fileClose(args...)
+}
+
+func (e *Engine) ioFlush(args ...value.Value) ([]value.Value, error) {
+	f, err := e.defaultFile(e.output, "output")
+	if err != nil {
+		return nil, err
+	}
+	return fileResult(f.flush(), ""), nil
+}
+
+func (e *Engine) ioInput(args ...value.Value) ([]value.Value, error) {
+	return e.setDefaultFile("input", "r", &e.input, args)
+}
+
+func (e *Engine) ioOutput(args ...value.Value) ([]value.Value, error) {
+	return e.setDefaultFile("output", "w", &e.output, args)
+}
+
+// setDefaultFile implements io.input and io.output. If a file name is given,
+// the file is opened with the given mode and becomes the default file, if
+// a file handle is given, it becomes the default file. The current default
+// file is returned.
+func (e *Engine) setDefaultFile(fnName, mode string, def **value.Userdata, args []value.Value) ([]value.Value, error) {
+	if !isNoneOrNil(args, 1) {
+		if filename, ok := args[0].(value.String); ok {
+			u, err := e.openFile(string(filename), mode)
+			if err != nil {
+				msg, _ := errorMessage(err)
+				return e.error(value.NewString(fmt.Sprintf("cannot open file '%s' (%s)", filename, msg)))
+			}
+			*def = u
+		} else {
+			if _, err := e.toFile(fnName, args, 1); err != nil {
+				return nil, err
+			}
+			*def = args[0].(*value.Userdata)
+		}
+	}
+	return values(*def), nil
+}
+
+func (e *Engine) ioLines(args ...value.Value) ([]value.Value, error) {
+	if isNoneOrNil(args, 1) {
+		if _, err := e.defaultFile(e.input, "input"); err != nil {
+			return nil, err
+		}
+		var formats []value.Value
+		if len(args) > 0 {
+			formats = args[1:]
+		}
+		return values(e.linesIterator(e.input, false, formats)), nil
+	}
+
+	filename, err := checkString("lines", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	u, err := e.openFile(filename, "r")
+	if err != nil {
+		msg, _ := errorMessage(err)
+		return e.error(value.NewString(fmt.Sprintf("%s: %s", filename, msg)))
+	}
+	return values(e.linesIterator(u, true, args[1:])), nil
+}
+
+func (e *Engine) ioOpen(args ...value.Value) ([]value.Value, error) {
+	filename, err := checkString("open", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := optString("open", args, 2, "r")
+	if err != nil {
+		return nil, err
+	}
+	if !checkMode(mode) {
+		return nil, argError(2, "open", "invalid mode")
+	}
+
+	u, err := e.openFile(filename, mode)
+	if err != nil {
+		return fileResult(err, filename), nil
+	}
+	return values(u), nil
+}
+
+func (e *Engine) ioRead(args ...value.Value) ([]value.Value, error) {
+	f, err := e.defaultFile(e.input, "input")
+	if err != nil {
+		return nil, err
+	}
+	return e.read(f, "read", args, 1)
+}
+
+func (e *Engine) ioType(args ...value.Value) ([]value.Value, error) {
+	if len(args) == 0 {
+		return nil, argError(1, "type", "value expected")
+	}
+	if u, ok := args[0].(*value.Userdata); ok {
+		if f, ok := u.Value.(*file); ok {
+			if f.closed {
+				return values(value.NewString("closed file")), nil
+			}
+			return values(value.NewString("file")), nil
+		}
+	}
+	return values(value.Nil), nil
+}
+
+func (e *Engine) ioWrite(args ...value.Value) ([]value.Value, error) {
+	f, err := e.defaultFile(e.output, "output")
+	if err != nil {
+		return nil, err
+	}
+	return e.write(f, e.output, "write", args, 1)
+}
+
+func (e *Engine) fileClose(args ...value.Value) ([]value.Value, error) {
+	f, err := e.toFile("close", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if f.closer == nil {
+		return values(value.Nil, value.NewString("cannot close standard file")), nil
+	}
+	return fileResult(f.close(), ""), nil
+}
+
+func (e *Engine) fileFlush(args ...value.Value) ([]value.Value, error) {
+	f, err := e.toFile("flush", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return fileResult(f.flush(), ""), nil
+}
+
+func (e *Engine) fileLines(args ...value.Value) ([]value.Value, error) {
+	if _, err := e.toFile("lines", args, 1); err != nil {
+		return nil, err
+	}
+	return values(e.linesIterator(args[0].(*value.Userdata), false, args[1:])), nil
+}
+
+// linesIterator returns the iterator function of io.lines and file:lines,
+// which reads from the given file with the given formats on every call. If
+// closeAtEOF is set, the file is closed when the iterator reaches the end
+// of the file.
+func (e *Engine) linesIterator(u *value.Userdata, closeAtEOF bool, formats []value.Value) *value.Function {
+	formats = append([]value.Value(nil), formats...)
+	return value.NewFunction("lines", func(...value.Value) ([]value.Value, error) {
+		f := u.Value.(*file)
+		if f.closed {
+			return e.error(value.NewString("file is already closed"))
+		}
+		results, err := e.read(f, "lines", formats, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) > 1 && e.isNil(results[0]) {
+			// a read error is raised, instead of being returned
+			return e.error(results[1])
+		}
+		if e.isNil(results[0]) && closeAtEOF {
+			_ = f.close()
+		}
+		return results, nil
+	})
+}
+
+func (e *Engine) fileRead(args ...value.Value) ([]value.Value, error) {
+	f, err := e.toFile("read", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return e.read(f, "read", args[1:], 2)
+}
+
+func (e *Engine) fileSeek(args ...value.Value) ([]value.Value, error) {
+	f, err := e.toFile("seek", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	whence, err := optString("seek", args, 2, "cur")
+	if err != nil {
+		return nil, err
+	}
+	offset, err := optInteger("seek", args, 3, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var w int
+	switch whence {
+	case "set":
+		w = io.SeekStart
+	case "cur":
+		w = io.SeekCurrent
+	case "end":
+		w = io.SeekEnd
+	default:
+		return nil, argError(2, "seek", fmt.Sprintf("invalid option '%s'", whence))
+	}
+
+	pos, err := f.seek(offset, w)
+	if err != nil {
+		return fileResult(err, ""), nil
+	}
+	return values(value.NewInteger(pos)), nil
+}
+
+func (e *Engine) fileSetvbuf(args ...value.Value) ([]value.Value, error) {
+	if _, err := e.toFile("setvbuf", args, 1); err != nil {
+		return nil, err
+	}
+	mode, err := checkString("setvbuf", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := optInteger("setvbuf", args, 3, 0); err != nil {
+		return nil, err
+	}
+	switch mode {
+	case "no", "full", "line":
+	default:
+		return nil, argError(2, "setvbuf", fmt.Sprintf("invalid option '%s'", mode))
+	}
+	// writes are never buffered, so all modes behave like "no"
+	return values(value.True), nil
+}
+
+func (e *Engine) fileWrite(args ...value.Value) ([]value.Value, error) {
+	f, err := e.toFile("write", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return e.write(f, args[0], "write", args[1:], 2)
+}
+
+func (e *Engine) fileGC(args ...value.Value) ([]value.Value, error) {
+	if u, ok := args[0].(*value.Userdata); ok {
+		if f, ok := u.Value.(*file); ok && !f.closed && f.closer != nil {
+			_ = f.close()
+		}
+	}
+	return nil, nil
+}
+
+func (e *Engine) fileTostring(args ...value.Value) ([]value.Value, error) {
+	if u, ok := args[0].(*value.Userdata); ok {
+		if f, ok := u.Value.(*file); ok && f.closed {
+			return values(value.NewString("file (closed)")), nil
+		}
+	}
+	f, err := e.toFile("__tostring", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewString(fmt.Sprintf("file (%p)", f))), nil
+}
+
+// write writes the given values, which must be strings or numbers, to the
+// given file. On success, the userdata of the file is returned. n is the
+// 1-based index of the first value in the arguments of the function.
+func (e *Engine) write(f *file, u value.Value, fnName string, args []value.Value, n int) ([]value.Value, error) {
+	for i := range args {
+		s, err := checkString(fnName, args, i+1)
+		if err != nil {
+			return nil, argError(i+n, fnName, fmt.Sprintf("string expected, got %s", args[i].Type().Name()))
+		}
+		if err := f.write(s); err != nil {
+			return fileResult(err, ""), nil
+		}
+	}
+	return values(u), nil
+}
+
+// read reads from the given file with the given formats, and returns one
+// value per format. If a format can't be read, nil is returned for it, and
+// no more formats are read. Without formats, a line is read. n is the
+// 1-based index of the first format in the arguments of the function.
+func (e *Engine) read(f *file, fnName string, formats []value.Value, n int) ([]value.Value, error) {
+	r, err := f.reader()
+	if err != nil {
+		return fileResult(err, ""), nil
+	}
+	if len(formats) == 0 {
+		formats = values(value.NewString("l"))
+	}
+
+	var results []value.Value
+	for i, format := range formats {
+		var result value.Value
+		var err error
+		if count, ok := toInteger(format); ok {
+			result, err = readCount(r, count)
+		} else {
+			s, ok := format.(value.String)
+			if !ok {
+				return nil, argError(i+n, fnName, "invalid format")
+			}
+			switch strings.TrimPrefix(string(s), "*") {
+			case "n":
+				result, err = readNumber(r)
+			case "l":
+				result, err = readLine(r, false)
+			case "L":
+				result, err = readLine(r, true)
+			case "a":
+				result, err = readAll(r)
+			default:
+				return nil, argError(i+n, fnName, "invalid format")
+			}
+		}
+		if err != nil {
+			return fileResult(err, ""), nil
+		}
+		results = append(results, result)
+		if result == value.Nil {
+			break
+		}
+	}
+	return results, nil
+}
+
+// readCount reads up to count bytes. If the end of the file is reached before
+// anything was read, nil is returned. If count is 0, an empty string is
+// returned, unless the end of the file was reached.
+func readCount(r *bufio.Reader, count int64) (value.Value, error) {
+	if count <= 0 {
+		if _, err := r.Peek(1); err == io.EOF {
+			return value.Nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return value.NewString(""), nil
+	}
+
+	var buf strings.Builder
+	_, err := io.CopyN(&buf, r, count)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if buf.Len() == 0 {
+		return value.Nil, nil
+	}
+	return value.NewString(buf.String()), nil
+}
+
+// readLine reads the next line. The newline is only kept if keepNewline is
+// set. If the end of the file is reached before anything was read, nil is
+// returned.
+func readLine(r *bufio.Reader, keepNewline bool) (value.Value, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err == io.EOF && line == "" {
+		return value.Nil, nil
+	}
+	if !keepNewline {
+		line = strings.TrimSuffix(line, "\n")
+	}
+	return value.NewString(line), nil
+}
+
+// readAll reads the rest of the file. Other than the other formats, this
+// never returns nil, but an empty string at the end of the file.
+func readAll(r *bufio.Reader) (value.Value, error) {
+	var buf strings.Builder
+	if _, err := io.Copy(&buf, r); err != nil {
+		return nil, err
+	}
+	return value.NewString(buf.String()), nil
+}
+
+// readNumber reads a numeral, and returns it as number. Like in the reference
+// implementation, the longest prefix that could be the start of a numeral is
+// read, and nil is returned if that prefix is not a valid numeral.
+func readNumber(r *bufio.Reader) (value.Value, error) {
+	var buf strings.Builder
+	// test reads the next byte and appends it to the numeral, if it is one of
+	// the given bytes
+	test := func(set string) bool {
+		c, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		if strings.IndexByte(set, c) < 0 {
+			_ = r.UnreadByte()
+			return false
+		}
+		buf.WriteByte(c)
+		return true
+	}
+	readDigits := func(hex bool) int {
+		digits := "0123456789"
+		if hex {
+			digits += "abcdefABCDEF"
+		}
+		count := 0
+		for test(digits) {
+			count++
+		}
+		return count
+	}
+
+	// skip leading whitespace
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return value.Nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !strings.ContainsRune(" \t\n\v\f\r", rune(c)) {
+			_ = r.UnreadByte()
+			break
+		}
+	}
+
+	test("-+")
+	count := 0
+	hex := false
+	if test("0") {
+		if test("xX") {
+			hex = true
+		} else {
+			count = 1
+		}
+	}
+	count += readDigits(hex)
+	if test(".") {
+		count += readDigits(hex)
+	}
+	if count > 0 {
+		exp := "eE"
+		if hex {
+			exp = "pP"
+		}
+		if test(exp) {
+			test("-+")
+			readDigits(false)
+		}
+	}
+
+	if num, ok := value.ParseNumber(buf.String()); ok {
+		return num, nil
+	}
+	return value.Nil, nil
+}
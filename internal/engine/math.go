@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"math"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// floatToNumber converts the given float to an integer if it has an exact
+// integer representation, and returns the float otherwise.
+func floatToNumber(f float64) value.Value {
+	if i, ok := value.FloatToInteger(f); ok {
+		return value.NewInteger(i)
+	}
+	return value.NewNumber(f)
+}
+
+func (e *Engine) mathAbs(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumberValue("abs", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := x.(value.Integer); ok {
+		if i < 0 {
+			i = -i // wraps around for the minimum integer
+		}
+		return values(i), nil
+	}
+	return values(value.NewNumber(math.Abs(float64(x.(value.Number))))), nil
+}
+
+func (e *Engine) mathCeil(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumberValue("ceil", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := x.(value.Integer); ok {
+		return values(i), nil
+	}
+	return values(floatToNumber(math.Ceil(float64(x.(value.Number))))), nil
+}
+
+func (e *Engine) mathFloor(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumberValue("floor", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := x.(value.Integer); ok {
+		return values(i), nil
+	}
+	return values(floatToNumber(math.Floor(float64(x.(value.Number))))), nil
+}
+
+func (e *Engine) mathFmod(args ...value.Value) ([]value.Value, error) {
+	a, err := checkNumberValue("fmod", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := checkNumberValue("fmod", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := a.(value.Integer); ok {
+		if d, ok := b.(value.Integer); ok {
+			switch d {
+			case 0:
+				return nil, argError(2, "fmod", "zero")
+			case -1:
+				return values(value.NewInteger(0)), nil // avoid overflow with the minimum integer
+			}
+			return values(m % d), nil // truncating remainder, like C's '%'
+		}
+	}
+	x, _ := value.ToFloat(a)
+	y, _ := value.ToFloat(b)
+	return values(value.NewNumber(math.Mod(x, y))), nil
+}
+
+func (e *Engine) mathModf(args ...value.Value) ([]value.Value, error) {
+	if len(args) > 0 {
+		if i, ok := args[0].(value.Integer); ok {
+			// an integer is its own integer part
+			return values(i, value.NewNumber(0)), nil
+		}
+	}
+	x, err := checkNumber("modf", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	// integer part, rounded towards zero
+	ip := math.Floor(x)
+	if x < 0 {
+		ip = math.Ceil(x)
+	}
+	frac := 0.0
+	if x != ip { // test needed for inf and -inf
+		frac = x - ip
+	}
+	return values(value.NewNumber(ip), value.NewNumber(frac)), nil
+}
+
+func (e *Engine) mathSqrt(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumber("sqrt", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewNumber(math.Sqrt(x))), nil
+}
+
+func (e *Engine) mathExp(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumber("exp", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewNumber(math.Exp(x))), nil
+}
+
+func (e *Engine) mathLog(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumber("log", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if isNoneOrNil(args, 2) {
+		return values(value.NewNumber(math.Log(x))), nil
+	}
+	base, err := checkNumber("log", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	var res float64
+	switch base {
+	case 2:
+		res = math.Log2(x)
+	case 10:
+		res = math.Log10(x)
+	default:
+		res = math.Log(x) / math.Log(base)
+	}
+	return values(value.NewNumber(res)), nil
+}
+
+func (e *Engine) mathSin(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumber("sin", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewNumber(math.Sin(x))), nil
+}
+
+func (e *Engine) mathCos(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumber("cos", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewNumber(math.Cos(x))), nil
+}
+
+func (e *Engine) mathTan(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumber("tan", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewNumber(math.Tan(x))), nil
+}
+
+func (e *Engine) mathAsin(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumber("asin", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewNumber(math.Asin(x))), nil
+}
+
+func (e *Engine) mathAcos(args ...value.Value) ([]value.Value, error) {
+	x, err := checkNumber("acos", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewNumber(math.Acos(x))), nil
+}
+
+func (e *Engine) mathAtan(args ...value.Value) ([]value.Value, error) {
+	y, err := checkNumber("atan", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	x := 1.0
+	if !isNoneOrNil(args, 2) {
+		if x, err = checkNumber("atan", args, 2); err != nil {
+			return nil, err
+		}
+	}
+	return values(value.NewNumber(math.Atan2(y, x))), nil
+}
+
+func (e *Engine) mathMax(args ...value.Value) ([]value.Value, error) {
+	return e.mathMinMax("max", args, func(x, max value.Value) bool { return numLess(max, x) })
+}
+
+func (e *Engine) mathMin(args ...value.Value) ([]value.Value, error) {
+	return e.mathMinMax("min", args, func(x, min value.Value) bool { return numLess(x, min) })
+}
+
+// mathMinMax returns the argument, for which better reports true when
+// compared with every other argument.
+func (e *Engine) mathMinMax(fnName string, args []value.Value, better func(x, current value.Value) bool) ([]value.Value, error) {
+	if len(args) < 1 {
+		return nil, argError(1, fnName, "value expected")
+	}
+	res, err := checkNumberValue(fnName, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for i := 2; i <= len(args); i++ {
+		x, err := checkNumberValue(fnName, args, i)
+		if err != nil {
+			return nil, err
+		}
+		if better(x, res) {
+			res = x
+		}
+	}
+	return values(res), nil
+}
+
+func (e *Engine) mathTointeger(args ...value.Value) ([]value.Value, error) {
+	if len(args) < 1 {
+		return nil, argError(1, "tointeger", "value expected")
+	}
+	if i, ok := toInteger(args[0]); ok {
+		return values(value.NewInteger(i)), nil
+	}
+	return values(value.Nil), nil
+}
+
+func (e *Engine) mathType(args ...value.Value) ([]value.Value, error) {
+	if len(args) < 1 {
+		return nil, argError(1, "type", "value expected")
+	}
+	switch args[0].(type) {
+	case value.Integer:
+		return values(value.NewString("integer")), nil
+	case value.Number:
+		return values(value.NewString("float")), nil
+	}
+	return values(value.Nil), nil
+}
+
+func (e *Engine) mathUlt(args ...value.Value) ([]value.Value, error) {
+	m, err := checkInteger("ult", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	n, err := checkInteger("ult", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(m) < uint64(n) {
+		return values(value.True), nil
+	}
+	return values(value.False), nil
+}
+
+func (e *Engine) mathRandom(args ...value.Value) ([]value.Value, error) {
+	r := e.random.Float64() // in [0, 1)
+
+	var low, up int64
+	var err error
+	switch len(args) {
+	case 0:
+		return values(value.NewNumber(r)), nil
+	case 1:
+		low = 1
+		if up, err = checkInteger("random", args, 1); err != nil {
+			return nil, err
+		}
+	case 2:
+		if low, err = checkInteger("random", args, 1); err != nil {
+			return nil, err
+		}
+		if up, err = checkInteger("random", args, 2); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, Error{Message: value.NewString("wrong number of arguments")}
+	}
+
+	if low > up {
+		return nil, argError(len(args), "random", "interval is empty")
+	}
+	if !(low >= 0 || up <= math.MaxInt64+low) {
+		return nil, argError(1, "random", "interval too large")
+	}
+	// project the random float into the interval
+	r *= float64(up-low) + 1
+	return values(value.NewInteger(int64(r) + low)), nil
+}
+
+func (e *Engine) mathRandomseed(args ...value.Value) ([]value.Value, error) {
+	seed, err := checkNumber("randomseed", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	e.random.Seed(int64(seed))
+	return nil, nil
+}
@@ -15,8 +15,13 @@ type metaTables struct {
 	ThreadMetaTable   *value.Table
 }
 
+// initMetatables creates the metatables that are shared by all values of a type.
+// It must be called after the standard library was initialized.
 func (e *Engine) initMetatables() {
+	// methods can be called on strings, e.g. ("x"):upper()
+	stringLib, _ := e._G.Get(value.NewString("string"))
 	e.metaTables.StringMetaTable = value.NewTable()
+	e.metaTables.StringMetaTable.Set(value.NewString("__index"), stringLib)
 }
 
 func (t metaTables) Table(typ value.Type) *value.Table {
@@ -66,14 +71,15 @@ func (e *Engine) metaMethod(object value.Value, event string) (value.Value, erro
 
 	switch object.Type() {
 	case value.TypeTable:
-		results, err := e.getmetatable(object)
-		if err != nil {
-			return nil, fmt.Errorf("getmetatable: %w", err)
-		}
-		if results[0] == nil || results[0] == value.Nil {
-			return nil, nil
+		metaTable = object.(*value.Table).Metatable
+	case value.TypeUserdata:
+		// full userdata have their own metatable, light userdata share
+		// the metatable of their type
+		if u, ok := object.(*value.Userdata); ok {
+			metaTable = u.Metatable
+		} else {
+			metaTable = e.metaTables.Table(object.Type())
 		}
-		metaTable = results[0].(*value.Table)
 	default:
 		metaTable = e.metaTables.Table(object.Type())
 	}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"io"
+	"math/rand"
 
 	"github.com/spf13/afero"
 )
@@ -38,6 +39,33 @@ func WithClock(clock Clock) Option {
 	}
 }
 
+// WithRandomSource sets the source of the pseudo-random numbers that
+// math.random generates. math.randomseed seeds this source. By default,
+// a source that is seeded with the current time of the engine's clock
+// is used.
+func WithRandomSource(src rand.Source) Option {
+	return func(e *Engine) {
+		e.random = rand.New(src)
+	}
+}
+
+// WithEnv sets the environment variables, that os.getenv looks up. By
+// default, the environment variables of the process are used.
+func WithEnv(env Env) Option {
+	return func(e *Engine) {
+		e.env = env
+	}
+}
+
+// WithExit sets the function that os.exit calls with the exit code. By
+// default, this is os.Exit. If the function returns, the evaluation is
+// aborted with an ExitError.
+func WithExit(exit func(code int)) Option {
+	return func(e *Engine) {
+		e.exit = exit
+	}
+}
+
 func WithMaxStackSize(maxSize int) Option {
 	return func(e *Engine) {
 		e.stack.maxSize = maxSize
@@ -0,0 +1,376 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/spf13/afero"
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// ExitError is returned by the evaluation, if os.exit was called and the
+// exit function of the engine returned.
+type ExitError struct {
+	Code int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func (e *Engine) osClock(args ...value.Value) ([]value.Value, error) {
+	// there is no processor time that can be attributed to an engine, so
+	// the time that has passed since the engine was created is used
+	return values(value.NewNumber(e.clock.Now().Sub(e.started).Seconds())), nil
+}
+
+func (e *Engine) osDate(args ...value.Value) ([]value.Value, error) {
+	format, err := optString("date", args, 1, "%c")
+	if err != nil {
+		return nil, err
+	}
+	t := e.clock.Now()
+	if !isNoneOrNil(args, 2) {
+		sec, err := checkInteger("date", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		t = time.Unix(sec, 0).In(t.Location())
+	}
+
+	// a leading '!' formats the time in UTC
+	if strings.HasPrefix(format, "!") {
+		format = format[1:]
+		t = t.UTC()
+	}
+
+	if strings.HasPrefix(format, "*t") {
+		date := value.NewTableSize(0, 9)
+		setDateFields(date, t)
+		return values(date), nil
+	}
+
+	s, err := strftime(format, t)
+	if err != nil {
+		return nil, argError(1, "date", err.Error())
+	}
+	return values(value.NewString(s)), nil
+}
+
+func (e *Engine) osDifftime(args ...value.Value) ([]value.Value, error) {
+	t2, err := checkNumber("difftime", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	t1, err := optNumber("difftime", args, 2, 0)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewNumber(t2 - t1)), nil
+}
+
+func (e *Engine) osExit(args ...value.Value) ([]value.Value, error) {
+	code := 0
+	if len(args) > 0 && (args[0] == value.True || args[0] == value.False) {
+		if args[0] == value.False {
+			code = 1
+		}
+	} else {
+		c, err := optInteger("exit", args, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		code = int(c)
+	}
+
+	e.exit(code)
+	return nil, ExitError{
+		Code: code,
+	}
+}
+
+func (e *Engine) osGetenv(args ...value.Value) ([]value.Value, error) {
+	key, err := checkString("getenv", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if val, ok := e.env.LookupEnv(key); ok {
+		return values(value.NewString(val)), nil
+	}
+	return values(value.Nil), nil
+}
+
+func (e *Engine) osRemove(args ...value.Value) ([]value.Value, error) {
+	filename, err := checkString("remove", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return fileResult(e.fs.Remove(filename), filename), nil
+}
+
+func (e *Engine) osRename(args ...value.Value) ([]value.Value, error) {
+	oldname, err := checkString("rename", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	newname, err := checkString("rename", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return fileResult(e.fs.Rename(oldname, newname), oldname), nil
+}
+
+func (e *Engine) osTime(args ...value.Value) ([]value.Value, error) {
+	if isNoneOrNil(args, 1) {
+		return values(value.NewInteger(e.clock.Now().Unix())), nil
+	}
+	date, ok := args[0].(*value.Table)
+	if !ok {
+		return nil, typeArgError(1, "time", "table", args)
+	}
+
+	// the fields are checked in the same order as in the reference
+	// implementation, so that the same field is reported if several
+	// fields are missing
+	var fields [6]int
+	for i, field := range []struct {
+		name string
+		def  int // negative if the field is required
+	}{
+		{"sec", 0},
+		{"min", 0},
+		{"hour", 12},
+		{"day", -1},
+		{"month", -1},
+		{"year", -1},
+	} {
+		val, _ := date.Get(value.NewString(field.name))
+		n, ok := toInteger(val)
+		switch {
+		case ok:
+			fields[i] = int(n)
+		case !e.isNil(val):
+			return e.error(value.NewString(fmt.Sprintf("field '%s' is not an integer", field.name)))
+		case field.def < 0:
+			return e.error(value.NewString(fmt.Sprintf("field '%s' missing in date table", field.name)))
+		default:
+			fields[i] = field.def
+		}
+	}
+
+	// like mktime, time.Date normalizes fields that are out of range, and
+	// the table is updated with the normalized fields
+	t := time.Date(fields[5], time.Month(fields[4]), fields[3], fields[2], fields[1], fields[0], 0, e.clock.Now().Location())
+	setDateFields(date, t)
+	return values(value.NewInteger(t.Unix())), nil
+}
+
+func (e *Engine) osTmpname(args ...value.Value) ([]value.Value, error) {
+	dir := os.TempDir()
+	if err := e.fs.MkdirAll(dir, 0700); err != nil {
+		return e.error(value.NewString("unable to generate a unique filename"))
+	}
+	file, err := afero.TempFile(e.fs, dir, "lua_")
+	if err != nil {
+		return e.error(value.NewString("unable to generate a unique filename"))
+	}
+	_ = file.Close()
+	return values(value.NewString(file.Name())), nil
+}
+
+// setDateFields sets the fields of a date table, as os.date("*t") returns
+// it, to the given time.
+func setDateFields(date *value.Table, t time.Time) {
+	for name, val := range map[string]value.Value{
+		"year":  value.NewInteger(int64(t.Year())),
+		"month": value.NewInteger(int64(t.Month())),
+		"day":   value.NewInteger(int64(t.Day())),
+		"hour":  value.NewInteger(int64(t.Hour())),
+		"min":   value.NewInteger(int64(t.Minute())),
+		"sec":   value.NewInteger(int64(t.Second())),
+		"wday":  value.NewInteger(int64(t.Weekday()) + 1),
+		"yday":  value.NewInteger(int64(t.YearDay())),
+		"isdst": value.Boolean(isDST(t)),
+	} {
+		date.Set(value.NewString(name), val)
+	}
+}
+
+// isDST reports whether daylight saving time is in effect at the given
+// time, which is the case if the offset of the time zone is larger than
+// the smaller one of the offsets in January and July.
+func isDST(t time.Time) bool {
+	_, offset := t.Zone()
+	_, jan := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location()).Zone()
+	_, jul := time.Date(t.Year(), time.July, 1, 0, 0, 0, 0, t.Location()).Zone()
+	if jul < jan {
+		jan = jul
+	}
+	return offset > jan
+}
+
+// strftime formats the given time like the C function strftime does in the
+// C locale. The conversions of C99 are supported, including the E and O
+// modifiers, which have no effect in the C locale.
+func strftime(format string, t time.Time) (string, error) {
+	var buf strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			buf.WriteByte(format[i])
+			continue
+		}
+
+		rest := format[i+1:]
+		conv := rest
+		if len(conv) >= 2 && ((conv[0] == 'E' && strings.IndexByte("cCxXyY", conv[1]) >= 0) ||
+			(conv[0] == 'O' && strings.IndexByte("deHImMSuUVwWy", conv[1]) >= 0)) {
+			conv = conv[1:]
+		}
+		if conv == "" {
+			return "", fmt.Errorf("invalid conversion specifier '%%%s'", rest)
+		}
+		s, ok := strftimeConversion(conv[0], t)
+		if !ok {
+			return "", fmt.Errorf("invalid conversion specifier '%%%s'", rest)
+		}
+		buf.WriteString(s)
+		i += len(rest) - len(conv) + 1
+	}
+	return buf.String(), nil
+}
+
+func strftimeConversion(conv byte, t time.Time) (string, bool) {
+	switch conv {
+	case 'a':
+		return t.Format("Mon"), true
+	case 'A':
+		return t.Format("Monday"), true
+	case 'b', 'h':
+		return t.Format("Jan"), true
+	case 'B':
+		return t.Format("January"), true
+	case 'c':
+		return t.Format("Mon Jan _2 15:04:05 2006"), true
+	case 'C':
+		return fmt.Sprintf("%02d", t.Year()/100), true
+	case 'd':
+		return fmt.Sprintf("%02d", t.Day()), true
+	case 'D':
+		return t.Format("01/02/06"), true
+	case 'e':
+		return fmt.Sprintf("%2d", t.Day()), true
+	case 'F':
+		return fmt.Sprintf("%d-%02d-%02d", t.Year(), t.Month(), t.Day()), true
+	case 'g':
+		year, _ := t.ISOWeek()
+		return fmt.Sprintf("%02d", year%100), true
+	case 'G':
+		year, _ := t.ISOWeek()
+		return fmt.Sprintf("%d", year), true
+	case 'H':
+		return fmt.Sprintf("%02d", t.Hour()), true
+	case 'I':
+		return t.Format("03"), true
+	case 'j':
+		return fmt.Sprintf("%03d", t.YearDay()), true
+	case 'm':
+		return fmt.Sprintf("%02d", t.Month()), true
+	case 'M':
+		return fmt.Sprintf("%02d", t.Minute()), true
+	case 'n':
+		return "\n", true
+	case 'p':
+		return t.Format("PM"), true
+	case 'r':
+		return t.Format("03:04:05 PM"), true
+	case 'R':
+		return t.Format("15:04"), true
+	case 'S':
+		return fmt.Sprintf("%02d", t.Second()), true
+	case 't':
+		return "\t", true
+	case 'T', 'X':
+		return t.Format("15:04:05"), true
+	case 'u':
+		wday := int(t.Weekday())
+		if wday == 0 {
+			wday = 7
+		}
+		return fmt.Sprintf("%d", wday), true
+	case 'U':
+		// weeks start on sunday, days before the first sunday are in week 0
+		return fmt.Sprintf("%02d", (t.YearDay()-1+7-int(t.Weekday()))/7), true
+	case 'V':
+		_, week := t.ISOWeek()
+		return fmt.Sprintf("%02d", week), true
+	case 'w':
+		return fmt.Sprintf("%d", t.Weekday()), true
+	case 'W':
+		// weeks start on monday, days before the first monday are in week 0
+		return fmt.Sprintf("%02d", (t.YearDay()-1+7-(int(t.Weekday())+6)%7)/7), true
+	case 'x':
+		return t.Format("01/02/06"), true
+	case 'y':
+		return fmt.Sprintf("%02d", t.Year()%100), true
+	case 'Y':
+		return fmt.Sprintf("%d", t.Year()), true
+	case 'z':
+		return t.Format("-0700"), true
+	case 'Z':
+		return t.Format("MST"), true
+	case '%':
+		return "%", true
+	}
+	return "", false
+}
+
+// fileResult converts the result of a file operation into the values that
+// Lua's library functions return: true if the operation succeeded, and nil,
+// an error message and an error number otherwise.
+func fileResult(err error, filename string) []value.Value {
+	if err == nil {
+		return values(value.True)
+	}
+	msg, errno := errorMessage(err)
+	if filename != "" {
+		msg = filename + ": " + msg
+	}
+	return values(value.Nil, value.NewString(msg), value.NewInteger(int64(errno)))
+}
+
+// errorMessage returns a message and an error number for the given error of
+// a file operation, which match the ones of the C library where possible.
+func errorMessage(err error) (string, int) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "No such file or directory", 2
+	case errors.Is(err, os.ErrPermission):
+		return "Permission denied", 13
+	case errors.Is(err, os.ErrExist):
+		return "File exists", 17
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return capitalize(errno.Error()), int(errno)
+	}
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error(), 0
+	}
+	return err.Error(), 0
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
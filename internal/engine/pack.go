@@ -0,0 +1,457 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"unsafe"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+const (
+	// packMaxIntSize is the maximum size of an integer in a pack format.
+	packMaxIntSize = 16
+	// packIntSize is the size of a Lua integer.
+	packIntSize = 8
+	// packMaxAlign is the native maximum alignment, used by '!' without size.
+	packMaxAlign = 8
+	// packMaxSize is the maximum size of a packed result.
+	packMaxSize = math.MaxInt32
+)
+
+type packOption uint8
+
+const (
+	packInt       packOption = iota // signed integers
+	packUint                        // unsigned integers
+	packFloat                       // floating-point numbers
+	packChar                        // fixed-length strings
+	packString                      // strings with prefixed length
+	packZstr                        // zero-terminated strings
+	packPadding                     // padding
+	packPaddAlign                   // padding for alignment
+	packNop                         // no-op (configuration or spaces)
+)
+
+// packFormat reads the options of a format string, as used by string.pack,
+// string.unpack and string.packsize.
+type packFormat struct {
+	fnName string
+	format string
+	pos    int
+
+	little   bool
+	maxAlign int
+}
+
+func newPackFormat(fnName, format string) *packFormat {
+	return &packFormat{
+		fnName:   fnName,
+		format:   format,
+		little:   nativeLittleEndian(),
+		maxAlign: 1,
+	}
+}
+
+func nativeLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
+
+func (f *packFormat) done() bool {
+	return f.pos >= len(f.format)
+}
+
+func (f *packFormat) isDigit() bool {
+	return !f.done() && f.format[f.pos] >= '0' && f.format[f.pos] <= '9'
+}
+
+// num reads a number from the format, or returns def if there is none.
+func (f *packFormat) num(def int) int {
+	if !f.isDigit() {
+		return def
+	}
+	a := 0
+	for f.isDigit() && a <= (packMaxSize-9)/10 {
+		a = a*10 + int(f.format[f.pos]-'0')
+		f.pos++
+	}
+	return a
+}
+
+// numLimit reads the size of an integer from the format, or returns def if
+// there is none.
+func (f *packFormat) numLimit(def int) (int, error) {
+	size := f.num(def)
+	if size > packMaxIntSize || size <= 0 {
+		return 0, Error{Message: value.NewString(fmt.Sprintf("integral size (%d) out of limits [1,%d]", size, packMaxIntSize))}
+	}
+	return size, nil
+}
+
+// option reads the next option from the format, and returns it together with
+// its size.
+func (f *packFormat) option() (packOption, int, error) {
+	opt := f.format[f.pos]
+	f.pos++
+	switch opt {
+	case 'b':
+		return packInt, 1, nil
+	case 'B':
+		return packUint, 1, nil
+	case 'h':
+		return packInt, 2, nil
+	case 'H':
+		return packUint, 2, nil
+	case 'l', 'j':
+		return packInt, 8, nil
+	case 'L', 'J', 'T':
+		return packUint, 8, nil
+	case 'f':
+		return packFloat, 4, nil
+	case 'd', 'n':
+		return packFloat, 8, nil
+	case 'i':
+		size, err := f.numLimit(4)
+		return packInt, size, err
+	case 'I':
+		size, err := f.numLimit(4)
+		return packUint, size, err
+	case 's':
+		size, err := f.numLimit(8)
+		return packString, size, err
+	case 'c':
+		size := f.num(-1)
+		if size == -1 {
+			return 0, 0, Error{Message: value.NewString("missing size for format option 'c'")}
+		}
+		return packChar, size, nil
+	case 'z':
+		return packZstr, 0, nil
+	case 'x':
+		return packPadding, 1, nil
+	case 'X':
+		return packPaddAlign, 0, nil
+	case ' ':
+	case '<':
+		f.little = true
+	case '>':
+		f.little = false
+	case '=':
+		f.little = nativeLittleEndian()
+	case '!':
+		align, err := f.numLimit(packMaxAlign)
+		if err != nil {
+			return 0, 0, err
+		}
+		f.maxAlign = align
+	default:
+		return 0, 0, Error{Message: value.NewString(fmt.Sprintf("invalid format option '%c'", opt))}
+	}
+	return packNop, 0, nil
+}
+
+// details reads the next option from the format, and returns it together with
+// its size and the amount of padding bytes that are needed to align it, given
+// that totalSize bytes were already processed.
+func (f *packFormat) details(totalSize int) (opt packOption, size, toAlign int, err error) {
+	opt, size, err = f.option()
+	if err != nil {
+		return
+	}
+
+	// usually, alignment follows size
+	align := size
+	if opt == packPaddAlign {
+		// 'X' gets alignment from the following option, which is
+		// otherwise ignored
+		if f.done() {
+			err = argError(1, f.fnName, "invalid next option for option 'X'")
+			return
+		}
+		var next packOption
+		next, align, err = f.option()
+		if err != nil {
+			return
+		}
+		if next == packChar || align == 0 {
+			err = argError(1, f.fnName, "invalid next option for option 'X'")
+			return
+		}
+	}
+
+	if align <= 1 || opt == packChar {
+		return
+	}
+	if align > f.maxAlign {
+		// enforce maximum alignment
+		align = f.maxAlign
+	}
+	if align&(align-1) != 0 {
+		err = argError(1, f.fnName, "format asks for alignment not power of 2")
+		return
+	}
+	toAlign = (align - (totalSize & (align - 1))) & (align - 1)
+	return
+}
+
+// packInteger appends the size lowest bytes of n to the buffer. If the size is
+// larger than the size of a Lua integer, negative numbers are sign extended.
+func packInteger(buf *bytes.Buffer, n uint64, little bool, size int, negative bool) {
+	b := make([]byte, size)
+	for i := 0; i < size; i++ {
+		var c byte
+		if i < packIntSize {
+			c = byte(n >> (8 * uint(i)))
+		} else if negative {
+			c = 0xff
+		}
+		if little {
+			b[i] = c
+		} else {
+			b[size-1-i] = c
+		}
+	}
+	buf.Write(b)
+}
+
+func unpackInteger(data string, little bool, size int, signed bool) (int64, error) {
+	at := func(i int) byte {
+		if little {
+			return data[i]
+		}
+		return data[size-1-i]
+	}
+
+	limit := size
+	if limit > packIntSize {
+		limit = packIntSize
+	}
+	var res uint64
+	for i := limit - 1; i >= 0; i-- {
+		res <<= 8
+		res |= uint64(at(i))
+	}
+
+	if size < packIntSize {
+		if signed {
+			// sign extension
+			mask := uint64(1) << (uint(size)*8 - 1)
+			res = (res ^ mask) - mask
+		}
+	} else if size > packIntSize {
+		// the unread bytes must only be sign extension
+		var mask byte
+		if signed && int64(res) < 0 {
+			mask = 0xff
+		}
+		for i := limit; i < size; i++ {
+			if at(i) != mask {
+				return 0, Error{Message: value.NewString(fmt.Sprintf("%d-byte integer does not fit into Lua Integer", size))}
+			}
+		}
+	}
+	return int64(res), nil
+}
+
+func (e *Engine) stringPack(args ...value.Value) ([]value.Value, error) {
+	format, err := checkString("pack", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newPackFormat("pack", format)
+	var buf bytes.Buffer
+	arg := 1
+	totalSize := 0
+	for !f.done() {
+		opt, size, toAlign, err := f.details(totalSize)
+		if err != nil {
+			return nil, err
+		}
+		totalSize += toAlign + size
+		for ; toAlign > 0; toAlign-- {
+			buf.WriteByte(0)
+		}
+
+		arg++
+		switch opt {
+		case packInt:
+			n, err := checkInteger("pack", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			if size < packIntSize {
+				lim := int64(1) << (uint(size)*8 - 1)
+				if n < -lim || n >= lim {
+					return nil, argError(arg, "pack", "integer overflow")
+				}
+			}
+			packInteger(&buf, uint64(n), f.little, size, n < 0)
+		case packUint:
+			n, err := checkInteger("pack", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			if size < packIntSize && uint64(n) >= uint64(1)<<(uint(size)*8) {
+				return nil, argError(arg, "pack", "unsigned overflow")
+			}
+			packInteger(&buf, uint64(n), f.little, size, false)
+		case packFloat:
+			n, err := checkNumber("pack", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			var order binary.ByteOrder = binary.BigEndian
+			if f.little {
+				order = binary.LittleEndian
+			}
+			if size == 4 {
+				_ = binary.Write(&buf, order, float32(n))
+			} else {
+				_ = binary.Write(&buf, order, n)
+			}
+		case packChar:
+			s, err := checkString("pack", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(s) > size {
+				return nil, argError(arg, "pack", "string longer than given size")
+			}
+			buf.WriteString(s)
+			buf.Write(make([]byte, size-len(s)))
+		case packString:
+			s, err := checkString("pack", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			if size < packIntSize && uint64(len(s)) >= uint64(1)<<(uint(size)*8) {
+				return nil, argError(arg, "pack", "string length does not fit in given size")
+			}
+			packInteger(&buf, uint64(len(s)), f.little, size, false)
+			buf.WriteString(s)
+			totalSize += len(s)
+		case packZstr:
+			s, err := checkString("pack", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			if strings.IndexByte(s, 0) != -1 {
+				return nil, argError(arg, "pack", "string contains zeros")
+			}
+			buf.WriteString(s)
+			buf.WriteByte(0)
+			totalSize += len(s) + 1
+		case packPadding:
+			buf.WriteByte(0)
+			arg--
+		case packPaddAlign, packNop:
+			arg--
+		}
+	}
+	return values(value.NewString(buf.String())), nil
+}
+
+func (e *Engine) stringPacksize(args ...value.Value) ([]value.Value, error) {
+	format, err := checkString("packsize", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newPackFormat("packsize", format)
+	totalSize := 0
+	for !f.done() {
+		opt, size, toAlign, err := f.details(totalSize)
+		if err != nil {
+			return nil, err
+		}
+		size += toAlign
+		if totalSize > packMaxSize-size {
+			return nil, argError(1, "packsize", "format result too large")
+		}
+		totalSize += size
+		if opt == packString || opt == packZstr {
+			return nil, argError(1, "packsize", "variable-length format")
+		}
+	}
+	return values(value.NewInteger(int64(totalSize))), nil
+}
+
+func (e *Engine) stringUnpack(args ...value.Value) ([]value.Value, error) {
+	format, err := checkString("unpack", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := checkString("unpack", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	init, err := optInteger("unpack", args, 3, 1)
+	if err != nil {
+		return nil, err
+	}
+	pos := int(posrelat(init, len(data))) - 1
+	if pos < 0 || pos > len(data) {
+		return nil, argError(3, "unpack", "initial position out of string")
+	}
+
+	f := newPackFormat("unpack", format)
+	var results []value.Value
+	for !f.done() {
+		opt, size, toAlign, err := f.details(pos)
+		if err != nil {
+			return nil, err
+		}
+		if pos+toAlign+size > len(data) {
+			return nil, argError(2, "unpack", "data string too short")
+		}
+		// skip alignment
+		pos += toAlign
+
+		switch opt {
+		case packInt, packUint:
+			n, err := unpackInteger(data[pos:pos+size], f.little, size, opt == packInt)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, value.NewInteger(n))
+		case packFloat:
+			var order binary.ByteOrder = binary.BigEndian
+			if f.little {
+				order = binary.LittleEndian
+			}
+			if size == 4 {
+				results = append(results, value.NewNumber(float64(math.Float32frombits(order.Uint32([]byte(data[pos:]))))))
+			} else {
+				results = append(results, value.NewNumber(math.Float64frombits(order.Uint64([]byte(data[pos:])))))
+			}
+		case packChar:
+			results = append(results, value.NewString(data[pos:pos+size]))
+		case packString:
+			n, err := unpackInteger(data[pos:pos+size], f.little, size, false)
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 || uint64(n) > uint64(len(data)-pos-size) {
+				return nil, argError(2, "unpack", "data string too short")
+			}
+			length := int(n)
+			results = append(results, value.NewString(data[pos+size:pos+size+length]))
+			pos += length
+		case packZstr:
+			length := strings.IndexByte(data[pos:], 0)
+			if length == -1 {
+				return nil, argError(2, "unpack", "unfinished string for format 'z'")
+			}
+			results = append(results, value.NewString(data[pos:pos+length]))
+			pos += length + 1
+		}
+		pos += size
+	}
+	// next position
+	return append(results, value.NewInteger(int64(pos+1))), nil
+}
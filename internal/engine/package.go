@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// defaultPath is the initial value of package.path. The paths are relative
+// to the root of the engine's file system.
+const defaultPath = "./?.lua;./?/init.lua"
+
+// initPackage creates the package library. Since the libraries that are
+// registered afterwards are added to package.loaded, this has to happen
+// before any other library is registered.
+func (e *Engine) initPackage() {
+	e.loaded = value.NewTable()
+	e.nativeModules = make(map[string][]*value.Function)
+
+	e.pkg = value.NewTable()
+	e.assign(e.pkg, "config", value.NewString("/\n;\n?\n!\n-\n"))
+	e.assign(e.pkg, "loaded", e.loaded)
+	e.assign(e.pkg, "path", value.NewString(defaultPath))
+	e.assign(e.pkg, "preload", value.NewTable())
+	e.assign(e.pkg, "searchpath", value.NewFunction("searchpath", e.packageSearchpath))
+
+	searchers := value.NewTable()
+	for i, searcher := range []*value.Function{
+		value.NewFunction("searcher_preload", e.searcherPreload),
+		value.NewFunction("searcher_native", e.searcherNative),
+		value.NewFunction("searcher_Lua", e.searcherLua),
+	} {
+		searchers.Set(value.NewInteger(int64(i+1)), searcher)
+	}
+	e.assign(e.pkg, "searchers", searchers)
+
+	e.assign(e._G, "package", e.pkg)
+	e.assign(e.loaded, "package", e.pkg)
+	e.assign(e.loaded, "_G", e._G)
+	e.Register(value.NewFunction("require", e.require))
+}
+
+// RegisterNativeModule makes a module with the given functions as fields
+// available to require. Other than with RegisterModule, the module is not
+// a global variable, and its table is only created when it is required
+// for the first time. Native modules are searched after package.preload,
+// but before the file system.
+func (e *Engine) RegisterNativeModule(name string, fns ...*value.Function) {
+	e.nativeModules[name] = fns
+}
+
+func (e *Engine) require(args ...value.Value) ([]value.Value, error) {
+	name, err := checkString("require", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if module, _ := e.loaded.Get(value.NewString(name)); e.valueIsLogicallyTrue(module) {
+		return values(module), nil
+	}
+
+	loader, extra, err := e.findLoader(name)
+	if err != nil {
+		return nil, err
+	}
+	results, err := e.call(loader, value.NewString(name), extra)
+	if err != nil {
+		return nil, fmt.Errorf("load module %s: %w", name, err)
+	}
+	if len(results) > 0 && !e.isNil(results[0]) {
+		e.assign(e.loaded, name, results[0])
+	}
+	if module, ok := e.loaded.Get(value.NewString(name)); ok {
+		return values(module), nil
+	}
+	// a module that doesn't return a value and doesn't set its
+	// entry in package.loaded is loaded as true
+	e.assign(e.loaded, name, value.True)
+	return values(value.True), nil
+}
+
+// findLoader calls the searchers in package.searchers in order, until one of
+// them returns a loader for the module with the given name. The loader is
+// returned together with the extra value that the searcher returned.
+func (e *Engine) findLoader(name string) (*value.Function, value.Value, error) {
+	searchers, ok := e.field(e.pkg, "searchers").(*value.Table)
+	if !ok {
+		_, err := e.error(value.NewString("'package.searchers' must be a table"))
+		return nil, nil, err
+	}
+
+	var msg strings.Builder
+	for i := int64(1); ; i++ {
+		searcher, _ := searchers.Get(value.NewInteger(i))
+		if e.isNil(searcher) {
+			_, err := e.error(value.NewString(fmt.Sprintf("module '%s' not found:%s", name, msg.String())))
+			return nil, nil, err
+		}
+
+		results, err := e.attemptCall(searcher, value.NewString(name))
+		if err != nil {
+			return nil, nil, fmt.Errorf("call searcher: %w", err)
+		}
+		results = adjust(results, 2)
+		switch result := results[0].(type) {
+		case *value.Function:
+			return result, results[1], nil
+		case value.String:
+			msg.WriteString(string(result))
+		}
+	}
+}
+
+// field returns the value of the field with the given name in the given
+// table, or Nil if there is no such field.
+func (e *Engine) field(table *value.Table, name string) value.Value {
+	val, ok := table.Get(value.NewString(name))
+	if !ok {
+		return value.Nil
+	}
+	return val
+}
+
+func (e *Engine) searcherPreload(args ...value.Value) ([]value.Value, error) {
+	name, err := checkString("searcher_preload", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	preload, ok := e.field(e.pkg, "preload").(*value.Table)
+	if !ok {
+		return e.error(value.NewString("'package.preload' must be a table"))
+	}
+	loader := e.field(preload, name)
+	if e.isNil(loader) {
+		return values(value.NewString(fmt.Sprintf("\n\tno field package.preload['%s']", name))), nil
+	}
+	return values(loader, value.NewString(":preload:")), nil
+}
+
+func (e *Engine) searcherNative(args ...value.Value) ([]value.Value, error) {
+	name, err := checkString("searcher_native", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	fns, ok := e.nativeModules[name]
+	if !ok {
+		return values(value.NewString(fmt.Sprintf("\n\tno native module '%s'", name))), nil
+	}
+
+	loader := value.NewFunction(name, func(...value.Value) ([]value.Value, error) {
+		module := value.NewTable()
+		for _, fn := range fns {
+			e.assign(module, fn.Name, fn)
+		}
+		return values(module), nil
+	})
+	return values(loader, value.NewString(":native:")), nil
+}
+
+func (e *Engine) searcherLua(args ...value.Value) ([]value.Value, error) {
+	name, err := checkString("searcher_Lua", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	path, ok := e.field(e.pkg, "path").(value.String)
+	if !ok {
+		return e.error(value.NewString("'package.path' must be a string"))
+	}
+
+	filename, msg := e.searchPath(name, string(path), ".", "/")
+	if filename == "" {
+		return values(value.NewString(msg)), nil
+	}
+
+	file, err := e.fs.Open(filename)
+	if err != nil {
+		return e.error(value.NewString(fmt.Sprintf("error loading module '%s' from file '%s':\n\tcannot open %s", name, filename, filename)))
+	}
+	defer func() { _ = file.Close() }()
+
+	results, err := e.loadChunk(file, "@"+filename, "bt", nil)
+	if err != nil {
+		return nil, err
+	}
+	if e.isNil(results[0]) {
+		return e.error(value.NewString(fmt.Sprintf("error loading module '%s' from file '%s':\n\t%s", name, filename, results[1])))
+	}
+	return values(results[0], value.NewString(filename)), nil
+}
+
+func (e *Engine) packageSearchpath(args ...value.Value) ([]value.Value, error) {
+	name, err := checkString("searchpath", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	path, err := checkString("searchpath", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := optString("searchpath", args, 3, ".")
+	if err != nil {
+		return nil, err
+	}
+	rep, err := optString("searchpath", args, 4, "/")
+	if err != nil {
+		return nil, err
+	}
+
+	filename, msg := e.searchPath(name, path, sep, rep)
+	if filename == "" {
+		return values(value.Nil, value.NewString(msg)), nil
+	}
+	return values(value.NewString(filename)), nil
+}
+
+// searchPath searches the given path, which is a list of templates separated
+// by ';', for the module with the given name. Every occurrence of sep in the
+// name is replaced by rep, and every '?' in a template is replaced by the
+// name. The first resulting file name, that refers to an existing file in
+// the engine's file system, is returned. If there is no such file, the
+// returned file name is empty, and msg lists all files that were tried.
+func (e *Engine) searchPath(name, path, sep, rep string) (filename, msg string) {
+	if sep != "" {
+		name = strings.ReplaceAll(name, sep, rep)
+	}
+
+	var tried strings.Builder
+	for _, template := range strings.Split(path, ";") {
+		if template == "" {
+			continue
+		}
+		filename := strings.ReplaceAll(template, "?", name)
+		if info, err := e.fs.Stat(filename); err == nil && !info.IsDir() {
+			return filename, ""
+		}
+		tried.WriteString(fmt.Sprintf("\n\tno file '%s'", filename))
+	}
+	return "", tried.String()
+}
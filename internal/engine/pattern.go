@@ -0,0 +1,430 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+const (
+	patternMaxCaptures = 32
+	patternMaxDepth    = 200
+
+	// special values for the length of a capture
+	captureUnfinished = -1
+	capturePosition   = -2
+
+	patternEscape   = '%'
+	patternSpecials = "^$*+?.([%-"
+)
+
+// patternError is raised (as panic) by the pattern matcher if the pattern is
+// malformed. It is recovered at the entry points of the matcher.
+type patternError string
+
+// matchState is the state of a single match of a Lua pattern against a source
+// string. All positions are byte offsets into the source or the pattern. A
+// failed match is represented by the position -1.
+type matchState struct {
+	src     string
+	pattern string
+
+	depth   int
+	level   int
+	capture [patternMaxCaptures]struct {
+		init int
+		len  int
+	}
+}
+
+func newMatchState(src, pattern string) *matchState {
+	return &matchState{
+		src:     src,
+		pattern: pattern,
+	}
+}
+
+// reset prepares the state for another match against the same source.
+func (ms *matchState) reset() {
+	ms.level = 0
+	ms.depth = patternMaxDepth
+}
+
+// find tries to match the pattern, starting at the pattern position p, against
+// the source at position s, and returns the end of the match, or -1. If the
+// pattern is malformed, an error is returned.
+func (ms *matchState) find(s, p int) (end int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if msg, ok := r.(patternError); ok {
+				end = -1
+				err = Error{Message: value.NewString(string(msg))}
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	ms.reset()
+	return ms.match(s, p), nil
+}
+
+func (ms *matchState) errorf(format string, args ...interface{}) {
+	panic(patternError(fmt.Sprintf(format, args...)))
+}
+
+// classEnd returns the position right after the character class that starts
+// at p.
+func (ms *matchState) classEnd(p int) int {
+	c := ms.pattern[p]
+	p++
+	switch c {
+	case patternEscape:
+		if p >= len(ms.pattern) {
+			ms.errorf("malformed pattern (ends with '%%')")
+		}
+		return p + 1
+	case '[':
+		if p < len(ms.pattern) && ms.pattern[p] == '^' {
+			p++
+		}
+		// look for a ']'
+		for {
+			if p >= len(ms.pattern) {
+				ms.errorf("malformed pattern (missing ']')")
+			}
+			c := ms.pattern[p]
+			p++
+			if c == patternEscape && p < len(ms.pattern) {
+				// skip escapes, e.g. '%]'
+				p++
+			}
+			if p < len(ms.pattern) && ms.pattern[p] == ']' {
+				return p + 1
+			}
+		}
+	}
+	return p
+}
+
+func matchClass(c, class byte) bool {
+	var res bool
+	switch class | 0x20 { // lower case
+	case 'a':
+		res = isAlpha(c)
+	case 'c':
+		res = c < 0x20 || c == 0x7f
+	case 'd':
+		res = c >= '0' && c <= '9'
+	case 'g':
+		res = c > 0x20 && c < 0x7f
+	case 'l':
+		res = c >= 'a' && c <= 'z'
+	case 'p':
+		res = c > 0x20 && c < 0x7f && !isAlpha(c) && !(c >= '0' && c <= '9')
+	case 's':
+		res = c == ' ' || (c >= '\t' && c <= '\r')
+	case 'u':
+		res = c >= 'A' && c <= 'Z'
+	case 'w':
+		res = isAlpha(c) || (c >= '0' && c <= '9')
+	case 'x':
+		res = (c >= '0' && c <= '9') || (c|0x20 >= 'a' && c|0x20 <= 'f')
+	default:
+		return class == c
+	}
+	if class >= 'A' && class <= 'Z' {
+		return !res
+	}
+	return res
+}
+
+func isAlpha(c byte) bool {
+	return c|0x20 >= 'a' && c|0x20 <= 'z'
+}
+
+// matchBracketClass reports whether c matches the set that starts at p with
+// '[' and ends at ec with ']'.
+func (ms *matchState) matchBracketClass(c byte, p, ec int) bool {
+	sig := true
+	if ms.pattern[p+1] == '^' {
+		sig = false
+		p++
+	}
+	for p++; p < ec; p++ {
+		if ms.pattern[p] == patternEscape {
+			p++
+			if matchClass(c, ms.pattern[p]) {
+				return sig
+			}
+		} else if ms.pattern[p+1] == '-' && p+2 < ec {
+			p += 2
+			if ms.pattern[p-2] <= c && c <= ms.pattern[p] {
+				return sig
+			}
+		} else if ms.pattern[p] == c {
+			return sig
+		}
+	}
+	return !sig
+}
+
+// singleMatch reports whether the character at s matches the character class
+// from p to ep.
+func (ms *matchState) singleMatch(s, p, ep int) bool {
+	if s >= len(ms.src) {
+		return false
+	}
+	c := ms.src[s]
+	switch ms.pattern[p] {
+	case '.':
+		return true
+	case patternEscape:
+		return matchClass(c, ms.pattern[p+1])
+	case '[':
+		return ms.matchBracketClass(c, p, ep-1)
+	}
+	return ms.pattern[p] == c
+}
+
+func (ms *matchState) match(s, p int) int {
+	ms.depth--
+	if ms.depth == 0 {
+		ms.errorf("pattern too complex")
+	}
+	defer func() { ms.depth++ }()
+
+	for p < len(ms.pattern) {
+		switch ms.pattern[p] {
+		case '(':
+			if p+1 < len(ms.pattern) && ms.pattern[p+1] == ')' {
+				return ms.startCapture(s, p+2, capturePosition)
+			}
+			return ms.startCapture(s, p+1, captureUnfinished)
+		case ')':
+			return ms.endCapture(s, p+1)
+		case '$':
+			if p+1 == len(ms.pattern) {
+				if s == len(ms.src) {
+					return s
+				}
+				return -1
+			}
+		case patternEscape:
+			if p+1 >= len(ms.pattern) {
+				break
+			}
+			switch next := ms.pattern[p+1]; {
+			case next == 'b':
+				s = ms.matchBalance(s, p+2)
+				if s == -1 {
+					return -1
+				}
+				p += 4
+				continue
+			case next == 'f':
+				p += 2
+				if p >= len(ms.pattern) || ms.pattern[p] != '[' {
+					ms.errorf("missing '[' after '%%f' in pattern")
+				}
+				ep := ms.classEnd(p)
+				var prev, cur byte
+				if s > 0 {
+					prev = ms.src[s-1]
+				}
+				if s < len(ms.src) {
+					cur = ms.src[s]
+				}
+				if ms.matchBracketClass(prev, p, ep-1) || !ms.matchBracketClass(cur, p, ep-1) {
+					return -1
+				}
+				p = ep
+				continue
+			case next >= '0' && next <= '9':
+				s = ms.matchCapture(s, next)
+				if s == -1 {
+					return -1
+				}
+				p += 2
+				continue
+			}
+		}
+
+		// default: a single character class, possibly followed
+		// by a repetition
+		ep := ms.classEnd(p)
+		var epc byte
+		if ep < len(ms.pattern) {
+			epc = ms.pattern[ep]
+		}
+		if !ms.singleMatch(s, p, ep) {
+			if epc == '*' || epc == '?' || epc == '-' {
+				// accept empty
+				p = ep + 1
+				continue
+			}
+			return -1
+		}
+		switch epc {
+		case '?':
+			if res := ms.match(s+1, ep+1); res != -1 {
+				return res
+			}
+			p = ep + 1
+		case '+':
+			return ms.maxExpand(s+1, p, ep)
+		case '*':
+			return ms.maxExpand(s, p, ep)
+		case '-':
+			return ms.minExpand(s, p, ep)
+		default:
+			s++
+			p = ep
+		}
+	}
+	return s
+}
+
+func (ms *matchState) maxExpand(s, p, ep int) int {
+	i := 0
+	for ms.singleMatch(s+i, p, ep) {
+		i++
+	}
+	// try with the maximum repetitions, and reduce them until the rest matches
+	for ; i >= 0; i-- {
+		if res := ms.match(s+i, ep+1); res != -1 {
+			return res
+		}
+	}
+	return -1
+}
+
+func (ms *matchState) minExpand(s, p, ep int) int {
+	for {
+		if res := ms.match(s, ep+1); res != -1 {
+			return res
+		}
+		if !ms.singleMatch(s, p, ep) {
+			return -1
+		}
+		s++
+	}
+}
+
+func (ms *matchState) startCapture(s, p, what int) int {
+	if ms.level >= patternMaxCaptures {
+		ms.errorf("too many captures")
+	}
+	ms.capture[ms.level].init = s
+	ms.capture[ms.level].len = what
+	ms.level++
+	res := ms.match(s, p)
+	if res == -1 {
+		// undo capture
+		ms.level--
+	}
+	return res
+}
+
+func (ms *matchState) endCapture(s, p int) int {
+	l := ms.captureToClose()
+	ms.capture[l].len = s - ms.capture[l].init
+	res := ms.match(s, p)
+	if res == -1 {
+		// undo capture
+		ms.capture[l].len = captureUnfinished
+	}
+	return res
+}
+
+func (ms *matchState) captureToClose() int {
+	for level := ms.level - 1; level >= 0; level-- {
+		if ms.capture[level].len == captureUnfinished {
+			return level
+		}
+	}
+	ms.errorf("invalid pattern capture")
+	return 0
+}
+
+func (ms *matchState) matchBalance(s, p int) int {
+	if p+1 >= len(ms.pattern) {
+		ms.errorf("malformed pattern (missing arguments to '%%b')")
+	}
+	if s >= len(ms.src) || ms.src[s] != ms.pattern[p] {
+		return -1
+	}
+	b, e := ms.pattern[p], ms.pattern[p+1]
+	cont := 1
+	for s++; s < len(ms.src); s++ {
+		if ms.src[s] == e {
+			cont--
+			if cont == 0 {
+				return s + 1
+			}
+		} else if ms.src[s] == b {
+			cont++
+		}
+	}
+	return -1
+}
+
+func (ms *matchState) matchCapture(s int, l byte) int {
+	index := ms.checkCapture(l)
+	capture := ms.src[ms.capture[index].init : ms.capture[index].init+ms.capture[index].len]
+	if len(ms.src)-s >= len(capture) && ms.src[s:s+len(capture)] == capture {
+		return s + len(capture)
+	}
+	return -1
+}
+
+func (ms *matchState) checkCapture(l byte) int {
+	index := int(l) - '1'
+	if index < 0 || index >= ms.level || ms.capture[index].len == captureUnfinished {
+		ms.errorf("invalid capture index %%%d", index+1)
+	}
+	return index
+}
+
+// captureValue returns the value of the i-th capture of a match from s to e.
+// If there are no captures, the 0th capture is the whole match.
+func (ms *matchState) captureValue(i, s, e int) value.Value {
+	if i >= ms.level {
+		if i != 0 {
+			ms.errorf("invalid capture index %%%d", i+1)
+		}
+		return value.NewString(ms.src[s:e])
+	}
+	init, l := ms.capture[i].init, ms.capture[i].len
+	if l == captureUnfinished {
+		ms.errorf("unfinished capture")
+	}
+	if l == capturePosition {
+		return value.NewInteger(int64(init + 1))
+	}
+	return value.NewString(ms.src[init : init+l])
+}
+
+// captures returns the values of all captures of a match from s to e. If there
+// are no captures and wholeIfNone is set, the whole match is returned.
+func (ms *matchState) captures(s, e int, wholeIfNone bool) (vals []value.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if msg, ok := r.(patternError); ok {
+				err = Error{Message: value.NewString(string(msg))}
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	n := ms.level
+	if n == 0 && wholeIfNone {
+		n = 1
+	}
+	vals = make([]value.Value, n)
+	for i := range vals {
+		vals[i] = ms.captureValue(i, s, e)
+	}
+	return vals, nil
+}
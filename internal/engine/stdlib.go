@@ -3,19 +3,21 @@ package engine
 import (
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"runtime"
 	"runtime/debug"
-	"strconv"
+	"strings"
 
 	. "github.com/tsatke/lua/internal/engine/value"
 )
 
 func (e *Engine) initStdlib() {
-	register := func(fn *Function) {
-		e.assign(e._G, fn.Name, fn)
-	}
+	register := e.Register
+	registerLib := e.RegisterModule
+	e.initPackage()
+	e.assign(e._G, "_G", e._G)
 	e.assign(e._G, "_VERSION", NewString("Lua 5.3"))
 	register(NewFunction("assert", e.assert))
 	register(NewFunction("collectgarbage", e.collectgarbage))
@@ -23,13 +25,102 @@ func (e *Engine) initStdlib() {
 	register(NewFunction("error", e.error))
 	register(NewFunction("getmetatable", e.getmetatable))
 	register(NewFunction("ipairs", e.ipairs))
+	register(NewFunction("load", e.load))
+	register(NewFunction("loadfile", e.loadfile))
+	register(NewFunction("next", e.next))
+	register(NewFunction("pairs", e.pairs))
 	register(NewFunction("pcall", e.pcall))
 	register(NewFunction("print", e.print))
+	register(NewFunction("rawequal", e.rawequal))
 	register(NewFunction("rawget", e.rawget))
+	register(NewFunction("rawlen", e.rawlen))
+	register(NewFunction("rawset", e.rawset))
 	register(NewFunction("select", e.select_))
 	register(NewFunction("setmetatable", e.setmetatable))
+	register(NewFunction("tonumber", e.tonumber))
 	register(NewFunction("tostring", e.tostring))
 	register(NewFunction("type", e.type_))
+
+	registerLib("coroutine",
+		NewFunction("create", e.coroutineCreate),
+		NewFunction("isyieldable", e.coroutineIsyieldable),
+		NewFunction("resume", e.coroutineResume),
+		NewFunction("running", e.coroutineRunning),
+		NewFunction("status", e.coroutineStatus),
+		NewFunction("wrap", e.coroutineWrap),
+		NewFunction("yield", e.coroutineYield),
+	)
+	e.initIO()
+	registerLib("math",
+		NewFunction("abs", e.mathAbs),
+		NewFunction("acos", e.mathAcos),
+		NewFunction("asin", e.mathAsin),
+		NewFunction("atan", e.mathAtan),
+		NewFunction("ceil", e.mathCeil),
+		NewFunction("cos", e.mathCos),
+		NewFunction("exp", e.mathExp),
+		NewFunction("floor", e.mathFloor),
+		NewFunction("fmod", e.mathFmod),
+		NewFunction("log", e.mathLog),
+		NewFunction("max", e.mathMax),
+		NewFunction("min", e.mathMin),
+		NewFunction("modf", e.mathModf),
+		NewFunction("random", e.mathRandom),
+		NewFunction("randomseed", e.mathRandomseed),
+		NewFunction("sin", e.mathSin),
+		NewFunction("sqrt", e.mathSqrt),
+		NewFunction("tan", e.mathTan),
+		NewFunction("tointeger", e.mathTointeger),
+		NewFunction("type", e.mathType),
+		NewFunction("ult", e.mathUlt),
+	)
+	mathLib, _ := e._G.Get(NewString("math"))
+	for name, val := range map[string]Value{
+		"huge":       NewNumber(math.Inf(1)),
+		"maxinteger": NewInteger(math.MaxInt64),
+		"mininteger": NewInteger(math.MinInt64),
+		"pi":         NewNumber(math.Pi),
+	} {
+		mathLib.(*Table).Set(NewString(name), val)
+	}
+	registerLib("os",
+		NewFunction("clock", e.osClock),
+		NewFunction("date", e.osDate),
+		NewFunction("difftime", e.osDifftime),
+		NewFunction("exit", e.osExit),
+		NewFunction("getenv", e.osGetenv),
+		NewFunction("remove", e.osRemove),
+		NewFunction("rename", e.osRename),
+		NewFunction("time", e.osTime),
+		NewFunction("tmpname", e.osTmpname),
+	)
+	registerLib("string",
+		NewFunction("byte", e.stringByte),
+		NewFunction("char", e.stringChar),
+		NewFunction("find", e.stringFind),
+		NewFunction("format", e.stringFormat),
+		NewFunction("gmatch", e.stringGmatch),
+		NewFunction("gsub", e.stringGsub),
+		NewFunction("len", e.stringLen),
+		NewFunction("lower", e.stringLower),
+		NewFunction("match", e.stringMatch),
+		NewFunction("pack", e.stringPack),
+		NewFunction("packsize", e.stringPacksize),
+		NewFunction("rep", e.stringRep),
+		NewFunction("reverse", e.stringReverse),
+		NewFunction("sub", e.stringSub),
+		NewFunction("unpack", e.stringUnpack),
+		NewFunction("upper", e.stringUpper),
+	)
+	registerLib("table",
+		NewFunction("concat", e.tableConcat),
+		NewFunction("insert", e.tableInsert),
+		NewFunction("move", e.tableMove),
+		NewFunction("pack", e.tablePack),
+		NewFunction("remove", e.tableRemove),
+		NewFunction("sort", e.tableSort),
+		NewFunction("unpack", e.tableUnpack),
+	)
 }
 
 func (e *Engine) assert(args ...Value) ([]Value, error) {
@@ -46,32 +137,37 @@ func (e *Engine) assert(args ...Value) ([]Value, error) {
 }
 
 func (e *Engine) collectgarbage(args ...Value) ([]Value, error) {
-	if len(args) == 0 {
-		return nil, fmt.Errorf("need at least one argument to 'collectgarbage'")
+	var opt Value = NewString("collect")
+	if len(args) > 0 {
+		opt = args[0]
 	}
 
-	opt := args[0]
 	if opt.Type() != TypeString {
 		return nil, fmt.Errorf("bad argument to 'collectgarbage' (%s expected, got %s)", TypeString, opt.Type())
 	}
 	switch opt.(String).String() {
 	case "collect":
 		runtime.GC()
+		e.runFinalizers()
+		return values(NewInteger(0)), nil
 	case "stop":
 		e.gcpercent = debug.SetGCPercent(-1)
 		e.gcrunning = false
+		return values(NewInteger(0)), nil
 	case "restart":
 		debug.SetGCPercent(e.gcpercent)
 		e.gcrunning = true
+		return values(NewInteger(0)), nil
 	case "count":
 		var m runtime.MemStats
 		runtime.ReadMemStats(&m)
 		return values(NewNumber(float64(m.HeapAlloc))), nil
 	case "step":
 		runtime.GC()
+		e.runFinalizers()
 		return values(True), nil
-	case "setpause":
-	case "setstepmul":
+	case "setpause", "setstepmul":
+		return values(NewInteger(0)), nil
 	case "isrunning":
 		if e.gcrunning {
 			return values(True), nil
@@ -123,6 +219,134 @@ func (e *Engine) dofile(args ...Value) ([]Value, error) {
 	return results, nil
 }
 
+func (e *Engine) load(args ...Value) ([]Value, error) {
+	var source string
+	defaultName := "=(load)"
+	var reader *Function
+	if len(args) > 0 {
+		reader, _ = args[0].(*Function)
+	}
+	if reader != nil {
+		// the reader function is called until it returns an empty string
+		// or nothing, the pieces make up the source of the chunk
+		var buf strings.Builder
+		for {
+			results, err := e.call(reader)
+			if err != nil {
+				var luaErr Error
+				if errors.As(err, &luaErr) {
+					return values(Nil, luaErr.Message), nil
+				}
+				return nil, fmt.Errorf("call reader: %w", err)
+			}
+			if len(results) == 0 || e.isNil(results[0]) {
+				break
+			}
+			piece, err := checkString("load", results, 1)
+			if err != nil {
+				return values(Nil, NewString("reader function must return a string")), nil
+			}
+			if piece == "" {
+				break
+			}
+			buf.WriteString(piece)
+		}
+		source = buf.String()
+	} else {
+		str, err := checkString("load", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		source = str
+		defaultName = str
+	}
+
+	chunkname, err := optString("load", args, 2, defaultName)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := optString("load", args, 3, "bt")
+	if err != nil {
+		return nil, err
+	}
+	env := optEnv(args, 4)
+	return e.loadChunk(strings.NewReader(source), chunkname, mode, env)
+}
+
+func (e *Engine) loadfile(args ...Value) ([]Value, error) {
+	mode, err := optString("loadfile", args, 2, "bt")
+	if err != nil {
+		return nil, err
+	}
+	env := optEnv(args, 3)
+
+	if isNoneOrNil(args, 1) {
+		// load stdin if no file name is given
+		return e.loadChunk(e.stdin, "=stdin", mode, env)
+	}
+	filename, err := checkString("loadfile", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := e.fs.Open(filename)
+	if err != nil {
+		return values(Nil, NewString(fmt.Sprintf("cannot open %s", filename))), nil
+	}
+	defer func() { _ = file.Close() }()
+
+	return e.loadChunk(file, "@"+filename, mode, env)
+}
+
+// loadChunk parses the given source and returns the function of the chunk,
+// without evaluating it. If the chunk can't be loaded, nil and the error
+// message are returned, like load does.
+func (e *Engine) loadChunk(source io.Reader, chunkname, mode string, env Value) ([]Value, error) {
+	// there are no binary chunks, so the mode only has to allow text
+	if !strings.Contains(mode, "t") {
+		return values(Nil, NewString(fmt.Sprintf("attempt to load a text chunk (mode is '%s')", mode))), nil
+	}
+
+	chunk, err := e.parse(source)
+	if err != nil {
+		return values(Nil, NewString(err.Error())), nil
+	}
+	chunk.Name = chunkID(chunkname)
+
+	fn, err := e.newChunkFunction(chunk, env)
+	if err != nil {
+		return nil, err
+	}
+	return values(fn), nil
+}
+
+// optEnv returns the n-th (1-based) argument as environment of a chunk, or
+// nil if the argument is absent. Like in Lua, an explicit nil is a valid
+// environment.
+func optEnv(args []Value, n int) Value {
+	if n > len(args) {
+		return nil
+	}
+	if args[n-1] == nil {
+		return Nil
+	}
+	return args[n-1]
+}
+
+// chunkID returns the name that a chunk with the given chunk name has in
+// stack traces. Like in the reference implementation, the names of files
+// start with '@' and other names with '=', everything else is the source
+// of the chunk itself.
+func chunkID(chunkname string) string {
+	if strings.HasPrefix(chunkname, "=") || strings.HasPrefix(chunkname, "@") {
+		return chunkname[1:]
+	}
+	if i := strings.IndexByte(chunkname, '\n'); i >= 0 {
+		chunkname = chunkname[:i] + "..."
+	}
+	return `[string "` + chunkname + `"]`
+}
+
 func (e *Engine) error(args ...Value) ([]Value, error) {
 	var message Value
 	var level Value
@@ -148,16 +372,27 @@ func (e *Engine) getmetatable(args ...Value) ([]Value, error) {
 	}
 
 	val := args[0]
-	if val.Type() == TypeTable {
-		if metatable := val.(*Table).Metatable; metatable != nil {
-			return values(metatable), nil
+	var metatable *Table
+	switch v := val.(type) {
+	case *Table:
+		metatable = v.Metatable
+	case *Userdata:
+		metatable = v.Metatable
+	default:
+		metatable = e.metaTables.Table(val.Type())
+	}
+	if metatable == nil {
+		if val.Type() == TypeTable || val.Type() == TypeUserdata {
+			return values(Nil), nil
 		}
-		return values(Nil), nil
+		return nil, fmt.Errorf("no meta table for type %s", val.Type())
 	}
-	if metatable := e.metaTables.Table(val.Type()); metatable != nil {
-		return values(metatable), nil
+	// a __metatable field protects the metatable from being
+	// obtained and changed
+	if protected, ok := metatable.Get(NewString("__metatable")); ok {
+		return values(protected), nil
 	}
-	return nil, fmt.Errorf("no meta table for type %s", val.Type())
+	return values(metatable), nil
 }
 
 func (e *Engine) ipairs(args ...Value) ([]Value, error) {
@@ -178,14 +413,14 @@ func (e *Engine) ipairs(args ...Value) ([]Value, error) {
 		if _, ok := args[0].(*Table); !ok {
 			return nil, fmt.Errorf("bad argument #1 to 'iter' (%s expected, got %s)", TypeTable, args[0].Type())
 		}
-		if _, ok := args[1].(Number); !ok {
+		if _, ok := args[1].(Integer); !ok {
 			return nil, fmt.Errorf("bad argument #2 to 'iter' (%s expected, got %s)", TypeNumber, args[0].Type())
 		}
 		var a *Table
-		var i Number
+		var i Integer
 		a = args[0].(*Table)
-		i = args[1].(Number)
-		i = NewNumber(i.Value() + 1)
+		i = args[1].(Integer)
+		i++
 		v, ok := a.Get(i)
 		if ok {
 			return values(i, v), nil
@@ -193,7 +428,57 @@ func (e *Engine) ipairs(args ...Value) ([]Value, error) {
 		return nil, nil
 	}
 	iterFn := NewFunction("iter", iter)
-	return values(iterFn, args[0], NewNumber(0)), nil
+	return values(iterFn, args[0], NewInteger(0)), nil
+}
+
+func (e *Engine) next(args ...Value) ([]Value, error) {
+	if len(args) == 0 {
+		return nil, typeArgError(1, "next", "table", args)
+	}
+	table, ok := args[0].(*Table)
+	if !ok {
+		return nil, typeArgError(1, "next", "table", args)
+	}
+	var key Value = Nil
+	if len(args) > 1 {
+		key = args[1]
+	}
+
+	nextKey, val, ok := table.Next(key)
+	if !ok {
+		return e.error(NewString("invalid key to 'next'"))
+	}
+	if nextKey == Nil {
+		return values(Nil), nil
+	}
+	return values(nextKey, val), nil
+}
+
+func (e *Engine) pairs(args ...Value) ([]Value, error) {
+	if len(args) == 0 {
+		return nil, typeArgError(1, "pairs", "table", args)
+	}
+
+	metaMethod, err := e.metaMethod(args[0], "__pairs")
+	if err != nil {
+		return nil, fmt.Errorf("meta method __pairs: %w", err)
+	}
+	if !e.isNil(metaMethod) {
+		results, err := e.attemptCall(metaMethod, args[0])
+		if err != nil {
+			return nil, err
+		}
+		// the results of __pairs are adjusted to three values
+		for len(results) < 3 {
+			results = append(results, Nil)
+		}
+		return results[:3], nil
+	}
+
+	if _, ok := args[0].(*Table); !ok {
+		return nil, typeArgError(1, "pairs", "table", args)
+	}
+	return values(NewFunction("next", e.next), args[0], Nil), nil
 }
 
 func (e *Engine) pcall(args ...Value) ([]Value, error) {
@@ -243,6 +528,13 @@ func (e *Engine) print(args ...Value) ([]Value, error) {
 	return nil, nil
 }
 
+func (e *Engine) rawequal(args ...Value) ([]Value, error) {
+	if len(args) < 2 {
+		return nil, argError(len(args)+1, "rawequal", "value expected")
+	}
+	return values(Boolean(rawEqual(args[0], args[1]))), nil
+}
+
 func (e *Engine) rawget(args ...Value) ([]Value, error) {
 	if len(args) != 2 {
 		return nil, fmt.Errorf("need exactly two arguments to 'rawget'")
@@ -264,33 +556,61 @@ func (e *Engine) rawget(args ...Value) ([]Value, error) {
 	return values(val), nil
 }
 
-func (e *Engine) select_(args ...Value) ([]Value, error) {
+func (e *Engine) rawlen(args ...Value) ([]Value, error) {
+	if len(args) > 0 {
+		switch v := args[0].(type) {
+		case *Table:
+			return values(v.Length()), nil
+		case String:
+			return values(NewInteger(int64(len(v)))), nil
+		}
+	}
+	return nil, argError(1, "rawlen", "table or string expected")
+}
+
+func (e *Engine) rawset(args ...Value) ([]Value, error) {
 	if len(args) == 0 {
-		return nil, fmt.Errorf("need at least one argument to 'select'")
+		return nil, typeArgError(1, "rawset", "table", args)
+	}
+	table, ok := args[0].(*Table)
+	if !ok {
+		return nil, typeArgError(1, "rawset", "table", args)
 	}
-	if str, ok := args[0].(String); ok && str == "#" {
-		return values(NewNumber(float64(len(args) - 1))), nil
-	} else if ok {
-		return nil, fmt.Errorf("if the first argument to 'select' is a string, it must be the string '#'")
+	if len(args) < 3 {
+		return nil, argError(len(args)+1, "rawset", "value expected")
 	}
 
-	num, ok := args[0].(Number)
-	if !ok {
-		return nil, fmt.Errorf("bad argument #1 to 'select' (%s expected, got %s", TypeNumber, args[0].Type())
+	if err := e.rawSet(table, args[1], args[2]); err != nil {
+		return nil, err
 	}
-	if float64(num) != math.Trunc(float64(num)) {
-		return nil, fmt.Errorf("number %f has no integral representation", float64(num))
+	return values(table), nil
+}
+
+func (e *Engine) select_(args ...Value) ([]Value, error) {
+	if len(args) > 0 {
+		if str, ok := args[0].(String); ok && str == "#" {
+			return values(NewInteger(int64(len(args) - 1))), nil
+		}
+	}
+	num, err := checkInteger("select", args, 1)
+	if err != nil {
+		return nil, err
 	}
 
-	selection := int(num)
-	if selection < 0 {
-		return args[len(args)-selection:], nil
+	// args[0] is the selector itself, so args[n] is the n-th argument
+	if num < 0 {
+		if -num > int64(len(args)-1) {
+			return nil, argError(1, "select", "index out of range")
+		}
+		return args[int64(len(args))+num:], nil
+	}
+	if num == 0 {
+		return nil, argError(1, "select", "index out of range")
 	}
-	fromIndex := selection + 1
-	if fromIndex > len(args) {
-		fromIndex = len(args)
+	if num > int64(len(args)) {
+		num = int64(len(args))
 	}
-	return args[fromIndex:], nil
+	return args[num:], nil
 }
 
 func (e *Engine) setmetatable(args ...Value) ([]Value, error) {
@@ -307,7 +627,7 @@ func (e *Engine) setmetatable(args ...Value) ([]Value, error) {
 	metatable := args[0].(*Table).Metatable
 	if metatable != nil {
 		if _, ok := metatable.Get(NewString("__metatable")); ok {
-			_, _ = e.error(NewString("original metatable has a __metatable field"))
+			return e.error(NewString("cannot change a protected metatable"))
 		}
 	}
 	if args[1] == Nil {
@@ -329,6 +649,23 @@ func (e *Engine) tostring(args ...Value) ([]Value, error) {
 	}
 
 	value := args[0]
+	if value.Type() == TypeTable || value.Type() == TypeUserdata {
+		tostring, err := e.metaMethod(value, "__tostring")
+		if err != nil {
+			return nil, fmt.Errorf("meta method __tostring: %w", err)
+		}
+		if !e.isNil(tostring) {
+			results, err := e.attemptCall(tostring, value)
+			if err != nil {
+				return nil, err
+			}
+			if len(results) == 0 || results[0].Type() != TypeString {
+				return e.error(NewString("'__tostring' must return a string"))
+			}
+			return results[:1], nil
+		}
+	}
+
 	switch value.Type() {
 	case TypeNil:
 		return values(NewString("nil")), nil
@@ -341,63 +678,88 @@ func (e *Engine) tostring(args ...Value) ([]Value, error) {
 		return values(value), nil
 	case TypeFunction:
 		return values(NewString("function " + value.(*Function).Name)), nil
+	case TypeThread:
+		return values(NewString(value.(*Coroutine).String())), nil
+	case TypeTable:
+		return values(NewString(fmt.Sprintf("table: %p", value))), nil
+	case TypeUserdata:
+		return values(NewString(fmt.Sprint(value))), nil
 	case TypeNumber:
-		return values(NewString(strconv.FormatFloat(float64(value.(Number)), 'G', -1, 64))), nil
+		return values(NewString(value.(fmt.Stringer).String())), nil
 	}
 	return nil, fmt.Errorf("unsupported type %s to 'tostring'", value.Type())
 }
 
 func (e *Engine) tonumber(args ...Value) ([]Value, error) {
 	if len(args) == 0 {
-		return nil, fmt.Errorf("need one argument to 'tonumber'")
+		return nil, argError(1, "tonumber", "value expected")
 	}
 
-	if args[0] == nil {
+	if isNoneOrNil(args, 2) {
+		if num, ok := toNumberValue(args[0]); ok {
+			return values(num), nil
+		}
 		return values(Nil), nil
 	}
 
-	value := args[0]
-	switch value.Type() {
-	case TypeNumber:
-		return values(value), nil
-	case TypeString:
-		num, err := strconv.ParseFloat(value.(String).String(), 64)
-		if err != nil {
-			return values(Nil), nil
-		}
-		return values(NewNumber(num)), nil
+	base, err := checkInteger("tonumber", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	str, ok := args[0].(String)
+	if !ok {
+		return nil, typeArgError(1, "tonumber", "string", args)
+	}
+	if base < 2 || base > 36 {
+		return nil, argError(2, "tonumber", "base out of range")
+	}
+	if num, ok := parseIntegerInBase(strings.TrimSpace(string(str)), base); ok {
+		return values(NewInteger(num)), nil
 	}
 	return values(Nil), nil
 }
 
+// parseIntegerInBase parses an optionally negative integer numeral in the
+// given base, where the letters 'a' to 'z' (in either case) are the digits
+// 10 to 35. Like in Lua, the result wraps around on overflow.
+func parseIntegerInBase(s string, base int64) (int64, bool) {
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, false
+	}
+	var n int64
+	for _, c := range []byte(s) {
+		var digit int64
+		switch {
+		case c >= '0' && c <= '9':
+			digit = int64(c - '0')
+		case c >= 'a' && c <= 'z':
+			digit = int64(c-'a') + 10
+		case c >= 'A' && c <= 'Z':
+			digit = int64(c-'A') + 10
+		default:
+			return 0, false
+		}
+		if digit >= base {
+			return 0, false
+		}
+		n = n*base + digit
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
 func (e *Engine) type_(args ...Value) ([]Value, error) {
 	if len(args) == 0 {
 		return nil, fmt.Errorf("need one argument to 'tostring'")
 	}
 
-	value := args[0]
-	var typeName string
-	switch value.Type() {
-	case TypeInvalid:
-		typeName = "<invalid>"
-	case TypeNil:
-		typeName = "nil"
-	case TypeBoolean:
-		typeName = "boolean"
-	case TypeNumber:
-		typeName = "number"
-	case TypeString:
-		typeName = "string"
-	case TypeFunction:
-		typeName = "function"
-	case TypeUserdata:
-		typeName = "userdata"
-	case TypeThread:
-		typeName = "thread"
-	case TypeTable:
-		typeName = "table"
-	}
-	return values(NewString(typeName)), nil
+	return values(NewString(args[0].Type().Name())), nil
 }
 
 func values(vals ...Value) []Value {
@@ -0,0 +1,625 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// maxStringSize is the maximum size of a string that string.rep creates.
+const maxStringSize = math.MaxInt32
+
+// posrelat converts a relative string position, where negative positions count
+// from the end of the string, into an absolute position. The result is 0 for
+// positions before the start of the string.
+func posrelat(pos int64, length int) int64 {
+	if pos >= 0 {
+		return pos
+	}
+	if -pos > int64(length) {
+		return 0
+	}
+	return int64(length) + pos + 1
+}
+
+func (e *Engine) stringByte(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("byte", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	i, err := optInteger("byte", args, 2, 1)
+	if err != nil {
+		return nil, err
+	}
+	posi := posrelat(i, len(s))
+	j, err := optInteger("byte", args, 3, posi)
+	if err != nil {
+		return nil, err
+	}
+	pose := posrelat(j, len(s))
+
+	if posi < 1 {
+		posi = 1
+	}
+	if pose > int64(len(s)) {
+		pose = int64(len(s))
+	}
+	if posi > pose {
+		return nil, nil
+	}
+
+	results := make([]value.Value, 0, pose-posi+1)
+	for _, c := range []byte(s[posi-1 : pose]) {
+		results = append(results, value.NewInteger(int64(c)))
+	}
+	return results, nil
+}
+
+func (e *Engine) stringChar(args ...value.Value) ([]value.Value, error) {
+	buf := make([]byte, len(args))
+	for i := range args {
+		c, err := checkInteger("char", args, i+1)
+		if err != nil {
+			return nil, err
+		}
+		if c < 0 || c > math.MaxUint8 {
+			return nil, argError(i+1, "char", "value out of range")
+		}
+		buf[i] = byte(c)
+	}
+	return values(value.NewString(string(buf))), nil
+}
+
+func (e *Engine) stringFind(args ...value.Value) ([]value.Value, error) {
+	return e.stringFindAux("find", true, args)
+}
+
+func (e *Engine) stringMatch(args ...value.Value) ([]value.Value, error) {
+	return e.stringFindAux("match", false, args)
+}
+
+// stringFindAux implements string.find and string.match, which only differ in
+// what they return.
+func (e *Engine) stringFindAux(fnName string, find bool, args []value.Value) ([]value.Value, error) {
+	s, err := checkString(fnName, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := checkString(fnName, args, 2)
+	if err != nil {
+		return nil, err
+	}
+	i, err := optInteger(fnName, args, 3, 1)
+	if err != nil {
+		return nil, err
+	}
+	init := posrelat(i, len(s))
+	if init < 1 {
+		init = 1
+	}
+	if init > int64(len(s))+1 {
+		// start after string's end, cannot find anything
+		return values(value.Nil), nil
+	}
+
+	plain := len(args) > 3 && e.valueIsLogicallyTrue(args[3])
+	if find && (plain || !strings.ContainsAny(pattern, patternSpecials)) {
+		// do a plain search
+		index := strings.Index(s[init-1:], pattern)
+		if index == -1 {
+			return values(value.Nil), nil
+		}
+		start := int(init) + index
+		return values(value.NewInteger(int64(start)), value.NewInteger(int64(start+len(pattern)-1))), nil
+	}
+
+	ms := newMatchState(s, pattern)
+	anchor := len(pattern) > 0 && pattern[0] == '^'
+	p := 0
+	if anchor {
+		p = 1
+	}
+	for start := int(init) - 1; start <= len(s); start++ {
+		end, err := ms.find(start, p)
+		if err != nil {
+			return nil, err
+		}
+		if end != -1 {
+			if !find {
+				return ms.captures(start, end, true)
+			}
+			captures, err := ms.captures(start, end, false)
+			if err != nil {
+				return nil, err
+			}
+			return append(values(value.NewInteger(int64(start+1)), value.NewInteger(int64(end))), captures...), nil
+		}
+		if anchor {
+			break
+		}
+	}
+	return values(value.Nil), nil
+}
+
+func (e *Engine) stringGmatch(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("gmatch", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := checkString("gmatch", args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := newMatchState(s, pattern)
+	src, lastMatch := 0, -1
+	return values(value.NewFunction("gmatch", func(...value.Value) ([]value.Value, error) {
+		for ; src <= len(s); src++ {
+			end, err := ms.find(src, 0)
+			if err != nil {
+				return nil, err
+			}
+			if end != -1 && end != lastMatch {
+				start := src
+				src, lastMatch = end, end
+				return ms.captures(start, end, true)
+			}
+		}
+		return values(value.Nil), nil
+	})), nil
+}
+
+func (e *Engine) stringGsub(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("gsub", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := checkString("gsub", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 3 {
+		return nil, typeArgError(3, "gsub", "string/function/table", args)
+	}
+	repl := args[2]
+	switch repl.Type() {
+	case value.TypeNumber, value.TypeString, value.TypeTable, value.TypeFunction:
+	default:
+		return nil, typeArgError(3, "gsub", "string/function/table", args)
+	}
+	maxN, err := optInteger("gsub", args, 4, int64(len(s))+1)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := len(pattern) > 0 && pattern[0] == '^'
+	p := 0
+	if anchor {
+		p = 1
+	}
+
+	ms := newMatchState(s, pattern)
+	var buf strings.Builder
+	src, lastMatch := 0, -1
+	var n int64
+	for n < maxN {
+		end, err := ms.find(src, p)
+		if err != nil {
+			return nil, err
+		}
+		if end != -1 && end != lastMatch {
+			n++
+			if err := e.addReplacement(&buf, ms, src, end, repl); err != nil {
+				return nil, err
+			}
+			src, lastMatch = end, end
+		} else if src < len(s) {
+			buf.WriteByte(s[src])
+			src++
+		} else {
+			break
+		}
+		if anchor {
+			break
+		}
+	}
+	buf.WriteString(s[src:])
+	return values(value.NewString(buf.String()), value.NewInteger(int64(n))), nil
+}
+
+// addReplacement writes the replacement for the match from s to e into the
+// given buffer.
+func (e *Engine) addReplacement(buf *strings.Builder, ms *matchState, s, end int, repl value.Value) error {
+	var result value.Value
+	switch r := repl.(type) {
+	case value.String, value.Number, value.Integer:
+		replString, _ := checkString("gsub", values(r), 1)
+		return e.addReplacementString(buf, ms, s, end, replString)
+	case *value.Table:
+		captures, err := ms.captures(s, end, true)
+		if err != nil {
+			return err
+		}
+		results, err := e.performIndexOperation(r, captures[0])
+		if err != nil {
+			return err
+		}
+		result = results[0]
+	case *value.Function:
+		captures, err := ms.captures(s, end, true)
+		if err != nil {
+			return err
+		}
+		results, err := e.call(r, captures...)
+		if err != nil {
+			return err
+		}
+		if len(results) > 0 {
+			result = results[0]
+		}
+	}
+
+	if !e.valueIsLogicallyTrue(result) {
+		// keep the original text
+		buf.WriteString(ms.src[s:end])
+		return nil
+	}
+	switch result.Type() {
+	case value.TypeString, value.TypeNumber:
+		str, _ := checkString("gsub", values(result), 1)
+		buf.WriteString(str)
+		return nil
+	}
+	return Error{Message: value.NewString(fmt.Sprintf("invalid replacement value (a %s)", result.Type().Name()))}
+}
+
+func (e *Engine) addReplacementString(buf *strings.Builder, ms *matchState, s, end int, repl string) error {
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		if c != patternEscape {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		if i < len(repl) && repl[i] == patternEscape {
+			buf.WriteByte(patternEscape)
+			continue
+		}
+		if i >= len(repl) || repl[i] < '0' || repl[i] > '9' {
+			return Error{Message: value.NewString("invalid use of '%' in replacement string")}
+		}
+		if repl[i] == '0' {
+			buf.WriteString(ms.src[s:end])
+			continue
+		}
+		captures, err := ms.captures(s, end, true)
+		if err != nil {
+			return err
+		}
+		index := int(repl[i] - '1')
+		if index >= len(captures) {
+			return Error{Message: value.NewString(fmt.Sprintf("invalid capture index %%%d", index+1))}
+		}
+		str, _ := checkString("gsub", captures[index:], 1)
+		buf.WriteString(str)
+	}
+	return nil
+}
+
+func (e *Engine) stringLen(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("len", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values(value.NewInteger(int64(len(s)))), nil
+}
+
+func (e *Engine) stringLower(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("lower", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	buf := []byte(s)
+	for i, c := range buf {
+		if c >= 'A' && c <= 'Z' {
+			buf[i] = c + ('a' - 'A')
+		}
+	}
+	return values(value.NewString(string(buf))), nil
+}
+
+func (e *Engine) stringUpper(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("upper", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	buf := []byte(s)
+	for i, c := range buf {
+		if c >= 'a' && c <= 'z' {
+			buf[i] = c - ('a' - 'A')
+		}
+	}
+	return values(value.NewString(string(buf))), nil
+}
+
+func (e *Engine) stringRep(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("rep", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	n, err := checkInteger("rep", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := optString("rep", args, 3, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if n <= 0 {
+		return values(value.NewString("")), nil
+	}
+	if int64(len(s)+len(sep)) > maxStringSize/n {
+		return nil, Error{Message: value.NewString("resulting string too large")}
+	}
+
+	var buf strings.Builder
+	buf.Grow(int(n)*(len(s)+len(sep)) - len(sep))
+	for i := int64(0); i < n; i++ {
+		if i > 0 {
+			buf.WriteString(sep)
+		}
+		buf.WriteString(s)
+	}
+	return values(value.NewString(buf.String())), nil
+}
+
+func (e *Engine) stringReverse(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("reverse", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, len(s))
+	for i := range buf {
+		buf[i] = s[len(s)-1-i]
+	}
+	return values(value.NewString(string(buf))), nil
+}
+
+func (e *Engine) stringSub(args ...value.Value) ([]value.Value, error) {
+	s, err := checkString("sub", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	i, err := checkInteger("sub", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	j, err := optInteger("sub", args, 3, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := posrelat(i, len(s)), posrelat(j, len(s))
+	if start < 1 {
+		start = 1
+	}
+	if end > int64(len(s)) {
+		end = int64(len(s))
+	}
+	if start > end {
+		return values(value.NewString("")), nil
+	}
+	return values(value.NewString(s[start-1 : end])), nil
+}
+
+const formatFlags = "-+ #0"
+
+func (e *Engine) stringFormat(args ...value.Value) ([]value.Value, error) {
+	format, err := checkString("format", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf strings.Builder
+	arg := 1
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			buf.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			buf.WriteByte('%')
+			continue
+		}
+
+		// scan the format specification
+		start := i
+		for i < len(format) && strings.IndexByte(formatFlags, format[i]) >= 0 {
+			i++
+		}
+		if i-start > len(formatFlags) {
+			return nil, Error{Message: value.NewString("invalid format (repeated flags)")}
+		}
+		i = skipDigits(format, i)
+		if i < len(format) && format[i] == '.' {
+			i = skipDigits(format, i+1)
+		}
+		if i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			return nil, Error{Message: value.NewString("invalid format (width or precision too long)")}
+		}
+		if i >= len(format) {
+			return nil, Error{Message: value.NewString("invalid conversion '%" + format[start:] + "' to 'format'")}
+		}
+		spec, conv := format[start:i], format[i]
+
+		arg++
+		if arg > len(args) {
+			return nil, argError(arg, "format", "no value")
+		}
+
+		switch conv {
+		case 'c':
+			c, err := checkInteger("format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(fmt.Sprintf("%"+spec+"s", string([]byte{byte(c)})))
+		case 'd', 'i':
+			n, err := checkInteger("format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(fmt.Sprintf("%"+spec+"d", n))
+		case 'u':
+			n, err := checkInteger("format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(fmt.Sprintf("%"+spec+"d", uint64(n)))
+		case 'o', 'x', 'X':
+			n, err := checkInteger("format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(fmt.Sprintf("%"+spec+string(conv), uint64(n)))
+		case 'a', 'A':
+			n, err := checkNumber("format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(formatFloat(spec, conv, n))
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			n, err := checkNumber("format", args, arg)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(formatFloat(spec, conv, n))
+		case 'q':
+			if err := e.addQuoted(&buf, args[arg-1]); err != nil {
+				return nil, argError(arg, "format", err.Error())
+			}
+		case 's':
+			results, err := e.tostring(args[arg-1])
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(fmt.Sprintf("%"+spec+"s", results[0].(value.String).String()))
+		default:
+			return nil, Error{Message: value.NewString(fmt.Sprintf("invalid option '%%%c' to 'format'", conv))}
+		}
+	}
+	return values(value.NewString(buf.String())), nil
+}
+
+// skipDigits skips at most two digits in s, starting at i.
+func skipDigits(s string, i int) int {
+	for n := 0; n < 2 && i < len(s) && s[i] >= '0' && s[i] <= '9'; n++ {
+		i++
+	}
+	return i
+}
+
+var hexFloatExponent = regexp.MustCompile(`([pP][+-])0*(\d)`)
+
+// formatFloat formats the given number like C's printf would, with the given
+// flags, width and precision (spec) and conversion.
+func formatFloat(spec string, conv byte, n float64) string {
+	if math.IsInf(n, 0) || math.IsNaN(n) {
+		var s string
+		switch {
+		case math.IsNaN(n):
+			s = "nan"
+		case n < 0:
+			s = "-inf"
+		case strings.ContainsRune(spec, '+'):
+			s = "+inf"
+		case strings.ContainsRune(spec, ' '):
+			s = " inf"
+		default:
+			s = "inf"
+		}
+		if conv >= 'A' && conv <= 'Z' {
+			s = strings.ToUpper(s)
+		}
+		// C doesn't pad inf and nan with zeros
+		return fmt.Sprintf("%"+strings.Replace(spec, "0", "", 1)+"s", s)
+	}
+
+	switch conv {
+	case 'a', 'A':
+		verb := "x"
+		if conv == 'A' {
+			verb = "X"
+		}
+		// Go prints the exponent with at least two digits, C doesn't
+		return hexFloatExponent.ReplaceAllString(fmt.Sprintf("%"+spec+verb, n), "$1$2")
+	case 'g', 'G':
+		if !strings.ContainsRune(spec, '.') {
+			// Go's default precision for %g is the smallest number of
+			// digits necessary, C's is 6
+			spec += ".6"
+		}
+	}
+	return fmt.Sprintf("%"+spec+string(conv), n)
+}
+
+// addQuoted writes the given value into the buffer, in a form that can be
+// read back by Lua.
+func (e *Engine) addQuoted(buf *strings.Builder, val value.Value) error {
+	switch v := val.(type) {
+	case value.String:
+		s := string(v)
+		buf.WriteByte('"')
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			switch {
+			case c == '"' || c == '\\' || c == '\n':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case c < 0x20 || c == 0x7f:
+				if i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9' {
+					buf.WriteString(fmt.Sprintf("\\%03d", c))
+				} else {
+					buf.WriteString(fmt.Sprintf("\\%d", c))
+				}
+			default:
+				buf.WriteByte(c)
+			}
+		}
+		buf.WriteByte('"')
+	case value.Number:
+		n := float64(v)
+		switch {
+		case math.IsInf(n, 1):
+			buf.WriteString("1e9999")
+		case math.IsInf(n, -1):
+			buf.WriteString("-1e9999")
+		case math.IsNaN(n):
+			buf.WriteString("(0/0)")
+		default:
+			buf.WriteString(formatFloat("", 'a', n))
+		}
+	case value.Integer:
+		if v == math.MinInt64 {
+			// the minimum integer can't be written as decimal literal
+			buf.WriteString("0x8000000000000000")
+		} else {
+			buf.WriteString(v.String())
+		}
+	case value.Boolean:
+		buf.WriteString(strconv.FormatBool(bool(v)))
+	default:
+		if val == nil || val == value.Nil {
+			buf.WriteString("nil")
+			return nil
+		}
+		return fmt.Errorf("value has no literal form")
+	}
+	return nil
+}
@@ -0,0 +1,494 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// Operations that the table functions need to perform on their table
+// arguments. A value that is not a table is accepted, if its metatable
+// provides the metamethods for all required operations.
+const (
+	tableRead   = 1 << iota // __index
+	tableWrite              // __newindex
+	tableLength             // __len
+)
+
+const (
+	// maxUnpack is the maximum number of values that table.unpack returns.
+	// Like LUAI_MAXSTACK in the reference implementation, it prevents
+	// allocating huge result lists, e.g. for table.unpack({}, 1, 1e8).
+	maxUnpack = 1000000
+	// maxSort is the maximum number of elements that table.sort sorts.
+	maxSort = math.MaxInt32
+)
+
+// checkTable checks that the n-th (1-based) argument is a table, or behaves
+// like one for the given operations.
+func (e *Engine) checkTable(fnName string, args []value.Value, n int, what int) error {
+	if n <= len(args) {
+		if _, ok := args[n-1].(*value.Table); ok {
+			return nil
+		}
+		if e.hasTableMetaMethods(args[n-1], what) {
+			return nil
+		}
+	}
+	return typeArgError(n, fnName, "table", args)
+}
+
+func (e *Engine) hasTableMetaMethods(val value.Value, what int) bool {
+	if e.isNil(val) {
+		return false
+	}
+	for _, op := range []struct {
+		flag  int
+		event string
+	}{
+		{tableRead, "__index"},
+		{tableWrite, "__newindex"},
+		{tableLength, "__len"},
+	} {
+		if what&op.flag == 0 {
+			continue
+		}
+		if mm, err := e.metaMethod(val, op.event); err != nil || e.isNil(mm) {
+			return false
+		}
+	}
+	return true
+}
+
+// geti returns t[i], respecting the __index metamethod.
+func (e *Engine) geti(t value.Value, i int64) (value.Value, error) {
+	results, err := e.performIndexOperation(t, value.NewInteger(i))
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return value.Nil, nil
+	}
+	return results[0], nil
+}
+
+// seti performs t[i] = val, respecting the __newindex metamethod.
+func (e *Engine) seti(t value.Value, i int64, val value.Value) error {
+	return e.performCreateIndex(t, value.NewInteger(i), val)
+}
+
+// lengthOf returns the length of the given value as integer, respecting the
+// __len metamethod.
+func (e *Engine) lengthOf(val value.Value) (int64, error) {
+	results, err := e.length(val)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) > 0 {
+		if n, ok := toInteger(results[0]); ok {
+			return n, nil
+		}
+	}
+	return 0, Error{Message: value.NewString("object length is not an integer")}
+}
+
+// tableLen checks the n-th argument with checkTable and returns its length.
+func (e *Engine) tableLen(fnName string, args []value.Value, n int, what int) (int64, error) {
+	if err := e.checkTable(fnName, args, n, what|tableLength); err != nil {
+		return 0, err
+	}
+	return e.lengthOf(args[n-1])
+}
+
+func (e *Engine) tableConcat(args ...value.Value) ([]value.Value, error) {
+	last, err := e.tableLen("concat", args, 1, tableRead)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := optString("concat", args, 2, "")
+	if err != nil {
+		return nil, err
+	}
+	i, err := optInteger("concat", args, 3, 1)
+	if err != nil {
+		return nil, err
+	}
+	last, err = optInteger("concat", args, 4, last)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf strings.Builder
+	for ; i <= last; i++ {
+		val, err := e.geti(args[0], i)
+		if err != nil {
+			return nil, err
+		}
+		switch v := val.(type) {
+		case value.String:
+			buf.WriteString(string(v))
+		case value.Number:
+			buf.WriteString(v.String())
+		case value.Integer:
+			buf.WriteString(v.String())
+		default:
+			return nil, Error{Message: value.NewString(fmt.Sprintf("invalid value (at index %d) in table for 'concat'", i))}
+		}
+		if i != last {
+			buf.WriteString(sep)
+		}
+		if i == math.MaxInt64 {
+			break
+		}
+	}
+	return values(value.NewString(buf.String())), nil
+}
+
+func (e *Engine) tableInsert(args ...value.Value) ([]value.Value, error) {
+	size, err := e.tableLen("insert", args, 1, tableRead|tableWrite)
+	if err != nil {
+		return nil, err
+	}
+	first := size + 1 // first empty element
+	var pos int64
+	switch len(args) {
+	case 2:
+		pos = first
+	case 3:
+		pos, err = checkInteger("insert", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		// check whether 'pos' is in [1, first]
+		if uint64(pos)-1 >= uint64(first) {
+			return nil, argError(2, "insert", "position out of bounds")
+		}
+		// move up elements
+		for i := first; i > pos; i-- {
+			val, err := e.geti(args[0], i-1)
+			if err != nil {
+				return nil, err
+			}
+			if err := e.seti(args[0], i, val); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, Error{Message: value.NewString("wrong number of arguments to 'insert'")}
+	}
+	if err := e.seti(args[0], pos, args[len(args)-1]); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (e *Engine) tableMove(args ...value.Value) ([]value.Value, error) {
+	from, err := checkInteger("move", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	end, err := checkInteger("move", args, 3)
+	if err != nil {
+		return nil, err
+	}
+	to, err := checkInteger("move", args, 4)
+	if err != nil {
+		return nil, err
+	}
+	destArg := 1
+	if !isNoneOrNil(args, 5) {
+		destArg = 5
+	}
+	if err := e.checkTable("move", args, 1, tableRead); err != nil {
+		return nil, err
+	}
+	if err := e.checkTable("move", args, destArg, tableWrite); err != nil {
+		return nil, err
+	}
+	src, dest := args[0], args[destArg-1]
+
+	if end >= from {
+		if !(from > 0 || end < math.MaxInt64+from) {
+			return nil, argError(3, "move", "too many elements to move")
+		}
+		n := end - from + 1 // number of elements to move
+		if to > math.MaxInt64-n+1 {
+			return nil, argError(4, "move", "destination wrap around")
+		}
+
+		move := func(i int64) error {
+			val, err := e.geti(src, from+i)
+			if err != nil {
+				return err
+			}
+			return e.seti(dest, to+i, val)
+		}
+		// copy forwards, unless the ranges overlap and the destination
+		// lies behind the start of the source
+		if to > end || to <= from || (destArg != 1 && src != dest) {
+			for i := int64(0); i < n; i++ {
+				if err := move(i); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			for i := n - 1; i >= 0; i-- {
+				if err := move(i); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return values(dest), nil
+}
+
+func (e *Engine) tablePack(args ...value.Value) ([]value.Value, error) {
+	t := value.NewTable()
+	for i, arg := range args {
+		t.Set(value.NewInteger(int64(i+1)), arg)
+	}
+	t.Set(value.NewString("n"), value.NewInteger(int64(len(args))))
+	return values(t), nil
+}
+
+func (e *Engine) tableRemove(args ...value.Value) ([]value.Value, error) {
+	size, err := e.tableLen("remove", args, 1, tableRead|tableWrite)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := optInteger("remove", args, 2, size)
+	if err != nil {
+		return nil, err
+	}
+	if pos != size {
+		// validate 'pos' if given, it may be size+1
+		if uint64(pos)-1 > uint64(size) {
+			return nil, argError(1, "remove", "position out of bounds")
+		}
+	}
+
+	result, err := e.geti(args[0], pos)
+	if err != nil {
+		return nil, err
+	}
+	for ; pos < size; pos++ {
+		val, err := e.geti(args[0], pos+1)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.seti(args[0], pos, val); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.seti(args[0], pos, value.Nil); err != nil {
+		return nil, err
+	}
+	return values(result), nil
+}
+
+func (e *Engine) tableUnpack(args ...value.Value) ([]value.Value, error) {
+	i, err := optInteger("unpack", args, 2, 1)
+	if err != nil {
+		return nil, err
+	}
+	var last int64
+	if isNoneOrNil(args, 3) {
+		if len(args) == 0 {
+			return nil, typeArgError(1, "unpack", "table", args)
+		}
+		last, err = e.lengthOf(args[0])
+	} else {
+		last, err = checkInteger("unpack", args, 3)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if i > last {
+		return nil, nil
+	}
+	n := uint64(last) - uint64(i) // number of elements minus 1
+	if n >= maxUnpack {
+		return nil, Error{Message: value.NewString("too many results to unpack")}
+	}
+
+	results := make([]value.Value, 0, n+1)
+	for ; ; i++ {
+		val, err := e.geti(args[0], i)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, val)
+		if i == last {
+			break
+		}
+	}
+	return results, nil
+}
+
+func (e *Engine) tableSort(args ...value.Value) ([]value.Value, error) {
+	n, err := e.tableLen("sort", args, 1, tableRead|tableWrite)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 1 {
+		return nil, nil
+	}
+	if n >= maxSort {
+		return nil, argError(1, "sort", "array too big")
+	}
+
+	var comp *value.Function
+	if !isNoneOrNil(args, 2) {
+		fn, ok := args[1].(*value.Function)
+		if !ok {
+			return nil, typeArgError(2, "sort", "function", args)
+		}
+		comp = fn
+	}
+
+	elems := make([]value.Value, n)
+	for i := range elems {
+		if elems[i], err = e.geti(args[0], int64(i+1)); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &sorter{e: e, elems: elems, comp: comp}
+	if err := s.sort(0, len(elems)-1); err != nil {
+		return nil, err
+	}
+
+	for i, elem := range elems {
+		if err := e.seti(args[0], int64(i+1), elem); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// sorter implements the quicksort of table.sort, which is the same algorithm
+// as the one of the reference implementation, so that invalid order functions
+// are detected in the same situations.
+type sorter struct {
+	e     *Engine
+	elems []value.Value
+	comp  *value.Function
+}
+
+func (s *sorter) less(a, b value.Value) (bool, error) {
+	var results []value.Value
+	var err error
+	if s.comp != nil {
+		results, err = s.e.call(s.comp, a, b)
+	} else {
+		results, err = s.e.evaluateLess(a, b)
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(results) > 0 && s.e.valueIsLogicallyTrue(results[0]), nil
+}
+
+func (s *sorter) swap(i, j int) {
+	s.elems[i], s.elems[j] = s.elems[j], s.elems[i]
+}
+
+// lessAt compares the elements at the given indices.
+func (s *sorter) lessAt(i, j int) (bool, error) {
+	return s.less(s.elems[i], s.elems[j])
+}
+
+func (s *sorter) sort(lo, up int) error {
+	for lo < up { // loop for tail recursion
+		// sort elements lo, (lo+up)/2 and up
+		if less, err := s.lessAt(up, lo); err != nil {
+			return err
+		} else if less {
+			s.swap(lo, up)
+		}
+		if up-lo == 1 {
+			break // only 2 elements
+		}
+		p := lo + (up-lo)/2
+		if less, err := s.lessAt(p, lo); err != nil {
+			return err
+		} else if less {
+			s.swap(p, lo)
+		} else {
+			if less, err := s.lessAt(up, p); err != nil {
+				return err
+			} else if less {
+				s.swap(p, up)
+			}
+		}
+		if up-lo == 2 {
+			break // only 3 elements
+		}
+		// move the pivot to up-1 and partition the rest
+		s.swap(p, up-1)
+		p, err := s.partition(lo, up)
+		if err != nil {
+			return err
+		}
+		// recurse into the smaller half, loop over the larger one
+		if p-lo < up-p {
+			if err := s.sort(lo, p-1); err != nil {
+				return err
+			}
+			lo = p + 1
+		} else {
+			if err := s.sort(p+1, up); err != nil {
+				return err
+			}
+			up = p - 1
+		}
+	}
+	return nil
+}
+
+// partition partitions the elements from lo to up around the pivot at up-1,
+// and returns the final position of the pivot.
+func (s *sorter) partition(lo, up int) (int, error) {
+	pivot := s.elems[up-1]
+	i, j := lo, up-1
+	for {
+		// next loop: repeat ++i while elems[i] < pivot
+		for {
+			i++
+			less, err := s.less(s.elems[i], pivot)
+			if err != nil {
+				return 0, err
+			}
+			if !less {
+				break
+			}
+			if i == up-1 {
+				return 0, errInvalidOrderFunction
+			}
+		}
+		// next loop: repeat --j while pivot < elems[j]
+		for {
+			j--
+			less, err := s.less(pivot, s.elems[j])
+			if err != nil {
+				return 0, err
+			}
+			if !less {
+				break
+			}
+			if j < i {
+				return 0, errInvalidOrderFunction
+			}
+		}
+		if j < i {
+			// swap the pivot with elems[i]
+			s.swap(up-1, i)
+			return i, nil
+		}
+		s.swap(i, j)
+	}
+}
+
+var errInvalidOrderFunction = Error{Message: value.NewString("invalid order function for sorting")}
@@ -0,0 +1,66 @@
+local function none() end
+local function one() return 1 end
+local function three() return 1, 2, 3 end
+
+-- calls
+print(three(), 10)
+print(10, three())
+print(none(), 10)
+print(10, none())
+print((three()))
+print(select("#", none(), none()), select("#", none()))
+
+-- assignments
+local a, b, c, d
+a, b, c, d = three()
+print(a, b, c, d)
+a, b, c, d = 0, three()
+print(a, b, c, d)
+a, b, c, d = three(), 0
+print(a, b, c, d)
+a, b = 1, 2, 3
+print(a, b)
+a, b = none()
+print(a, b)
+
+-- locals
+local e, f, g
+print(e, f, g)
+local h, i, j = three()
+print(h, i, j)
+local k, l, m = three(), 10
+print(k, l, m)
+local n, o = 1, 2, 3
+print(n, o)
+local p = none()
+print(p)
+local q = 5
+do
+    local q, r = q + 1, q
+    print(q, r)
+end
+
+-- returns
+local function ret1() return three(), 10 end
+local function ret2() return 10, three() end
+local function ret3() return (three()) end
+print(ret1())
+print(ret2())
+print(ret3())
+
+-- table constructors
+local t = { three(), three() }
+print(#t, t[1], t[2], t[4])
+t = { three(), three(), x = 1 }
+print(#t)
+t = { none(), none() }
+print(#t)
+t = { [one()] = three() }
+print(t[1], #t)
+t = { (three()) }
+print(#t)
+
+-- expressions
+print(three() + 1, -three(), none() == nil, not none())
+print(pcall(function() return none() + 1 end))
+print(("x"):rep(three()))
\ No newline at end of file
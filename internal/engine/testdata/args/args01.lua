@@ -0,0 +1,8 @@
+function component(spec)
+    return spec.name .. ":" .. spec.kind .. ":" .. spec[1]
+end
+
+print(component{ name = "x", kind = "button", "first" })
+print(component { name = "y", kind = "label", "second" })
+local value = "positional"
+print(component{ name = "z", kind = value, value })
\ No newline at end of file
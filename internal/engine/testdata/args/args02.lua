@@ -0,0 +1,15 @@
+function prefix(p)
+    return function(t)
+        return p .. t[1]
+    end
+end
+
+function collect(a)
+    return function(b)
+        return a[1] .. b[1]
+    end
+end
+
+print(prefix"pre-"{ "fix" })
+print(collect{ "a" }{ "b" })
+print(type{})
\ No newline at end of file
@@ -0,0 +1,14 @@
+local obj = {}
+
+function obj:describe(t)
+    return self.kind .. " with " .. t.size
+end
+
+function obj:greet(name)
+    return "hello " .. name
+end
+
+obj.kind = "box"
+print(obj:describe{ size = "large" })
+print(obj:greet"world")
+print "done"
\ No newline at end of file
@@ -0,0 +1,6 @@
+local x
+print(x and x.y, x or "default")
+local function fail() error("evaluated") end
+print(false and fail(), nil and fail(), true or fail(), 1 or fail())
+print(1 and 2, nil and 2, false or nil, nil or false)
+print(pcall(function() return true and fail() end))
\ No newline at end of file
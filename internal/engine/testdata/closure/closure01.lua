@@ -0,0 +1,14 @@
+function counter()
+    local count = 0
+    return function()
+        count = count + 1
+        return count
+    end
+end
+
+local c1 = counter()
+local c2 = counter()
+print(c1())
+print(c1())
+print(c2())
+print(c1())
\ No newline at end of file
@@ -0,0 +1,16 @@
+function pair()
+    local value = 1
+    local p = {}
+    p.get = function()
+        return value
+    end
+    p.set = function(v)
+        value = v
+    end
+    return p
+end
+
+local p = pair()
+print(p.get())
+p.set(5)
+print(p.get())
\ No newline at end of file
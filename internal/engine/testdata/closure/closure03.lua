@@ -0,0 +1,9 @@
+local fns = {}
+for i = 1, 3 do
+    fns[i] = function()
+        return i
+    end
+end
+print(fns[1]())
+print(fns[2]())
+print(fns[3]())
\ No newline at end of file
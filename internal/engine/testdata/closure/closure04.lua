@@ -0,0 +1,14 @@
+local x = "outer"
+
+function show()
+    print(x)
+end
+
+function shadow()
+    local x = "inner"
+    show()
+end
+
+shadow()
+x = "changed"
+show()
\ No newline at end of file
@@ -0,0 +1,34 @@
+-- a closure keeps referring to the variable it captured, even if a
+-- later local with the same name shadows it
+local x = 1
+local f = function() return x end
+local x = 2
+print(f(), x)
+
+-- locals declared after a closure was created are not visible in it
+local g = function() return y end
+local y = 5
+print(g())
+
+-- assignments to a captured variable are shared
+local n = 0
+local inc = function() n = n + 1 end
+local get = function() return n end
+inc()
+inc()
+print(get(), n)
+
+-- every iteration of a loop declares new variables
+local fns = {}
+for i = 1, 3 do
+    local j = i * 10
+    fns[i] = function() return i, j end
+end
+print(fns[1](), fns[3]())
+
+-- a local function can refer to itself
+local function fact(k)
+    if k <= 1 then return 1 end
+    return k * fact(k - 1)
+end
+print(fact(5))
\ No newline at end of file
@@ -0,0 +1,15 @@
+local co = coroutine.create(function(a, b)
+    print("start", a, b)
+    local c = coroutine.yield(a + b)
+    print("got", c)
+    print("got", coroutine.yield(c * 2))
+    return "finished"
+end)
+
+print(coroutine.status(co))
+print(coroutine.resume(co, 1, 2))
+print(coroutine.status(co))
+print(coroutine.resume(co, 10))
+print(coroutine.resume(co, "x", "y"))
+print(coroutine.status(co))
+print(coroutine.resume(co))
\ No newline at end of file
@@ -0,0 +1,11 @@
+function range(n)
+    return coroutine.wrap(function()
+        for i = 1, n do
+            coroutine.yield(i)
+        end
+    end)
+end
+
+for i in range(3) do
+    print(i)
+end
\ No newline at end of file
@@ -0,0 +1,13 @@
+local co = coroutine.create(function()
+    print(pcall(function()
+        coroutine.yield("inside pcall")
+        error("failure after yield")
+    end))
+    coroutine.yield("after pcall")
+    error("unprotected")
+end)
+
+print(coroutine.resume(co))
+print(coroutine.resume(co))
+print(coroutine.resume(co))
+print(coroutine.status(co))
\ No newline at end of file
@@ -0,0 +1,16 @@
+print(coroutine.isyieldable())
+print(type(coroutine.running()))
+print(select(2, coroutine.running()))
+
+co = coroutine.create(function()
+    print(coroutine.isyieldable())
+    print(coroutine.running() == co)
+    print(select(2, coroutine.running()))
+    print(coroutine.status(co))
+    local inner = coroutine.create(function()
+        print(coroutine.status(co))
+    end)
+    coroutine.resume(inner)
+end)
+coroutine.resume(co)
+print(pcall(coroutine.yield, 1))
\ No newline at end of file
@@ -0,0 +1,17 @@
+print(pcall(coroutine.create))
+print(pcall(coroutine.create, 1))
+print(pcall(coroutine.resume))
+print(pcall(coroutine.resume, 1))
+print(pcall(coroutine.status, {}))
+print(pcall(coroutine.wrap, "f"))
+
+local co = coroutine.create(function() end)
+coroutine.resume(co)
+print(coroutine.resume(co))
+local f = coroutine.wrap(function() end)
+f()
+print(pcall(f))
+
+local self
+self = coroutine.create(function() return coroutine.resume(self) end)
+print(coroutine.resume(self))
\ No newline at end of file
@@ -0,0 +1,54 @@
+-- _G is a regular global, that refers to the global table
+print(_G._G == _G, _ENV == _G, _G.print == print)
+x = 1
+print(_G.x, _ENV.x, rawget(_G, "x"))
+
+-- free names are resolved through the local _ENV
+do
+    local print = print
+    local _ENV = {y = 2}
+    x = 3
+    print(x, y, _ENV.x)
+end
+print(x, y)
+
+-- _ENV can be a parameter
+local function get(_ENV, ...)
+    return a, b
+end
+print(get({a = "a", b = "b"}))
+
+-- closures keep the _ENV they were created in
+local function sandbox(env)
+    local _ENV = env
+    return function(v) value = v return value end
+end
+local sandboxed = {}
+local set = sandbox(sandboxed)
+print(set(5), sandboxed.value, value)
+
+-- the environment is indexed like any other table
+local fallback = setmetatable({}, {__index = _G})
+do
+    local _ENV = fallback
+    z = tostring(10)
+end
+print(z, fallback.z)
+
+setmetatable(_G, {__newindex = function(t, k, v)
+    error("assignment to undeclared global " .. k, 0)
+end})
+print(pcall(function() undeclared = 1 end))
+x = 4 -- existing globals can still be assigned
+setmetatable(_G, nil)
+print(x, undeclared)
+
+-- rebinding _ENV changes the environment of the rest of the chunk
+local chunk = load([[
+local t = {}
+_ENV = t
+w = 1
+return t
+]])
+local t = chunk()
+print(t.w, w)
\ No newline at end of file
@@ -0,0 +1,6 @@
+local function f() end
+local function g() end
+local h = f
+print(f == f, f == g, f == h, f ~= g)
+print(print == print, print == type)
+print(f == nil, f == "f", {} == f)
\ No newline at end of file
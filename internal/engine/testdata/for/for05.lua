@@ -0,0 +1,27 @@
+-- the explist of a generic for is adjusted to three values
+local function count(n)
+    local i = 0
+    return function()
+        i = i + 1
+        if i <= n then
+            return i
+        end
+    end
+end
+for i in count(3) do
+    print(i)
+end
+
+-- the iterator can be any callable value
+local callable = setmetatable({}, {__call = function(self, state, last)
+    if last < state then
+        return last + 1
+    end
+end})
+for i in callable, 2, 0 do
+    print(i)
+end
+
+print(pcall(function()
+    for i in nil do end
+end))
\ No newline at end of file
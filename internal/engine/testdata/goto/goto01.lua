@@ -0,0 +1,68 @@
+-- continue
+for i = 1, 5 do
+    local odd = i % 2 == 1
+    if odd then goto continue end
+    local half = i // 2
+    print(i, half)
+    ::continue::
+end
+
+local i = 0
+while i < 4 do
+    i = i + 1
+    if i == 2 then goto continue end
+    print(i)
+    ::continue::
+end
+
+i = 0
+repeat
+    i = i + 1
+    if i ~= 3 then goto continue end
+    print("three")
+    ::continue::
+until i >= 4
+
+-- leaving nested loops
+for x = 1, 3 do
+    for y = 1, 3 do
+        if x * y == 4 then
+            print("found", x, y)
+            goto found
+        end
+    end
+end
+::found::
+
+-- a loop built with goto, every iteration has a fresh local
+local fns = {}
+local n = 1
+::top::
+do
+    local captured = n
+    fns[n] = function() return captured end
+end
+local after = n * 10
+n = n + 1
+if n <= 3 then goto top end
+print(fns[1](), fns[2](), fns[3](), after)
+
+-- locals declared after a label are left when jumping back to it
+local count = 0
+::again::
+local seen = seen
+count = count + 1
+if count < 3 then
+    seen = count
+    goto again
+end
+print(count, seen)
+
+-- goto in a nested function does not see the labels of the caller
+local function f(k)
+    if k > 0 then goto positive end
+    do return "not positive" end
+    ::positive::
+    return "positive"
+end
+print(f(1), f(0))
\ No newline at end of file
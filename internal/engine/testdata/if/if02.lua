@@ -0,0 +1,16 @@
+function classify(n)
+    if n < 0 then
+        return "negative"
+    elseif n == 0 then
+        return "zero"
+    elseif n < 10 then
+        return "small"
+    else
+        return "large"
+    end
+end
+
+print(classify(-5))
+print(classify(0))
+print(classify(7))
+print(classify(42))
\ No newline at end of file
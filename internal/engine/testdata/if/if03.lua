@@ -0,0 +1,21 @@
+function check(a, b)
+    if a then
+        if b then
+            print("a and b")
+        elseif b == false then
+            print("a and not b")
+        end
+    elseif b then
+        if a == nil then
+            print("b and a is nil")
+        else
+            print("b and a is false")
+        end
+    end
+end
+
+check(true, true)
+check(true, false)
+check(nil, true)
+check(false, true)
+check(false, false)
\ No newline at end of file
@@ -0,0 +1,17 @@
+function cond(name, result)
+    print(name)
+    return result
+end
+
+local x = "outer"
+if cond("if", false) then
+    local x = "if"
+elseif cond("elseif 1", true) then
+    local x = "elseif 1"
+    print(x)
+elseif cond("elseif 2", true) then
+    local x = "elseif 2"
+else
+    local x = "else"
+end
+print(x)
\ No newline at end of file
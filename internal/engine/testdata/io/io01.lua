@@ -0,0 +1,35 @@
+local f = assert(io.open("data.txt"))
+print(io.type(f), io.type(io.stdout), io.type(42))
+print(f:read())
+print(f:read("L"))
+print(f:read("n", "n", "n", "n"))
+print(f:read(1), f:read("l"))
+print(f:read(2), f:read(0), f:read("a"))
+print(f:read("l"), f:read(0), f:read("a"))
+
+print(f:seek("set", 6))
+print(f:read(4))
+print(f:seek())
+print(f:seek("end"))
+print(pcall(f.seek, f, "middle"))
+print(f:setvbuf("no"))
+print(f:write("x"))
+print(f:close())
+print(io.type(f), tostring(f))
+print(pcall(f.read, f))
+
+for line in io.lines("data.txt") do
+    io.write("[", line, "]")
+end
+io.write("\n")
+for a, b in io.lines("data.txt", 6, "l") do
+    print(a, b)
+end
+
+print(io.open("missing.txt"))
+print(pcall(io.open, "data.txt", "rw"))
+print(pcall(io.lines, "missing.txt"))
+print(io.write(1, " ", 2.5, "\n") == io.stdout)
+print(pcall(io.write, {}))
+print(io.stdout:close())
+print(io.output() == io.stdout, io.input() == io.stdin)
\ No newline at end of file
@@ -0,0 +1,33 @@
+-- a loaded chunk is not evaluated until it is called
+local f = load("x = 1 return ...")
+print(type(f), x)
+print(f(2, 3))
+print(x)
+
+-- a custom environment holds the globals of the chunk
+local env = {print = print, y = "env"}
+y = "global"
+local g = load("print(y) y = 2 z = 3 return function() return y end", "chunk", "t", env)
+local getY = g()
+print(y, z, env.y, env.z, getY())
+env.y = 4
+print(getY())
+
+-- a reader function provides the source in pieces
+local pieces = {"return ", 1, " + 2"}
+local i = 0
+local h = load(function()
+    i = i + 1
+    return pieces[i]
+end)
+print(h())
+
+-- errors are returned, not raised
+local fn, msg = load("return +")
+print(fn, type(msg))
+print(load("return 1", "chunk", "b"))
+print(load(function() return {} end))
+print(load(function() error("reader failed") end))
+
+-- an explicit nil is a valid environment
+print(pcall(load("return x", "chunk", "t", nil)))
\ No newline at end of file
@@ -0,0 +1,5 @@
+local env = {}
+local f = loadfile("include01.lua", "t", env)
+print(f(5), name, env.name)
+print(loadfile("missing.lua"))
+print(loadfile("include01.lua", "b"))
\ No newline at end of file
@@ -0,0 +1,16 @@
+print(math.abs(-3), math.ceil(3.2), math.floor(-3.2), math.floor(3))
+print(math.fmod(7, 3), math.fmod(-7, 3), math.fmod(7.5, 2))
+print(pcall(math.fmod, 1, 0))
+print(string.format("%.2f %.2f", math.modf(3.75)))
+print(string.format("%.2f %.2f", math.modf(-3.75)))
+print(math.sqrt(16) == 4, math.exp(0) == 1, math.log(1) == 0, math.log(8, 2) == 3, math.log(100, 10) == 2)
+print(string.format("%.4f %.4f %.4f", math.sin(math.pi / 2), math.cos(0), math.tan(0)))
+print(string.format("%.4f %.4f %.4f %.4f", math.asin(1), math.acos(1), math.atan(1), math.atan(1, -1)))
+print(math.max(3, 7, 1), math.min(3, 7, 1), math.max(-1))
+print(pcall(math.max))
+print(pcall(math.min, 1, "x"))
+print(math.huge > 1e308, math.huge == math.huge * 2)
+print(math.tointeger(3), math.tointeger(3.5), math.tointeger("8"), math.tointeger({}))
+print(math.type(1), math.type(1.5), math.type("1"))
+print(math.ult(1, 2), math.ult(-1, 2), math.ult(2, -1))
+print(math.mininteger < 0, math.maxinteger > 0)
\ No newline at end of file
@@ -0,0 +1,18 @@
+local ok = true
+for i = 1, 100 do
+    local f = math.random()
+    local a = math.random(6)
+    local b = math.random(-3, 3)
+    if f < 0 or f >= 1 or a < 1 or a > 6 or b < -3 or b > 3 or math.type(a) ~= "integer" then
+        ok = false
+    end
+end
+print(ok)
+math.randomseed(42)
+local first = math.random(1000)
+math.randomseed(42)
+print(first == math.random(1000))
+print(math.random(5, 5))
+print(pcall(math.random, 2, 1))
+print(pcall(math.random, 0))
+print(pcall(math.random, 1, 2, 3))
\ No newline at end of file
@@ -0,0 +1,4 @@
+local t = setmetatable({}, { __metatable = "locked" })
+print(getmetatable(t))
+print(pcall(setmetatable, t, {}))
+print(getmetatable({}))
\ No newline at end of file
@@ -0,0 +1,7 @@
+a = { b = { c = {} } }
+
+function a.b.c.greet(name)
+    return "Hello, " .. name
+end
+
+print(a.b.c.greet("World"))
\ No newline at end of file
@@ -0,0 +1,10 @@
+counter = { count = 0 }
+
+function counter:increment(n)
+    self.count = self.count + n
+    return self
+end
+
+counter:increment(2)
+counter:increment(3):increment(4)
+print(counter.count)
\ No newline at end of file
@@ -0,0 +1,31 @@
+Animal = {}
+Animal.__index = Animal
+
+function Animal.new(name, sound)
+    local self = setmetatable({}, Animal)
+    self.name = name
+    self.sound = sound
+    return self
+end
+
+function Animal:speak()
+    return self.name .. " says " .. self.sound
+end
+
+Dog = setmetatable({}, { __index = Animal })
+Dog.__index = Dog
+
+function Dog.new(name)
+    local self = Animal.new(name, "woof")
+    return setmetatable(self, Dog)
+end
+
+function Dog:fetch()
+    return self.name .. " fetches"
+end
+
+local cat = Animal.new("Cat", "meow")
+local dog = Dog.new("Dog")
+print(cat:speak())
+print(dog:speak())
+print(dog:fetch())
\ No newline at end of file
@@ -0,0 +1,13 @@
+local obj = { value = "local" }
+
+function obj.nested()
+    return "nested"
+end
+
+function obj:get()
+    return self.value
+end
+
+print(obj.nested())
+print(obj:get())
+print(obj.get({ value = "explicit self" }))
\ No newline at end of file
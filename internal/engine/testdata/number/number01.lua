@@ -0,0 +1,12 @@
+print(1, 1.0, -0.0, 3 / 2, 4 / 2, 7 // 2, 7.0 // 2, -7 // 2)
+print(7 % 3, -7 % 3, 7 % -3, 5.5 % 2, 1 // 0.0, -1 // 0.0)
+print(2^53, math.maxinteger + 1 == math.mininteger, math.mininteger)
+print(1e15, 1e100, 0.1, 1/3, 100000000000000, 2^63)
+print(0x10, 0xff, 0xA.8p1, 0xffffffffffffffff, 1e2, 3e-2)
+print(1 == 1.0, math.type(1), math.type(1.0), math.type(0x10), math.type(1e2))
+print(9007199254740993 < 9007199254740992.0, math.maxinteger < 2^63, math.maxinteger + 0.0 == 2^63)
+print(3 | 5, 3 & 5, 3 ~ 5, ~0, 1 << 63, 1 << 64, -1 >> 1, 3.0 | 0)
+print("3" + 4, "3.0" + 4, "0x10" * 2, 1 .. "", 1.0 .. "", 10 // 3 .. "|" .. 10 / 2)
+for i = 1, 2 do print(i) end
+for i = 1.0, 2 do print(i) end
+for i = math.maxinteger - 1, math.maxinteger do print(i) end
\ No newline at end of file
@@ -0,0 +1,8 @@
+print(pcall(function() return 1 // 0 end))
+print(pcall(function() return 1 % 0 end))
+print(pcall(function() return 1.5 | 0 end))
+print(pcall(function() return "1.5" << 1 end))
+print(pcall(function() return {} + 1 end))
+print(pcall(function() return "a" | 1 end))
+print(pcall(function() return 1 < "2" end))
+print(pcall(function() for i = 1, "x" do end end))
\ No newline at end of file
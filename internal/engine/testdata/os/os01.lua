@@ -0,0 +1,24 @@
+-- the clock of the engine is fixed at 2020-12-01 19:27:43 UTC
+local now = os.time()
+print(now, math.type(now), os.clock())
+print(os.date("%Y-%m-%d %H:%M:%S"), os.date("!%c"), os.date())
+print(os.date("%a %A %b %B %h %d %e %j %m %y %Y %C"))
+print(os.date("%D|%F|%x|%X|%T|%R|%r|%I|%p|%z|%Z|%%|%Ey|%Od"))
+print(os.date("%u %w %U %W %V %G %g"))
+print(os.date("!%Y-%m-%d %H:%M:%S", 0), os.date("%d.%m.%Y", 86400 * 365))
+print(os.date("%n%t") == "\n\t")
+
+local t = os.date("*t")
+print(t.year, t.month, t.day, t.hour, t.min, t.sec, t.wday, t.yday, t.isdst)
+print(os.time(t) == now, os.time({year = 2020, month = 12, day = 1}) - now)
+
+-- out of range fields are normalized
+t = {year = 2020, month = 13, day = 32, hour = -1}
+print(os.time(t), t.year, t.month, t.day, t.hour, t.wday, t.yday)
+
+print(os.difftime(now, now - 90), os.difftime(5), os.difftime(1.5, 0))
+
+print(pcall(os.date, "%Q is not valid"))
+print(pcall(os.date, "%E"))
+print(pcall(os.time, {year = 2020, month = 1}))
+print(pcall(os.time, {year = 2020, month = "x", day = 1}))
\ No newline at end of file
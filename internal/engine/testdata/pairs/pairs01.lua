@@ -0,0 +1,36 @@
+local t = { 10, 20, 30, x = "a", y = "b" }
+t.z = "c"
+t[4] = 40
+local keys = {}
+for k, v in pairs(t) do
+    keys[#keys + 1] = tostring(k) .. "=" .. tostring(v)
+end
+print(table.concat(keys, " "))
+
+-- the traversal order is stable
+local first = {}
+for k in pairs(t) do first[#first + 1] = k end
+local second = {}
+for k in pairs(t) do second[#second + 1] = k end
+for i = 1, #first do assert(first[i] == second[i]) end
+print(#first, #second)
+
+-- next
+print(next({}))
+print(next({ "a" }))
+print(next({ "a" }, 1))
+local k = next(t)
+print(k, t[k], next(t, k))
+print(pcall(next, t, "nope"))
+print(pcall(next))
+print(pcall(pairs, nil))
+print(type(pairs(t)), select("#", pairs(t)))
+
+-- fields may be cleared during the traversal
+local u = { a = 1, b = 2, c = 3, d = 4 }
+local n = 0
+for k in pairs(u) do
+    u[k] = nil
+    n = n + 1
+end
+print(n, next(u))
\ No newline at end of file
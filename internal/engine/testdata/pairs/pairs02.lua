@@ -0,0 +1,21 @@
+local proxy = setmetatable({}, {
+    __pairs = function(t)
+        local i = 0
+        return function(_, k)
+            i = i + 1
+            if i <= 3 then
+                return i, i * i
+            end
+        end, t, nil
+    end
+})
+for k, v in pairs(proxy) do
+    print(k, v)
+end
+
+-- __pairs results are adjusted to three values
+local mt = { __pairs = function() return next, { "x" } end }
+print(select("#", pairs(setmetatable({}, mt))), select(3, pairs(setmetatable({}, mt))))
+for k, v in pairs(setmetatable({}, mt)) do
+    print(k, v)
+end
\ No newline at end of file
@@ -0,0 +1,20 @@
+local log = {}
+local t = setmetatable({}, {
+    __newindex = function(t, k, v) log[#log + 1] = k end,
+    __len = function() return 42 end,
+    __eq = function() return true end
+})
+t.a = 1
+print(rawset(t, "b", 2) == t, rawget(t, "a"), rawget(t, "b"), #log)
+rawset(t, "b", nil)
+print(rawget(t, "b"))
+print(pcall(rawset, "x", 1, 2))
+print(pcall(rawset, {}, 1))
+
+print(#t, rawlen(t), rawlen({ 1, 2, 3 }), rawlen("abcd"))
+print(pcall(rawlen, 5))
+
+local other = setmetatable({}, getmetatable(t))
+print(t == other, rawequal(t, other), rawequal(t, t))
+print(rawequal(1, 1.0), rawequal("a", "a"), rawequal(1, "1"), rawequal(nil, false))
+print(pcall(rawequal, 1))
\ No newline at end of file
@@ -0,0 +1 @@
+return +
\ No newline at end of file
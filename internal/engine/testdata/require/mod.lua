@@ -0,0 +1,3 @@
+loads = (loads or 0) + 1
+local name, filename = ...
+return {name = name, filename = filename}
\ No newline at end of file
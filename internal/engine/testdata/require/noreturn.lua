@@ -0,0 +1 @@
+noreturn = true
\ No newline at end of file
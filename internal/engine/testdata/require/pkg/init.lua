@@ -0,0 +1 @@
+return "pkg init"
\ No newline at end of file
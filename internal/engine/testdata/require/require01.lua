@@ -0,0 +1,21 @@
+local mod = require("mod")
+print(mod.name, mod.filename, loads)
+print(require("mod") == mod, loads, package.loaded.mod == mod)
+
+print(require("pkg"), require("sub.mod"))
+print(require("noreturn"), noreturn, package.loaded.noreturn)
+
+package.preload.preloaded = function(name, extra)
+    return "preloaded " .. name
+end
+print(require("preloaded"))
+
+-- the standard libraries are loaded already
+print(require("string") == string, package.loaded._G == _G, require("package") == package)
+
+print(package.searchpath("sub.mod", package.path))
+print(package.searchpath("a.b", "./?.x;?/y", ".", "_"))
+
+print(pcall(require, "missing"))
+local ok, err = pcall(require, "broken")
+print(ok, err:match("^error loading module 'broken' from file '%./broken%.lua'") ~= nil)
\ No newline at end of file
@@ -0,0 +1 @@
+return "sub.mod"
\ No newline at end of file
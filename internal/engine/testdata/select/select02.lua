@@ -0,0 +1,3 @@
+print(select(2, "a", "b", "c"))
+print(select(-1, "a", "b", "c"))
+print(select(5, "a", "b", "c"))
\ No newline at end of file
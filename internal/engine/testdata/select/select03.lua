@@ -0,0 +1,7 @@
+print(pcall(select, 0, "a"))
+print(pcall(select, -2, "a"))
+print(pcall(select, 1.5, "a"))
+print(pcall(select, "x"))
+print(pcall(select))
+print(select("2", "a", "b"))
+print(select(5, "a", "b"))
\ No newline at end of file
@@ -0,0 +1,7 @@
+print(string.len("abc"), ("abc"):len(), string.upper("aBc"), ("aBc"):lower())
+print(string.rep("ab", 3), string.rep("ab", 3, ","), string.rep("x", 0))
+print(string.reverse("abc"), string.sub("abcdef", 2, -2), string.sub("abc", -10, 10))
+print(string.byte("abc"), string.byte("abc", 1, -1))
+print(string.char(72, 105), pcall(string.char, 256))
+local s = "hello"
+print(s:sub(2), s:upper():lower())
\ No newline at end of file
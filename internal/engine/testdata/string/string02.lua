@@ -0,0 +1,14 @@
+local s = "hello world from lua"
+print(s:find("wor"))
+print(s:find("o", 6))
+print(s:find("(%w+) (%w+)"))
+print(s:find("l+"))
+print(s:find(".", 1, true))
+print(s:find("xyz"))
+print(s:match("(%w+) (%w+)$"))
+print(s:match("^(%w+)"))
+print(string.match("hello", "()ll()"))
+print(string.match("  key = value  ", "^%s*(%S+)%s*=%s*(%S+)%s*$"))
+print(string.match("f(a(b)c)d", "%b()"))
+print(string.match("2024-01-15", "(%d+)-(%d+)-(%d+)"))
+print(string.match("abcabc", "(a)(b)c%1%2"))
\ No newline at end of file
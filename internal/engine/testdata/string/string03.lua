@@ -0,0 +1,15 @@
+for word in string.gmatch("one two three", "%a+") do
+	print(word)
+end
+for k, v in string.gmatch("a=1, b=2", "(%w+)=(%w+)") do
+	print(k, v)
+end
+print(string.gsub("hello world", "o", "0"))
+print(string.gsub("hello world", "o", "0", 1))
+print(string.gsub("hello world", "(%w+)", "<%1>"))
+print(string.gsub("abc", "%w", "%0%0"))
+print(string.gsub("abc", "", "-"))
+print(string.gsub("$name is $age", "%$(%w+)", { name = "bob" }))
+print(string.gsub("abc", "%w", function(c) return c:upper() .. "." end))
+print(string.gsub("THE (quick) fox", "%f[%a]%a+", "W"))
+print(pcall(string.gsub, "abc", "b", "%2"))
\ No newline at end of file
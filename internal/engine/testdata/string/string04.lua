@@ -0,0 +1,10 @@
+print(string.format("%5.2f|%d|%x|%X|%o|%s|%5s|%-5s|", 3.14159, 42, 255, 255, 8, "x", "ab", "cd"))
+print(string.format("%g %g %g %e", 1e20, 0.1, 100000, 12345.678))
+print(string.format("%q", 'a\nb"c\\'))
+print(string.format("%q %q", 1, 0.5))
+print(string.format("%c%c%c", 76, 117, 97))
+print(string.format("%a", 1.5))
+print(string.format("%%|%05d|%+d|%.3s", 42, 5, "abcdef"))
+print(pcall(string.format, "%d", 1.5))
+print(pcall(string.format, "%d"))
+print(pcall(string.format, "%y", 1))
\ No newline at end of file
@@ -0,0 +1,7 @@
+print(pcall(string.find, "a", "[a"))
+print(pcall(string.find, "a", "%"))
+print(pcall(string.find, "a", "(a"))
+print(pcall(string.find, "a", "a)"))
+print(pcall(string.find, "a", "%1"))
+print(pcall(string.rep))
+print(pcall(string.sub, "a", {}))
\ No newline at end of file
@@ -0,0 +1,11 @@
+local p = string.pack("<i4", 100)
+print(#p, string.byte(p, 1, -1))
+print(string.unpack("<i4", p))
+print(string.byte(string.pack(">I2", 258), 1, -1))
+print(string.unpack(">h", string.pack(">h", -2)))
+print(string.packsize("i4i8"), string.packsize("!i1i8"), string.packsize("!4i1d"))
+print(string.unpack("z", string.pack("z", "hello")))
+print(string.unpack("s1", string.pack("s1", "abc")))
+print(string.unpack("<d", string.pack("<d", 1.5)))
+print(string.unpack("f", string.pack("f", 0.5)))
+print(string.unpack("c3", string.pack("c3", "ab")) == "ab\0")
\ No newline at end of file
@@ -0,0 +1,14 @@
+print(pcall(string.pack, "i1", 200))
+print(pcall(string.pack, "I1", -1))
+print(pcall(string.pack, "i17", 1))
+print(pcall(string.pack, "y", 1))
+print(pcall(string.pack, "c", "a"))
+print(pcall(string.packsize, "s"))
+print(pcall(string.unpack, "i4", "abc"))
+print(pcall(string.unpack, "z", "abc"))
+print(pcall(string.pack, "!3i4", 1))
+print(pcall(string.pack, "Xc1", 1))
+print(string.unpack("<i16", string.pack("<i16", -3)))
+print(pcall(string.unpack, "<I9", string.rep("\255", 9)))
+print(#string.pack("!8 b Xd", 1), string.unpack("B", "\200", 1))
+print(string.unpack("<i2 i2", "\1\0\2\0"))
\ No newline at end of file
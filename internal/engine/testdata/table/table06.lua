@@ -0,0 +1,19 @@
+local t = {1, 2, 3}
+table.insert(t, 4)
+table.insert(t, 1, 0)
+print(#t, table.concat(t, ","))
+print(table.remove(t), table.remove(t, 1), table.concat(t, ","))
+print(table.remove({}), #t)
+print(pcall(table.insert, t, 5, 9))
+print(pcall(table.insert, t))
+print(pcall(table.insert, nil, 1))
+print(table.concat({1, "a", 2.5}, "-"), table.concat({"a", "b", "c"}, "", 2, 3), table.concat({}, "x"))
+print(pcall(table.concat, {1, {}, 3}))
+print(table.unpack({1, 2, 3}))
+print(table.unpack({1, 2, 3}, 2))
+print(table.unpack({1, 2, 3}, 2, 5))
+local p = table.pack(1, nil, 3)
+print(p.n, p[1], p[2], p[3])
+print(table.concat(table.move({1, 2, 3, 4, 5}, 1, 3, 3), ","))
+print(table.concat(table.move({1, 2, 3, 4, 5}, 2, 5, 1), ","))
+print(table.concat(table.move({1, 2, 3}, 1, 3, 2, {}), ",", 2, 4))
\ No newline at end of file
@@ -0,0 +1,18 @@
+local t = {5, 2, 8, 1, 9, 3, 7, 4, 6, 10}
+table.sort(t)
+print(table.concat(t, " "))
+table.sort(t, function(a, b) return a > b end)
+print(table.concat(t, " "))
+local s = {"banana", "apple", "cherry"}
+table.sort(s)
+print(table.concat(s, " "))
+print(pcall(table.sort, {3, "a", 1}))
+print(pcall(table.sort, {1, 2, 3}, 1))
+print(pcall(table.sort, {5, 4, 3, 2, 1, 0}, function(a, b) return true end))
+
+local mt = {__lt = function(a, b) return a.v < b.v end}
+local function v(n) return setmetatable({v = n}, mt) end
+local o = {v(3), v(1), v(2)}
+table.sort(o)
+print(o[1].v, o[2].v, o[3].v)
+print(v(1) > v(2), v(2) > v(1))
\ No newline at end of file
@@ -0,0 +1,14 @@
+local log = {}
+local backing = {10, 20, 30}
+local proxy = setmetatable({}, {
+    __index = function(t, k) return backing[k] end,
+    __newindex = function(t, k, v) table.insert(log, k) backing[k] = v end,
+    __len = function() return #backing end
+})
+print(#proxy, table.concat(proxy, ","))
+table.insert(proxy, 40)
+print(table.concat(backing, ","), table.concat(log, ","))
+print(table.remove(proxy, 1), table.concat(backing, ","))
+print(table.unpack(proxy))
+table.sort(proxy, function(a, b) return a > b end)
+print(table.concat(backing, ","))
\ No newline at end of file
@@ -0,0 +1,47 @@
+print(#{}, #{ 1, 2, 3 }, #{ 1, 2, nil, x = 1 }, #{ n = 1 }, #{ nil })
+
+-- appending with the length operator
+local t = {}
+for i = 1, 100 do
+    t[#t + 1] = i
+end
+print(#t, t[1], t[100], t[101])
+
+-- removing from the end
+for i = 100, 51, -1 do
+    t[i] = nil
+end
+print(#t, t[50], t[51])
+
+-- filling a table in reverse order
+local r = {}
+for i = 10, 1, -1 do
+    r[i] = i * i
+end
+print(#r, r[1], r[10])
+local sum = 0
+for i, v in ipairs(r) do
+    sum = sum + v
+end
+print(sum)
+
+-- filling the gap of a table
+local g = { 1, 2 }
+g[4] = 4
+g[5] = 5
+print(g[4], rawlen({ 1, 2 }))
+g[3] = 3
+print(#g, g[5])
+
+-- integer keys outside of the sequence
+local h = { 1, 2, 3 }
+h[0] = 0
+h[-1] = -1
+h[1000] = 1000
+h[1.5] = 1.5
+print(#h, h[0], h[-1], h[1000], h[1.5])
+local keys = {}
+for k in pairs(h) do
+    keys[#keys + 1] = tostring(k)
+end
+print(table.concat(keys, " "))
\ No newline at end of file
@@ -0,0 +1,31 @@
+local t = {}
+t[1.0] = "a"
+t[2] = "b"
+print(t[1], t[2.0], #t, math.type(next(t)))
+t[2^53] = "big"
+print(t[2^53], t[math.tointeger(2^53)], t[9007199254740992])
+t[-0.0] = "zero"
+print(t[0], t[-0.0])
+t[1.5] = "float"
+print(t[1.5], t[1])
+print(rawget(t, 1.0), rawget(t, 2), rawlen(t))
+rawset(t, 3.0, "c")
+print(t[3], #t)
+local c = { [1.0] = "x", [2] = "y" }
+print(c[1], c[2.0], #c)
+
+print(t[nil], t[0/0])
+print(pcall(function() t[nil] = 1 end))
+print(pcall(function() t[0/0] = 1 end))
+print(pcall(function() t[nil] = nil end))
+print(pcall(rawset, t, nil, 1))
+print(pcall(rawset, t, 0/0, 1))
+print(pcall(function() return { [nil] = 1 } end))
+print(pcall(function() return { [0/0] = 1 } end))
+
+-- __newindex is consulted before the key is checked
+local log = {}
+local p = setmetatable({}, { __newindex = function(t, k, v) log[#log + 1] = type(k) end })
+p[0/0] = 1
+p[nil] = 1
+print(#log, log[1], log[2])
\ No newline at end of file
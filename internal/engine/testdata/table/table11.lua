@@ -0,0 +1,5 @@
+print(pcall(table.unpack, {}, 1, 1e8))
+print(pcall(table.unpack, {}, math.mininteger, math.maxinteger))
+print(pcall(table.unpack, {}, 1, 1000001))
+print(select("#", table.unpack({}, 1, 1000000)))
+print(table.unpack({1, 2}, math.maxinteger - 1, math.maxinteger))
\ No newline at end of file
@@ -0,0 +1,10 @@
+print(tonumber("10"), tonumber("10.0"), tonumber("  -7  "), tonumber("1e2"), tonumber(".5"))
+print(tonumber("0x10"), tonumber("0xA.8p1"), tonumber("-0x10"), tonumber("0x"), tonumber("1e"))
+print(tonumber(42), tonumber(4.5), tonumber(nil), tonumber({}), tonumber("abc"), tonumber(""))
+print(tonumber("ff", 16), tonumber("FF", 16), tonumber("-ff", 16), tonumber("777", 8), tonumber("zz", 36))
+print(tonumber("101", 2), tonumber("102", 2), tonumber(" 11 ", 10), tonumber("1.5", 10), tonumber("", 10))
+print(pcall(tonumber))
+print(pcall(tonumber, 10, 16))
+print(pcall(tonumber, "10", 1))
+print(pcall(tonumber, "10", 37))
+print(math.type(tonumber("3")), math.type(tonumber("3.0")), math.type(tonumber("10", 2)))
\ No newline at end of file
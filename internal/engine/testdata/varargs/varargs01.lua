@@ -0,0 +1,51 @@
+local function count(...)
+    return select("#", ...)
+end
+print(count(), count(nil), count(1, nil, 3), count(nil, nil))
+
+local function pass(...)
+    return ...
+end
+print(pass(1, 2, 3))
+print(pass())
+print((pass(1, 2, 3)))
+
+local function first(a, ...)
+    return a, select("#", ...)
+end
+print(first(1))
+print(first(1, 2, 3))
+
+local function pack(...)
+    return { ... }
+end
+local t = pack(1, 2, 3)
+print(#t, t[1], t[2], t[3])
+print(#pack())
+local p = table.pack(pass(1, nil, 3))
+print(p.n, p[1], p[2], p[3])
+
+local function forward(...)
+    return count(...), select(2, ...)
+end
+print(forward("a", "b", "c"))
+
+local function sum(...)
+    local s = 0
+    for _, v in ipairs({ ... }) do
+        s = s + v
+    end
+    return s
+end
+print(sum(), sum(1, 2, 3, 4))
+
+-- varargs are not shared with nested functions
+local function outer(...)
+    local inner = function(...)
+        return select("#", ...)
+    end
+    return inner(), inner(...)
+end
+print(outer(1, 2))
+
+print(select("#", ...))
\ No newline at end of file
@@ -2,14 +2,13 @@ package engine
 
 import (
 	"fmt"
-	"math"
 
 	. "github.com/tsatke/lua/internal/engine/value"
 )
 
 func (e *Engine) length(val Value) ([]Value, error) {
 	if val.Type() == TypeString {
-		return values(NewNumber(float64(len(val.(String).String())))), nil
+		return values(NewInteger(int64(len(val.(String).String())))), nil
 	}
 
 	// not a string, attempt metamethod
@@ -26,18 +25,27 @@ func (e *Engine) length(val Value) ([]Value, error) {
 		return values(val.(*Table).Length()), nil
 	}
 
-	return nil, fmt.Errorf("operand is not a string")
+	return e.error(NewString(fmt.Sprintf("attempt to get length of a %s value", val.Type().Name())))
 }
 
-func (e *Engine) bitwiseNot(val Value) ([]Value, error) {
-	if val.Type() != TypeNumber {
-		return nil, fmt.Errorf("operand is not a number")
+func (e *Engine) negate(val Value) ([]Value, error) {
+	num, ok := toNumberValue(val)
+	if !ok {
+		return e.error(NewString("attempt to perform arithmetic on a " + val.Type().Name() + " value"))
 	}
-
-	floatVal := val.(Number).Value()
-	if floatVal != math.Trunc(floatVal) {
-		return nil, fmt.Errorf("%v has no integral representation", floatVal)
+	if i, ok := num.(Integer); ok {
+		return values(-i), nil // wraps around for the minimum integer
 	}
+	return values(-num.(Number)), nil
+}
 
-	return values(NewNumber(float64(^int64(floatVal)))), nil
+func (e *Engine) bitwiseNot(val Value) ([]Value, error) {
+	if _, ok := toNumberValue(val); !ok {
+		return e.error(NewString("attempt to perform bitwise operation on a " + val.Type().Name() + " value"))
+	}
+	i, ok := toInteger(val)
+	if !ok {
+		return e.error(NewString("number has no integer representation"))
+	}
+	return values(NewInteger(^i)), nil
 }
@@ -5,6 +5,10 @@ type LuaFn func(...Value) ([]Value, error)
 type Function struct {
 	Name     string
 	Callable LuaFn
+
+	// Scopes are the scopes that this function closes over, innermost scope
+	// first. Functions implemented in Go don't have any scopes.
+	Scopes []*Scope
 }
 
 func NewFunction(name string, callable LuaFn) *Function {
@@ -14,6 +18,18 @@ func NewFunction(name string, callable LuaFn) *Function {
 	}
 }
 
+// NewClosure creates a new function that closes over the given scopes.
+// The variables in the scopes are shared with whoever else holds them,
+// which means that changes to a variable are visible to every closure
+// that captured that variable.
+func NewClosure(name string, callable LuaFn, scopes []*Scope) *Function {
+	return &Function{
+		Name:     name,
+		Callable: callable,
+		Scopes:   scopes,
+	}
+}
+
 func (Function) Type() Type { return TypeFunction }
 
 func (f Function) String() string {
@@ -3,19 +3,164 @@ package value
 import (
 	"math"
 	"strconv"
+	"strings"
 )
 
+// Number is the float subtype of a Lua number.
 type Number float64
 
 func (Number) Type() Type       { return TypeNumber }
 func (n Number) Value() float64 { return float64(n) }
-func (n Number) String() string { return strconv.FormatFloat(float64(n), 'G', -1, 64) }
+
+// String formats the number like Lua does, with 14 significant digits. A
+// float that looks like an integer is suffixed with ".0", to distinguish it
+// from an integer.
+func (n Number) String() string {
+	f := float64(n)
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		if math.Signbit(f) {
+			return "-nan"
+		}
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', 14, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
 
 func NewNumber(value float64) Number {
 	return Number(value)
 }
 
 func (n Number) Integral() (int64, bool) {
-	trunc := math.Trunc(float64(n))
-	return int64(trunc), trunc == float64(n)
+	return FloatToInteger(float64(n))
+}
+
+// Integer is the integer subtype of a Lua number.
+type Integer int64
+
+func (Integer) Type() Type       { return TypeNumber }
+func (i Integer) Value() int64   { return int64(i) }
+func (i Integer) String() string { return strconv.FormatInt(int64(i), 10) }
+
+func NewInteger(value int64) Integer {
+	return Integer(value)
+}
+
+// FloatToInteger converts the given float to an integer, if it has an exact
+// integer representation.
+func FloatToInteger(f float64) (int64, bool) {
+	// -2^63 is exactly representable as float, 2^63 is not a valid integer
+	if f != math.Floor(f) || f < math.MinInt64 || f >= -math.MinInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// ToFloat converts the given value to a float, if it is a number of either
+// subtype. Strings are not converted.
+func ToFloat(v Value) (float64, bool) {
+	switch n := v.(type) {
+	case Number:
+		return float64(n), true
+	case Integer:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// ParseNumber converts the given string to a number, following the rules of
+// the Lua lexer. Decimal and hexadecimal numerals without a fractional part or
+// exponent are integers, all other numerals are floats. Hexadecimal integers
+// wrap around on overflow, decimal integers that overflow are converted to
+// floats. Leading and trailing whitespace is ignored.
+func ParseNumber(s string) (Value, bool) {
+	s = strings.TrimSpace(s)
+	if i, ok := parseInteger(s); ok {
+		return NewInteger(i), true
+	}
+	if f, ok := parseFloat(s); ok {
+		return NewNumber(f), true
+	}
+	return nil, false
+}
+
+func parseInteger(s string) (int64, bool) {
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, false
+	}
+
+	var n uint64
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		for _, c := range []byte(s[2:]) {
+			d, ok := hexDigit(c)
+			if !ok {
+				return 0, false
+			}
+			n = n<<4 | uint64(d) // hexadecimal integers wrap around
+		}
+	} else {
+		for _, c := range []byte(s) {
+			if c < '0' || c > '9' {
+				return 0, false
+			}
+			d := uint64(c - '0')
+			if n > (math.MaxInt64-d)/10 {
+				// a decimal numeral that overflows is a float
+				return 0, false
+			}
+			n = n*10 + d
+		}
+	}
+	if neg {
+		return int64(-n), true
+	}
+	return int64(n), true
+}
+
+func parseFloat(s string) (float64, bool) {
+	if strings.ContainsAny(s, "nN_") {
+		// reject 'inf' and 'nan', as well as Go's digit separators
+		return 0, false
+	}
+	body := strings.TrimLeft(s, "+-")
+	if len(body) > 2 && body[0] == '0' && (body[1] == 'x' || body[1] == 'X') && !strings.ContainsAny(body, "pP") {
+		// Go requires an exponent for hexadecimal floats, Lua doesn't
+		s += "p0"
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			// overflow results in +/-inf, like in Lua
+			return f, true
+		}
+		return 0, false
+	}
+	return f, true
+}
+
+func hexDigit(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
 }
@@ -0,0 +1,77 @@
+package value
+
+// Scope holds the local variables of a block. Other than a Table, a Scope
+// can hold variables whose value is nil, since a local variable exists
+// from the point it is declared, no matter what its value is.
+//
+// Every declaration creates a new Variable, even if a variable with the
+// same name already exists in the scope. A function that is created in a
+// scope captures the variables that are declared at that point (see
+// Capture), and shares them with every other function that captured them.
+type Scope struct {
+	Variables map[string]*Variable
+	// Varargs holds the values of the vararg expression '...' in a call of
+	// a vararg function. It is only set on the scope that the parameters
+	// of such a call are declared in, and is nil for all other scopes.
+	Varargs []Value
+}
+
+// Variable is a local variable, which closures capture as upvalue.
+type Variable struct {
+	Value Value
+}
+
+func NewScope() *Scope {
+	return &Scope{
+		Variables: make(map[string]*Variable),
+	}
+}
+
+// Declare declares a new variable with the given name in this scope. If a
+// variable with the same name already exists in this scope, it is shadowed,
+// but closures that captured it keep referring to the old variable.
+func (s *Scope) Declare(name string, value Value) {
+	if value == nil {
+		value = Nil
+	}
+	s.Variables[name] = &Variable{Value: value}
+}
+
+// Set assigns the given value to the variable with the given name, and
+// returns false if no such variable was declared in this scope.
+func (s *Scope) Set(name string, value Value) bool {
+	variable, ok := s.Variables[name]
+	if !ok {
+		return false
+	}
+	if value == nil {
+		value = Nil
+	}
+	variable.Value = value
+	return true
+}
+
+// Get returns the value of the variable with the given name, and false if
+// no such variable was declared in this scope.
+func (s *Scope) Get(name string) (Value, bool) {
+	variable, ok := s.Variables[name]
+	if !ok {
+		return nil, false
+	}
+	return variable.Value, true
+}
+
+// Capture returns a scope that holds the variables that are declared in
+// this scope at the time of the call. The variables themselves are shared,
+// but variables that are declared in this scope afterwards are not part of
+// the returned scope.
+func (s *Scope) Capture() *Scope {
+	variables := make(map[string]*Variable, len(s.Variables))
+	for name, variable := range s.Variables {
+		variables[name] = variable
+	}
+	return &Scope{
+		Variables: variables,
+		Varargs:   s.Varargs,
+	}
+}
@@ -1,32 +1,303 @@
 package value
 
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+var (
+	// ErrNilIndex is returned by CheckKey for nil keys.
+	ErrNilIndex = errors.New("table index is nil")
+	// ErrNaNIndex is returned by CheckKey for NaN keys.
+	ErrNaNIndex = errors.New("table index is NaN")
+)
+
+// Table is a Lua table. Like in the reference implementation, a table
+// consists of an array part and a hash part. The array part holds the
+// values of the integer keys 1 to n, the hash part holds all other fields.
+// Sequences, which are the most common use of tables, are therefore
+// stored in a plain slice.
 type Table struct {
 	Metatable *Table
 
-	Fields map[Value]Value
+	// array holds the values of the keys 1 to len(array). Absent fields
+	// are nil. Whenever the array part grows, the fields that directly
+	// follow it are moved from the hash part into the array part, so that
+	// the hash part never holds the key len(array)+1. When a new key is
+	// inserted and less than half of the array part is used, the array
+	// part is shrunk, see rehash.
+	array []Value
+	// arrayCount is the number of non-nil values in the array part.
+	arrayCount int
+	// hash holds all fields that are not part of the array part.
+	hash map[Value]Value
+	// keys holds the keys of the hash part in insertion order, which is
+	// the order in which Next traverses the hash part. Keys of fields that
+	// were removed stay in here until a new key is inserted, so that a
+	// traversal may clear fields while it is running, like in Lua.
+	keys []Value
+	// positions holds the index of every key in keys.
+	positions map[Value]int
 }
 
 func NewTable() *Table {
+	return NewTableSize(0, 0)
+}
+
+// NewTableSize creates a new table with preallocated space for arraySize
+// sequence elements and hashSize other fields.
+func NewTableSize(arraySize, hashSize int) *Table {
 	return &Table{
-		Fields: make(map[Value]Value),
+		array:     make([]Value, 0, arraySize),
+		hash:      make(map[Value]Value, hashSize),
+		positions: make(map[Value]int, hashSize),
 	}
 }
 
 func (Table) Type() Type { return TypeTable }
 
+// CheckKey returns an error if the given value can't be used as a key of a
+// table, which is the case for nil and NaN.
+func CheckKey(key Value) error {
+	switch k := key.(type) {
+	case nil, nilValue:
+		return ErrNilIndex
+	case Number:
+		if math.IsNaN(float64(k)) {
+			return ErrNaNIndex
+		}
+	}
+	return nil
+}
+
+// normalizeKey converts a float key with an integral value to an integer
+// key, so that t[1] and t[1.0] refer to the same field.
+func normalizeKey(key Value) Value {
+	if n, ok := key.(Number); ok {
+		if i, ok := FloatToInteger(float64(n)); ok {
+			return NewInteger(i)
+		}
+	}
+	return key
+}
+
+// Set stores the given value under the given key, or removes the field if
+// the value is nil. Keys that are rejected by CheckKey are ignored, so
+// callers that set keys from Lua code must check them first.
 func (t *Table) Set(key Value, value Value) {
-	if value == Nil || value == nil {
-		delete(t.Fields, key)
-	} else {
-		t.Fields[key] = value
+	if CheckKey(key) != nil {
+		return
+	}
+	key = normalizeKey(key)
+	if value == Nil {
+		value = nil
+	}
+
+	if i, ok := t.arrayIndex(key); ok {
+		old := t.array[i]
+		t.array[i] = value
+		if old == nil && value != nil {
+			t.arrayCount++
+			t.rehash()
+		} else if old != nil && value == nil {
+			t.arrayCount--
+		}
+		return
 	}
+	if k, ok := key.(Integer); ok && int64(k) == int64(len(t.array))+1 {
+		if value != nil {
+			t.array = append(t.array, value)
+			t.arrayCount++
+			t.migrate()
+			t.rehash()
+		}
+		return
+	}
+
+	if value == nil {
+		delete(t.hash, key)
+		return
+	}
+	if _, ok := t.hash[key]; !ok {
+		t.setHash(key, value)
+		t.rehash()
+		return
+	}
+	t.hash[key] = value
+}
+
+// setHash stores the given non-nil value under the given key in the hash
+// part.
+func (t *Table) setHash(key Value, value Value) {
+	if _, ok := t.positions[key]; !ok {
+		t.compactKeys()
+		t.positions[key] = len(t.keys)
+		t.keys = append(t.keys, key)
+	}
+	t.hash[key] = value
 }
 
 func (t *Table) Get(key Value) (Value, bool) {
-	val, ok := t.Fields[key]
+	key = normalizeKey(key)
+	if i, ok := t.arrayIndex(key); ok {
+		val := t.array[i]
+		return val, val != nil
+	}
+	val, ok := t.hash[key]
 	return val, ok
 }
 
+// arrayIndex returns the index in the array part, that holds the value of
+// the given key, and false if the key is not part of the array part.
+func (t *Table) arrayIndex(key Value) (int, bool) {
+	k, ok := key.(Integer)
+	if !ok || k < 1 || int64(k) > int64(len(t.array)) {
+		return 0, false
+	}
+	return int(k - 1), true
+}
+
+// migrate moves the fields that directly follow the array part from the
+// hash part into the array part.
+func (t *Table) migrate() {
+	for len(t.hash) > 0 {
+		key := NewInteger(int64(len(t.array)) + 1)
+		val, ok := t.hash[key]
+		if !ok {
+			return
+		}
+		delete(t.hash, key)
+		t.array = append(t.array, val)
+		t.arrayCount++
+	}
+}
+
+// rehash shrinks the array part, if less than half of it is used. Like in
+// the reference implementation, the new size is the largest power of two n,
+// such that more than half of the keys 1 to n are used. The fields after
+// the new array part are moved into the hash part. Since fields are only
+// removed by setting them to nil, without a rehash, a table can be cleared
+// during a traversal.
+func (t *Table) rehash() {
+	if 2*t.arrayCount >= len(t.array) {
+		return
+	}
+
+	// nums[b] is the number of used keys k with 2^(b-1) < k <= 2^b
+	var nums [bits.UintSize + 1]int
+	for i, val := range t.array {
+		if val != nil {
+			nums[bits.Len(uint(i))]++
+		}
+	}
+	size, used := 0, 0
+	for b, pow := 0, 1; pow/2 < t.arrayCount; b, pow = b+1, pow*2 {
+		used += nums[b]
+		if used > pow/2 {
+			size = pow
+		}
+	}
+	if size > len(t.array) {
+		size = len(t.array)
+	}
+	// the hash part must not hold the key that follows the array part
+	for size < len(t.array) && t.array[size] != nil {
+		size++
+	}
+
+	array := make([]Value, size)
+	copy(array, t.array)
+	t.arrayCount = 0
+	for i, val := range t.array {
+		if val == nil {
+			continue
+		}
+		if i < size {
+			t.arrayCount++
+		} else {
+			t.setHash(NewInteger(int64(i+1)), val)
+		}
+	}
+	t.array = array
+}
+
+// Next returns the field that follows the field with the given key in the
+// traversal order of the table. If key is nil, the first field is returned.
+// After the last field, the returned key is Nil. If the given key is not
+// part of the table, ok is false. The array part is traversed first, in
+// ascending order, followed by the hash part in insertion order.
+func (t *Table) Next(key Value) (nextKey, val Value, ok bool) {
+	arrayStart, hashStart := 0, 0
+	if key != nil && key != Nil {
+		key = normalizeKey(key)
+		if i, found := t.arrayIndex(key); found {
+			arrayStart = i + 1
+		} else if pos, found := t.positions[key]; found {
+			arrayStart, hashStart = len(t.array), pos+1
+		} else {
+			return nil, nil, false
+		}
+	}
+
+	for i := arrayStart; i < len(t.array); i++ {
+		if t.array[i] != nil {
+			return NewInteger(int64(i + 1)), t.array[i], true
+		}
+	}
+	for i := hashStart; i < len(t.keys); i++ {
+		if v, found := t.hash[t.keys[i]]; found {
+			return t.keys[i], v, true
+		}
+	}
+	return Nil, Nil, true
+}
+
+// compactKeys drops the keys of removed fields from the traversal order,
+// once they make up at least half of the keys.
+func (t *Table) compactKeys() {
+	if len(t.keys) < 2*len(t.hash) || len(t.keys) == 0 {
+		return
+	}
+	keys := t.keys[:0]
+	for _, key := range t.keys {
+		if _, ok := t.hash[key]; ok {
+			keys = append(keys, key)
+		} else {
+			delete(t.positions, key)
+		}
+	}
+	for i := len(keys); i < len(t.keys); i++ {
+		t.keys[i] = nil // allow the removed keys to be collected
+	}
+	t.keys = keys
+	for i, key := range t.keys {
+		t.positions[key] = i
+	}
+}
+
+// Length returns a border of the table, which is an index n, such that t[n]
+// is not nil and t[n+1] is nil, or 0 if t[1] is nil. This is the length
+// of the table, as the length operator defines it without a __len
+// metamethod. If the table is not a sequence, any of its borders may be
+// returned.
 func (t *Table) Length() Value {
-	return NewNumber(float64(len(t.Fields)))
+	n := len(t.array)
+	if n == 0 || t.array[n-1] != nil {
+		// the hash part never holds the key n+1
+		return NewInteger(int64(n))
+	}
+
+	// binary search for a border in the array part, where i is always 0 or
+	// a non-nil index, and j is always a nil index
+	i, j := 0, n
+	for j-i > 1 {
+		m := (i + j) / 2
+		if t.array[m-1] == nil {
+			j = m
+		} else {
+			i = m
+		}
+	}
+	return NewInteger(int64(i))
 }
@@ -0,0 +1,83 @@
+package value
+
+import "testing"
+
+const benchSequenceLength = 1000
+
+func sequence(n int) *Table {
+	t := NewTable()
+	for i := 1; i <= n; i++ {
+		t.Set(NewInteger(int64(i)), NewInteger(int64(i)))
+	}
+	return t
+}
+
+func Benchmark_Table_SetSequence(b *testing.B) {
+	var t *Table
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		t = sequence(benchSequenceLength)
+	}
+
+	_ = t
+}
+
+func Benchmark_Table_GetSequence(b *testing.B) {
+	t := sequence(benchSequenceLength)
+	var v Value
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := 1; j <= benchSequenceLength; j++ {
+			v, _ = t.Get(NewInteger(int64(j)))
+		}
+	}
+
+	_ = v
+}
+
+func Benchmark_Table_Append(b *testing.B) {
+	var t *Table
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		// t[#t+1] = v
+		t = NewTable()
+		for j := 0; j < benchSequenceLength; j++ {
+			t.Set(NewInteger(t.Length().(Integer).Value()+1), True)
+		}
+	}
+
+	_ = t
+}
+
+func Benchmark_Table_Length(b *testing.B) {
+	t := sequence(benchSequenceLength)
+	var n Value
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		n = t.Length()
+	}
+
+	_ = n
+}
+
+func Benchmark_Table_Next(b *testing.B) {
+	t := sequence(benchSequenceLength)
+	for i := 0; i < benchSequenceLength; i++ {
+		t.Set(NewString(string(rune('a'+i%26))+NewInteger(int64(i)).String()), True)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for k, _, _ := t.Next(Nil); k != Nil; k, _, _ = t.Next(k) {
+		}
+	}
+}
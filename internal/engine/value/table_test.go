@@ -0,0 +1,184 @@
+package value
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// keys returns the keys of the given table in traversal order.
+func keys(t *Table) []Value {
+	var ks []Value
+	for k, _, _ := t.Next(Nil); k != Nil; k, _, _ = t.Next(k) {
+		ks = append(ks, k)
+	}
+	return ks
+}
+
+func TestTable_ArrayAndHashPart(t *testing.T) {
+	assert := assert.New(t)
+
+	tbl := NewTable()
+	tbl.Set(NewInteger(1), NewString("a"))
+	tbl.Set(NewInteger(2), NewString("b"))
+	tbl.Set(NewInteger(4), NewString("d"))
+	tbl.Set(NewString("x"), True)
+	assert.Len(tbl.array, 2)
+	assert.Len(tbl.hash, 2)
+
+	// filling the gap moves the following key into the array part
+	tbl.Set(NewInteger(3), NewString("c"))
+	assert.Len(tbl.array, 4)
+	assert.Len(tbl.hash, 1)
+	for i, want := range []string{"a", "b", "c", "d"} {
+		got, ok := tbl.Get(NewInteger(int64(i + 1)))
+		assert.True(ok)
+		assert.Equal(NewString(want), got)
+	}
+
+	// float keys with an integral value refer to the same field
+	got, ok := tbl.Get(NewNumber(2))
+	assert.True(ok)
+	assert.Equal(NewString("b"), got)
+	tbl.Set(NewNumber(5), NewString("e"))
+	assert.Len(tbl.array, 5)
+
+	// removing a field of the array part leaves a hole
+	tbl.Set(NewInteger(2), Nil)
+	_, ok = tbl.Get(NewInteger(2))
+	assert.False(ok)
+	assert.Len(tbl.array, 5)
+
+	// nil and NaN keys are ignored
+	tbl.Set(Nil, True)
+	tbl.Set(NewNumber(math.NaN()), True)
+	assert.Len(tbl.hash, 1)
+}
+
+func TestTable_NextAfterDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	tbl := NewTable()
+	tbl.Set(NewInteger(1), True)
+	tbl.Set(NewInteger(2), True)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tbl.Set(NewString(k), True)
+	}
+	assert.Equal([]Value{NewInteger(1), NewInteger(2), NewString("a"), NewString("b"), NewString("c"), NewString("d")}, keys(tbl))
+
+	tbl.Set(NewInteger(1), Nil)
+	tbl.Set(NewString("b"), Nil)
+	assert.Equal([]Value{NewInteger(2), NewString("a"), NewString("c"), NewString("d")}, keys(tbl))
+
+	// a key that was removed during a traversal can still be passed to Next
+	next, _, ok := tbl.Next(NewString("b"))
+	assert.True(ok)
+	assert.Equal(NewString("c"), next)
+
+	// clearing all fields during a traversal visits every field once
+	var visited []Value
+	for k, _, _ := tbl.Next(Nil); k != Nil; k, _, _ = tbl.Next(k) {
+		visited = append(visited, k)
+		tbl.Set(k, Nil)
+	}
+	assert.Equal([]Value{NewInteger(2), NewString("a"), NewString("c"), NewString("d")}, visited)
+	assert.Empty(keys(tbl))
+
+	// new keys are traversed in insertion order after compaction
+	tbl.Set(NewString("e"), True)
+	tbl.Set(NewString("a"), True)
+	assert.Equal([]Value{NewString("e"), NewString("a")}, keys(tbl))
+
+	_, _, ok = tbl.Next(NewString("missing"))
+	assert.False(ok)
+}
+
+func TestTable_LengthBorder(t *testing.T) {
+	assert := assert.New(t)
+
+	tbl := NewTable()
+	assert.Equal(NewInteger(0), tbl.Length())
+
+	for i := 1; i <= 8; i++ {
+		tbl.Set(NewInteger(int64(i)), True)
+	}
+	assert.Equal(NewInteger(8), tbl.Length())
+
+	// with holes, any border may be returned
+	isBorder := func(n int64) bool {
+		if n == 0 {
+			_, ok := tbl.Get(NewInteger(1))
+			return !ok
+		}
+		_, ok := tbl.Get(NewInteger(n))
+		_, next := tbl.Get(NewInteger(n + 1))
+		return ok && !next
+	}
+	for _, hole := range []int64{8, 3, 1, 5} {
+		tbl.Set(NewInteger(hole), Nil)
+		n := int64(tbl.Length().(Integer))
+		assert.True(isBorder(n), "%d is not a border after removing %d", n, hole)
+	}
+
+	// keys in the hash part don't count, unless they follow the array part
+	tbl = NewTable()
+	tbl.Set(NewInteger(2), True)
+	assert.Equal(NewInteger(0), tbl.Length())
+	tbl.Set(NewInteger(1), True)
+	assert.Equal(NewInteger(2), tbl.Length())
+}
+
+func TestTable_ShrinkSparseArray(t *testing.T) {
+	assert := assert.New(t)
+
+	// a queue that removes from the front and appends at the back doesn't
+	// keep the removed fields in the array part
+	tbl := NewTable()
+	head, tail := int64(1), int64(1)
+	for ; tail <= 100; tail++ {
+		tbl.Set(NewInteger(tail), NewInteger(tail))
+	}
+	for ; tail <= 10000; tail, head = tail+1, head+1 {
+		tbl.Set(NewInteger(head), Nil)
+		tbl.Set(NewInteger(tail), NewInteger(tail))
+		assert.True(len(tbl.array) <= 2*(tbl.arrayCount+1), "array part of size %d for %d values", len(tbl.array), tbl.arrayCount)
+	}
+	assert.Len(keys(tbl), 100)
+	for k := head; k < tail; k++ {
+		got, ok := tbl.Get(NewInteger(k))
+		assert.True(ok)
+		assert.Equal(NewInteger(k), got)
+	}
+
+	// removing fields leaves the array part as it is, so that a table can
+	// be cleared during a traversal, but the next new key shrinks it
+	tbl = NewTable()
+	for i := int64(1); i <= 64; i++ {
+		tbl.Set(NewInteger(i), True)
+	}
+	for k, _, _ := tbl.Next(Nil); k != Nil; k, _, _ = tbl.Next(k) {
+		if k.(Integer) > 2 {
+			tbl.Set(k, Nil)
+		}
+	}
+	assert.Len(tbl.array, 64)
+	tbl.Set(NewString("x"), True)
+	assert.Len(tbl.array, 2)
+	assert.Equal(NewInteger(2), tbl.Length())
+	assert.Equal([]Value{NewInteger(1), NewInteger(2), NewString("x")}, keys(tbl))
+
+	// fields after the new array part are moved into the hash part
+	tbl = NewTable()
+	for i := int64(1); i <= 16; i++ {
+		tbl.Set(NewInteger(i), True)
+	}
+	for i := int64(3); i <= 14; i++ {
+		tbl.Set(NewInteger(i), Nil)
+	}
+	tbl.Set(NewInteger(20), True)
+	assert.Len(tbl.array, 2)
+	assert.Equal([]Value{NewInteger(1), NewInteger(2), NewInteger(20), NewInteger(15), NewInteger(16)}, keys(tbl))
+	tbl.Set(NewInteger(3), True)
+	assert.Len(tbl.array, 3)
+}
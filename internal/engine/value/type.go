@@ -15,3 +15,27 @@ const (
 	TypeThread
 	TypeTable
 )
+
+// Name returns the name of the type as it is used by Lua, e.g. by the
+// 'type' function.
+func (t Type) Name() string {
+	switch t {
+	case TypeNil:
+		return "nil"
+	case TypeBoolean:
+		return "boolean"
+	case TypeNumber:
+		return "number"
+	case TypeString:
+		return "string"
+	case TypeFunction:
+		return "function"
+	case TypeUserdata:
+		return "userdata"
+	case TypeThread:
+		return "thread"
+	case TypeTable:
+		return "table"
+	}
+	return "<invalid>"
+}
@@ -0,0 +1,44 @@
+package value
+
+import "fmt"
+
+// Userdata is a full userdata, which wraps an arbitrary Go value. Every full
+// userdata is a unique object with its own metatable, and two userdata values
+// are only equal if they are the same object.
+type Userdata struct {
+	Metatable *Table
+
+	Value interface{}
+}
+
+func NewUserdata(value interface{}) *Userdata {
+	return &Userdata{
+		Value: value,
+	}
+}
+
+func (Userdata) Type() Type { return TypeUserdata }
+
+func (u *Userdata) String() string {
+	return fmt.Sprintf("userdata: %p", u)
+}
+
+// LightUserdata is a userdata that is only a reference to a Go value. It has
+// no metatable of its own, but shares the metatable of all userdata values.
+// Two light userdata values are equal if the values they reference are equal,
+// which is why the referenced value must be comparable, e.g. a pointer.
+type LightUserdata struct {
+	Value interface{}
+}
+
+func NewLightUserdata(value interface{}) LightUserdata {
+	return LightUserdata{
+		Value: value,
+	}
+}
+
+func (LightUserdata) Type() Type { return TypeUserdata }
+
+func (u LightUserdata) String() string {
+	return fmt.Sprintf("userdata: %v", u.Value)
+}
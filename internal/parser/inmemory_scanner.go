@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 	"unicode"
 
 	"github.com/tsatke/lua/internal/token"
@@ -163,8 +164,28 @@ func (s *inMemoryScanner) checkNumber() bool {
 
 	// a number token does not contain a sign
 
+	// hexadecimal numerals start with '0x' or '0X', use hexadecimal
+	// digits and a binary exponent, that is introduced by 'p' or 'P'
+	isDigit := func(r rune) bool {
+		return unicode.IsDigit(r)
+	}
+	exponent := "eE"
+	if s.hasMore(2) && s.input[s.pos] == '0' && (s.input[s.pos+1] == 'x' || s.input[s.pos+1] == 'X') {
+		consume()
+		consume()
+		isDigit = func(r rune) bool {
+			return unicode.IsDigit(r) || (r|0x20 >= 'a' && r|0x20 <= 'f')
+		}
+		exponent = "pP"
+
+		if !(hasMore() && (isDigit(rune(get())) || get() == '.')) {
+			// require at least one digit after the prefix
+			return false
+		}
+	}
+
 	// optional integral digits
-	for hasMore() && unicode.IsDigit(rune(get())) {
+	for hasMore() && isDigit(rune(get())) {
 		consume()
 	}
 
@@ -172,21 +193,26 @@ func (s *inMemoryScanner) checkNumber() bool {
 	if hasMore() && get() == '.' {
 		consume()
 
-		if !(hasMore() && unicode.IsDigit(rune(get()))) {
+		if !(hasMore() && isDigit(rune(get()))) {
 			// no digit, require at least one digit after decimal point
 			return false
 		}
 
 		// optional fractional digits
-		for hasMore() && unicode.IsDigit(rune(get())) {
+		for hasMore() && isDigit(rune(get())) {
 			consume()
 		}
 	}
 
-	// optional exponent part
-	if hasMore() && (get() == 'e' || get() == 'E') {
+	// optional exponent part, the exponent is always decimal
+	if hasMore() && strings.IndexByte(exponent, get()) != -1 {
 		consume()
 
+		// optional exponent sign
+		if hasMore() && (get() == '+' || get() == '-') {
+			consume()
+		}
+
 		if !(hasMore() && unicode.IsDigit(rune(get()))) {
 			// no digit, require at least one digit after exponent indicator
 			return false
@@ -282,6 +308,10 @@ start:
 		} else if s.checkWord("function") {
 			return s.token(token.Function), true
 		}
+	case 'g':
+		if s.checkWord("goto") {
+			return s.token(token.Goto), true
+		}
 	case 'i':
 		if s.checkWord("if") {
 			return s.token(token.If), true
@@ -437,7 +467,9 @@ start:
 			return s.token(token.Comma), true
 		}
 	case ':':
-		if s.check(":") {
+		if s.check("::") {
+			return s.token(token.DoubleColon), true
+		} else if s.check(":") {
 			return s.token(token.Colon), true
 		}
 	case '"', '\'':
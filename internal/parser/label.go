@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/tsatke/lua/internal/ast"
+	"github.com/tsatke/lua/internal/token"
+)
+
+// labelScope holds the labels, locals and unresolved gotos of a block that
+// is currently being parsed. A label is visible in the entire block where
+// it is defined, including nested blocks, but not nested functions. A goto
+// may jump to any visible label, as long as it does not jump into the scope
+// of a local.
+type labelScope struct {
+	// function is the number of functions enclosing the block. Gotos are
+	// only passed on to the enclosing block if it is part of the same
+	// function.
+	function int
+	// statements is the number of statements in the block so far.
+	statements int
+	// locals holds the names of the locals declared in the block so far.
+	locals []token.Token
+	labels []label
+	gotos  []pendingGoto
+}
+
+type label struct {
+	name token.Token
+	// statement is the index of the label in its block.
+	statement int
+	// locals is the number of locals in scope at the label.
+	locals int
+}
+
+type pendingGoto struct {
+	label token.Token
+	// statement is the index of the statement in the block, that is or
+	// contains the goto.
+	statement int
+	// locals is the number of locals in scope at the goto.
+	locals int
+}
+
+func (p *parser) openLabelScope() {
+	p.labelScopes = append(p.labelScopes, &labelScope{
+		function: len(p.varargs),
+	})
+}
+
+// declareStatement records the locals, labels and gotos of the given
+// statement in the current block.
+func (p *parser) declareStatement(stmt ast.Statement) {
+	scope := p.labelScopes[len(p.labelScopes)-1]
+	switch s := stmt.(type) {
+	case ast.Local:
+		scope.locals = append(scope.locals, s.NameList...)
+	case ast.LocalFunction:
+		scope.locals = append(scope.locals, s.Name)
+	case ast.Label:
+		for _, l := range scope.labels {
+			if l.name.Value() == s.Name.Value() {
+				p.collectError(fmt.Errorf("label '%s' at %s already defined at %s", s.Name.Value(), s.Name.Pos(), l.name.Pos()))
+			}
+		}
+		scope.labels = append(scope.labels, label{
+			name:      s.Name,
+			statement: scope.statements,
+			locals:    len(scope.locals),
+		})
+	case ast.Goto:
+		scope.gotos = append(scope.gotos, pendingGoto{
+			label:     s.Label,
+			statement: scope.statements,
+			locals:    len(scope.locals),
+		})
+	}
+	scope.statements++
+}
+
+// closeLabelScope resolves the gotos of the given block, which is the
+// current block. Gotos that don't jump to a label of this block are passed
+// on to the enclosing block, or are reported if there is no enclosing
+// block in the same function.
+func (p *parser) closeLabelScope(block ast.Block) {
+	scope := p.labelScopes[len(p.labelScopes)-1]
+	p.labelScopes = p.labelScopes[:len(p.labelScopes)-1]
+
+	// labels that are only followed by other labels are considered to be
+	// at the end of the block, which is outside the scope of the locals of
+	// the block, so that gotos can jump there, like 'continue' would
+	void := len(block)
+	for void > 0 {
+		if _, ok := block[void-1].(ast.Label); !ok {
+			break
+		}
+		void--
+	}
+
+	var parent *labelScope
+	if len(p.labelScopes) > 0 && p.labelScopes[len(p.labelScopes)-1].function == scope.function {
+		parent = p.labelScopes[len(p.labelScopes)-1]
+	}
+
+gotos:
+	for _, g := range scope.gotos {
+		for _, l := range scope.labels {
+			if l.name.Value() != g.label.Value() {
+				continue
+			}
+			locals := l.locals
+			if l.statement >= void {
+				locals = 0
+			}
+			if l.statement > g.statement && g.locals < locals {
+				p.collectError(fmt.Errorf("goto %s at %s jumps into the scope of local '%s'", g.label.Value(), g.label.Pos(), scope.locals[g.locals].Value()))
+			}
+			continue gotos
+		}
+
+		if parent == nil {
+			p.collectError(fmt.Errorf("no visible label '%s' for goto at %s", g.label.Value(), g.label.Pos()))
+			continue
+		}
+		parent.gotos = append(parent.gotos, pendingGoto{
+			label:     g.label,
+			statement: parent.statements,
+			locals:    len(parent.locals),
+		})
+	}
+}
@@ -26,6 +26,13 @@ type parser struct {
 	errors []error
 
 	tkstash []token.Token
+	// varargs holds for every function that is currently being parsed,
+	// innermost function last, whether it is a vararg function. The main
+	// chunk is always a vararg function, and is not part of this.
+	varargs []bool
+	// labelScopes holds the label scopes of all blocks that are currently
+	// being parsed, innermost block last.
+	labelScopes []*labelScope
 }
 
 // New creates a new single-use Lua-parser.
@@ -101,8 +108,11 @@ func (p *parser) next() (token.Token, bool) {
 }
 
 func (p *parser) block() ast.Block {
+	p.openLabelScope()
+
 	block := ast.Block{}
 	for stmt := p.stmt(); stmt != nil; stmt = p.stmt() {
+		p.declareStatement(stmt)
 		block = append(block, stmt)
 	}
 	next, ok := p.next()
@@ -120,6 +130,8 @@ func (p *parser) block() ast.Block {
 			p.stash(next)
 		}
 	}
+
+	p.closeLabelScope(block)
 	return block
 }
 
@@ -244,6 +256,35 @@ func (p *parser) stmt() (stmt ast.Statement) {
 			return nil
 		}
 		return whileBlock
+	case tk.Is(token.Goto):
+		name, ok := p.next()
+		if !ok {
+			p.collectError(ErrUnexpectedEof("name"))
+			return nil
+		}
+		if !name.Is(token.Name) {
+			p.collectError(ErrUnexpectedThing("name", name))
+			return nil
+		}
+		return ast.Goto{
+			Label: name,
+		}
+	case tk.Is(token.DoubleColon):
+		name, ok := p.next()
+		if !ok {
+			p.collectError(ErrUnexpectedEof("name"))
+			return nil
+		}
+		if !name.Is(token.Name) {
+			p.collectError(ErrUnexpectedThing("name", name))
+			return nil
+		}
+		if !p.requireToken(token.DoubleColon) {
+			return nil
+		}
+		return ast.Label{
+			Name: name,
+		}
 	case tk.Is(token.For):
 		l1, ok := p.next()
 		if !ok {
@@ -553,15 +594,21 @@ func (p *parser) if_() (ast.IfBlock, bool) {
 		return ast.IfBlock{}, false
 	}
 
+	var elseIfs []ast.ElseIf
 	next, ok := p.next()
+	for ok && next.Is(token.Elseif) {
+		elseIf, elseIfOk := p.elseIf()
+		if !elseIfOk {
+			p.collectError(ErrExpectedSomething("elseif block"))
+			return ast.IfBlock{}, false
+		}
+		elseIfs = append(elseIfs, elseIf)
+		next, ok = p.next()
+	}
 	if !ok {
 		p.collectError(ErrUnexpectedEof("elseif, else or end"))
 		return ast.IfBlock{}, false
 	}
-	if next.Is(token.Elseif) {
-		p.collectError(fmt.Errorf("elseif is not supported yet"))
-		return ast.IfBlock{}, false
-	}
 
 	var elseBlock ast.Block
 	if next.Is(token.Else) {
@@ -581,9 +628,35 @@ func (p *parser) if_() (ast.IfBlock, bool) {
 	}
 
 	return ast.IfBlock{
+		If:     exp,
+		Then:   block,
+		ElseIf: elseIfs,
+		Else:   elseBlock,
+	}, true
+}
+
+// elseIf parses the condition and block of an elseif. The 'elseif' keyword
+// must already have been consumed.
+func (p *parser) elseIf() (ast.ElseIf, bool) {
+	exp := p.exp()
+	if exp == nil {
+		p.collectError(ErrExpectedSomething("exp"))
+		return ast.ElseIf{}, false
+	}
+
+	if !p.requireToken(token.Then) {
+		return ast.ElseIf{}, false
+	}
+
+	block := p.block()
+	if block == nil {
+		p.collectError(ErrExpectedSomething("block"))
+		return ast.ElseIf{}, false
+	}
+
+	return ast.ElseIf{
 		If:   exp,
 		Then: block,
-		Else: elseBlock,
 	}, true
 }
 
@@ -719,7 +792,9 @@ func (p *parser) funcbody() (ast.FuncBody, bool) {
 		}
 	}
 
+	p.varargs = append(p.varargs, parlist.Ellipsis)
 	block := p.block()
+	p.varargs = p.varargs[:len(p.varargs)-1]
 	if block == nil {
 		p.collectError(ErrExpectedSomething("block"))
 		return ast.FuncBody{}, false
@@ -808,15 +883,19 @@ func (p *parser) local() (ast.Local, bool) {
 
 	namelist := p.namelist()
 
-	// check if there's a '=' between namelist and explist
+	// the explist is optional, 'local a, b' declares the variables
+	// without a value
 	assign, ok := p.next()
 	if !ok {
-		p.collectError(fmt.Errorf("expected '=' followed by explist, but got EOF"))
-		return ast.Local{}, false
+		return ast.Local{
+			NameList: namelist,
+		}, true
 	}
 	if !assign.Is(token.Assign) {
-		p.collectError(fmt.Errorf("expected '=' followed by explist, but got %s", assign))
-		return ast.Local{}, false
+		p.stash(assign)
+		return ast.Local{
+			NameList: namelist,
+		}, true
 	}
 
 	explist := p.explist()
@@ -868,6 +947,17 @@ func (p *parser) namelist() []token.Token {
 			p.stash(next)
 			break
 		}
+		// in a parameter list, the names may be followed by ', ...'
+		following, ok := p.next()
+		if !ok {
+			p.stash(next)
+			break
+		}
+		p.stash(following)
+		if following.Is(token.Ellipsis) {
+			p.stash(next)
+			break
+		}
 	}
 	return list
 }
@@ -884,6 +974,17 @@ func (p *parser) varlist() []ast.Var {
 			p.stash(next)
 			break
 		}
+		// in a parameter list, the names may be followed by ', ...'
+		following, ok := p.next()
+		if !ok {
+			p.stash(next)
+			break
+		}
+		p.stash(following)
+		if following.Is(token.Ellipsis) {
+			p.stash(next)
+			break
+		}
 	}
 	return list
 }
@@ -938,6 +1039,10 @@ func (p *parser) expAtomic() (exp ast.Exp) {
 			True: next,
 		}
 	case next.Is(token.Ellipsis):
+		if len(p.varargs) > 0 && !p.varargs[len(p.varargs)-1] {
+			p.collectError(fmt.Errorf("cannot use '...' outside a vararg function at %s", next.Pos()))
+			return nil
+		}
 		exp = ast.SimpleExp{
 			Ellipsis: next,
 		}
@@ -950,7 +1055,8 @@ func (p *parser) expAtomic() (exp ast.Exp) {
 			String: next,
 		}
 	case next.Is(token.UnaryOperator):
-		exp = p.exp()
+		// unary operators bind tighter than all binary operators except '^'
+		exp = p.expPrecedence(p.expAtomic(), precedence11)
 		if exp == nil {
 			p.collectError(fmt.Errorf("expected expression after unary operator %s, but got nothing", next))
 			return nil
@@ -1107,7 +1213,17 @@ func (p *parser) field() (ast.Field, bool) {
 
 	switch {
 	case next.Is(token.Name):
-		name = next
+		// a name is only the key of the field if it is followed by '=',
+		// otherwise it's the start of an expression
+		assign, ok := p.next()
+		if ok && assign.Is(token.Assign) {
+			p.stash(assign)
+			name = next
+		} else if ok {
+			p.stash(next, assign)
+		} else {
+			p.stash(next)
+		}
 	case next.Is(token.BracketLeft):
 		leftExp = p.exp()
 		if leftExp == nil {
@@ -1227,7 +1343,7 @@ fragments:
 				Name: name,
 				Args: &args,
 			})
-		case next.Is(token.ParLeft):
+		case next.Is(token.ParLeft) || next.Is(token.CurlyLeft) || next.Is(token.String):
 			p.stash(next)
 			args, ok := p.args()
 			if !ok {
@@ -1294,8 +1410,15 @@ func (p *parser) args() (ast.Args, bool) {
 			ExpList: explist,
 		}, true
 	case next.Is(token.CurlyLeft):
-		p.collectError(fmt.Errorf("table constructors not supported yet"))
-		return ast.Args{}, false
+		p.stash(next)
+		tbl, ok := p.tableconstructor()
+		if !ok {
+			p.collectError(ErrExpectedSomething("table"))
+			return ast.Args{}, false
+		}
+		return ast.Args{
+			TableConstructor: tbl,
+		}, true
 	case next.Is(token.String):
 		return ast.Args{
 			String: next,
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/tsatke/lua/internal/ast"
 	"github.com/tsatke/lua/internal/token"
 )
@@ -283,3 +285,249 @@ return x * 5
 		},
 	})
 }
+
+func (suite *ParserSuite) TestUnaryExpression() {
+	suite.assertChunkString(`
+return -a % b, -c ^ d
+`, ast.Chunk{
+		Name: "<unknown input>",
+		Block: ast.Block{
+			ast.LastStatement{
+				ExpList: []ast.Exp{
+					ast.BinopExp{
+						Left: ast.UnopExp{
+							Unop: token.New("-", token.Position{2, 8, 8}, token.UnaryOperator, token.BinaryOperator),
+							Exp: ast.PrefixExp{
+								Name: token.New("a", token.Position{2, 9, 9}, token.Name),
+							},
+						},
+						Binop: token.New("%", token.Position{2, 11, 11}, token.BinaryOperator),
+						Right: ast.PrefixExp{
+							Name: token.New("b", token.Position{2, 13, 13}, token.Name),
+						},
+					},
+					ast.UnopExp{
+						Unop: token.New("-", token.Position{2, 16, 16}, token.UnaryOperator, token.BinaryOperator),
+						Exp: ast.BinopExp{
+							Left: ast.PrefixExp{
+								Name: token.New("c", token.Position{2, 17, 17}, token.Name),
+							},
+							Binop: token.New("^", token.Position{2, 19, 19}, token.BinaryOperator),
+							Right: ast.PrefixExp{
+								Name: token.New("d", token.Position{2, 21, 21}, token.Name),
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func (suite *ParserSuite) TestIfElseIf() {
+	suite.assertChunkString(`
+if a then elseif b then elseif c then else end
+`, ast.Chunk{
+		Name: "<unknown input>",
+		Block: ast.Block{
+			ast.IfBlock{
+				If: ast.PrefixExp{
+					Name: token.New("a", token.Position{2, 4, 4}, token.Name),
+				},
+				Then: ast.Block{},
+				ElseIf: []ast.ElseIf{
+					{
+						If: ast.PrefixExp{
+							Name: token.New("b", token.Position{2, 18, 18}, token.Name),
+						},
+						Then: ast.Block{},
+					},
+					{
+						If: ast.PrefixExp{
+							Name: token.New("c", token.Position{2, 32, 32}, token.Name),
+						},
+						Then: ast.Block{},
+					},
+				},
+				Else: ast.Block{},
+			},
+		},
+	})
+}
+
+func (suite *ParserSuite) TestMethodDeclaration() {
+	suite.assertChunkString(`
+function a.b:c(x) end
+`, ast.Chunk{
+		Name: "<unknown input>",
+		Block: ast.Block{
+			ast.Function{
+				FuncName: &ast.FuncName{
+					Name1: []token.Token{
+						token.New("a", token.Position{2, 10, 10}, token.Name),
+						token.New("b", token.Position{2, 12, 12}, token.Name),
+					},
+					Name2: token.New("c", token.Position{2, 14, 14}, token.Name),
+				},
+				FuncBody: ast.FuncBody{
+					ParList: ast.ParList{
+						NameList: []token.Token{
+							token.New("x", token.Position{2, 16, 16}, token.Name),
+						},
+					},
+					Block: ast.Block{},
+				},
+			},
+		},
+	})
+}
+
+func (suite *ParserSuite) TestTableConstructorAndStringArgs() {
+	suite.assertChunkString(`
+f{a}"s"
+`, ast.Chunk{
+		Name: "<unknown input>",
+		Block: ast.Block{
+			ast.FunctionCall{
+				PrefixExp: ast.PrefixExp{
+					Name: token.New("f", token.Position{2, 1, 1}, token.Name),
+					Fragments: []ast.PrefixExpFragment{
+						{
+							Args: &ast.Args{
+								TableConstructor: ast.TableConstructor{
+									Fields: []ast.Field{
+										{
+											RightExp: ast.PrefixExp{
+												Name: token.New("a", token.Position{2, 3, 3}, token.Name),
+											},
+										},
+									},
+								},
+							},
+						},
+						{
+							Args: &ast.Args{
+								String: token.New("s", token.Position{2, 5, 5}, token.String),
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func (suite *ParserSuite) TestVarargs() {
+	suite.assertChunkString(`
+return ...
+`, ast.Chunk{
+		Name: "<unknown input>",
+		Block: ast.Block{
+			ast.LastStatement{
+				ExpList: []ast.Exp{
+					ast.SimpleExp{
+						Ellipsis: token.New("...", token.Position{2, 8, 8}, token.Ellipsis),
+					},
+				},
+			},
+		},
+	})
+
+	p, err := New(strings.NewReader(`local function f(a, b, ...) return ... end`))
+	suite.NoError(err)
+	chunk, ok := p.Parse()
+	suite.True(ok)
+	suite.Empty(p.Errors())
+	parList := chunk.Block[0].(ast.LocalFunction).FuncBody.ParList
+	suite.Len(parList.NameList, 2)
+	suite.True(parList.Ellipsis)
+
+	p, err = New(strings.NewReader(`function f(a) return function(...) return ... end, ... end`))
+	suite.NoError(err)
+	_, ok = p.Parse()
+	suite.False(ok)
+	suite.Len(p.Errors(), 1)
+	suite.Contains(p.Errors()[0].Error(), "cannot use '...' outside a vararg function")
+}
+
+func (suite *ParserSuite) TestLocalWithoutExpList() {
+	suite.assertChunkString(`
+local a, b
+a = 1
+`, ast.Chunk{
+		Name: "<unknown input>",
+		Block: ast.Block{
+			ast.Local{
+				NameList: []token.Token{
+					token.New("a", token.Position{2, 7, 7}, token.Name),
+					token.New("b", token.Position{2, 10, 10}, token.Name),
+				},
+			},
+			ast.Assignment{
+				VarList: []ast.Var{
+					{
+						PrefixExp: ast.PrefixExp{
+							Name: token.New("a", token.Position{3, 1, 12}, token.Name),
+						},
+					},
+				},
+				ExpList: []ast.Exp{
+					ast.SimpleExp{
+						Number: token.New("1", token.Position{3, 5, 16}, token.Number),
+					},
+				},
+			},
+		},
+	})
+}
+
+func (suite *ParserSuite) TestGoto() {
+	suite.assertChunkString(`
+::top::
+goto top
+`, ast.Chunk{
+		Name: "<unknown input>",
+		Block: ast.Block{
+			ast.Label{
+				Name: token.New("top", token.Position{2, 3, 3}, token.Name),
+			},
+			ast.Goto{
+				Label: token.New("top", token.Position{3, 6, 14}, token.Name),
+			},
+		},
+	})
+
+	valid := []string{
+		// continue, the label is at the end of the block
+		`for i = 1, 3 do local x = i if x == 2 then goto continue end local y = x ::continue:: end`,
+		`while true do goto done end ::done::`,
+		`do goto a end ::a:: ::b:: ; goto b`,
+		`::a:: do local x = 1 goto a end`,
+		`do ::a:: end do ::a:: end`,
+	}
+	for _, source := range valid {
+		p, err := New(strings.NewReader(source))
+		suite.NoError(err)
+		_, ok := p.Parse()
+		suite.True(ok, source)
+		suite.Empty(p.Errors(), source)
+	}
+
+	invalid := map[string]string{
+		`goto a local x = 1 ::a:: print(x)`:                          "jumps into the scope of local 'x'",
+		`do goto a end local x ::a:: x = 1`:                          "jumps into the scope of local 'x'",
+		`goto a do ::a:: end`:                                        "no visible label 'a' for goto",
+		`::a:: function f() goto a end`:                              "no visible label 'a' for goto",
+		`::a:: ::a::`:                                                "label 'a' at 1:9,offset=8 already defined",
+		`repeat goto continue local x ::continue:: x = 1 until true`: "jumps into the scope of local 'x'",
+	}
+	for source, msg := range invalid {
+		p, err := New(strings.NewReader(source))
+		suite.NoError(err)
+		_, ok := p.Parse()
+		suite.False(ok, source)
+		if suite.Len(p.Errors(), 1, source) {
+			suite.Contains(p.Errors()[0].Error(), msg, source)
+		}
+	}
+}
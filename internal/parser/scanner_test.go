@@ -18,7 +18,7 @@ func (suite *ScannerSuite) TestSmallInput() {
 }
 
 func (suite *ScannerSuite) TestKeywordTypes() {
-	suite.assertTokensString("and break do else elseif end false for function if in local nil not or repeat return then true until while",
+	suite.assertTokensString("and break do else elseif end false for function goto if in local nil not or repeat return then true until while",
 		[]token.Token{
 			token.New("and", token.Position{1, 1, 0}, token.And, token.BinaryOperator),
 			token.New("break", token.Position{1, 5, 4}, token.Break),
@@ -29,18 +29,19 @@ func (suite *ScannerSuite) TestKeywordTypes() {
 			token.New("false", token.Position{1, 30, 29}, token.False),
 			token.New("for", token.Position{1, 36, 35}, token.For),
 			token.New("function", token.Position{1, 40, 39}, token.Function),
-			token.New("if", token.Position{1, 49, 48}, token.If),
-			token.New("in", token.Position{1, 52, 51}, token.In),
-			token.New("local", token.Position{1, 55, 54}, token.Local),
-			token.New("nil", token.Position{1, 61, 60}, token.Nil),
-			token.New("not", token.Position{1, 65, 64}, token.Not, token.UnaryOperator),
-			token.New("or", token.Position{1, 69, 68}, token.Or, token.BinaryOperator),
-			token.New("repeat", token.Position{1, 72, 71}, token.Repeat),
-			token.New("return", token.Position{1, 79, 78}, token.Return),
-			token.New("then", token.Position{1, 86, 85}, token.Then),
-			token.New("true", token.Position{1, 91, 90}, token.True),
-			token.New("until", token.Position{1, 96, 95}, token.Until),
-			token.New("while", token.Position{1, 102, 101}, token.While),
+			token.New("goto", token.Position{1, 49, 48}, token.Goto),
+			token.New("if", token.Position{1, 54, 53}, token.If),
+			token.New("in", token.Position{1, 57, 56}, token.In),
+			token.New("local", token.Position{1, 60, 59}, token.Local),
+			token.New("nil", token.Position{1, 66, 65}, token.Nil),
+			token.New("not", token.Position{1, 70, 69}, token.Not, token.UnaryOperator),
+			token.New("or", token.Position{1, 74, 73}, token.Or, token.BinaryOperator),
+			token.New("repeat", token.Position{1, 77, 76}, token.Repeat),
+			token.New("return", token.Position{1, 84, 83}, token.Return),
+			token.New("then", token.Position{1, 91, 90}, token.Then),
+			token.New("true", token.Position{1, 96, 95}, token.True),
+			token.New("until", token.Position{1, 101, 100}, token.Until),
+			token.New("while", token.Position{1, 107, 106}, token.While),
 		})
 }
 
@@ -96,6 +97,22 @@ func (suite *ScannerSuite) TestConcatenatedTokens() {
 		})
 }
 
+func (suite *ScannerSuite) TestLabel() {
+	suite.assertTokensString(`::continue:: a:b() goto continue`,
+		[]token.Token{
+			token.New("::", token.Position{1, 1, 0}, token.DoubleColon),
+			token.New("continue", token.Position{1, 3, 2}, token.Name),
+			token.New("::", token.Position{1, 11, 10}, token.DoubleColon),
+			token.New("a", token.Position{1, 14, 13}, token.Name),
+			token.New(":", token.Position{1, 15, 14}, token.Colon),
+			token.New("b", token.Position{1, 16, 15}, token.Name),
+			token.New("(", token.Position{1, 17, 16}, token.ParLeft),
+			token.New(")", token.Position{1, 18, 17}, token.ParRight),
+			token.New("goto", token.Position{1, 20, 19}, token.Goto),
+			token.New("continue", token.Position{1, 25, 24}, token.Name),
+		})
+}
+
 func (suite *ScannerSuite) TestNumbers() {
 	suite.assertTokensString(`1.5E7`,
 		[]token.Token{
@@ -115,6 +132,17 @@ func (suite *ScannerSuite) TestNumbers() {
 		[]token.Token{
 			token.New(".3E9", token.Position{1, 1, 0}, token.Number),
 		})
+	suite.assertTokensString(`1e-5 2E+3`,
+		[]token.Token{
+			token.New("1e-5", token.Position{1, 1, 0}, token.Number),
+			token.New("2E+3", token.Position{1, 6, 5}, token.Number),
+		})
+	suite.assertTokensString(`0xff 0XA.8p1 0x.1P-4`,
+		[]token.Token{
+			token.New("0xff", token.Position{1, 1, 0}, token.Number),
+			token.New("0XA.8p1", token.Position{1, 6, 5}, token.Number),
+			token.New("0x.1P-4", token.Position{1, 14, 13}, token.Number),
+		})
 }
 
 func (suite *ScannerSuite) TestStrings() {
@@ -27,6 +27,8 @@ const (
 	For
 	// Function is the token type for the keyword 'function'.
 	Function
+	// Goto is the token type for the keyword 'goto'.
+	Goto
 	// If is the token type for the keyword 'if'.
 	If
 	// In is the token type for the keyword 'in'.
@@ -87,6 +89,9 @@ const (
 	SemiColon
 	// Colon is the token type for a colon ':'.
 	Colon
+	// DoubleColon is the token type for a double colon '::', which
+	// encloses the name of a label.
+	DoubleColon
 	// Comma is the token type for a comma ','.
 	Comma
 	// Dot is the token type for a period '.'.
@@ -18,42 +18,44 @@ func _() {
 	_ = x[False-7]
 	_ = x[For-8]
 	_ = x[Function-9]
-	_ = x[If-10]
-	_ = x[In-11]
-	_ = x[Local-12]
-	_ = x[Nil-13]
-	_ = x[Not-14]
-	_ = x[Or-15]
-	_ = x[Repeat-16]
-	_ = x[Return-17]
-	_ = x[Then-18]
-	_ = x[True-19]
-	_ = x[Until-20]
-	_ = x[While-21]
-	_ = x[BinaryOperator-22]
-	_ = x[UnaryOperator-23]
-	_ = x[Assign-24]
-	_ = x[Number-25]
-	_ = x[String-26]
-	_ = x[Name-27]
-	_ = x[ParLeft-28]
-	_ = x[ParRight-29]
-	_ = x[CurlyLeft-30]
-	_ = x[CurlyRight-31]
-	_ = x[BracketLeft-32]
-	_ = x[BracketRight-33]
-	_ = x[SemiColon-34]
-	_ = x[Colon-35]
-	_ = x[Comma-36]
-	_ = x[Dot-37]
-	_ = x[DoubleDot-38]
-	_ = x[Ellipsis-39]
-	_ = x[Error-40]
+	_ = x[Goto-10]
+	_ = x[If-11]
+	_ = x[In-12]
+	_ = x[Local-13]
+	_ = x[Nil-14]
+	_ = x[Not-15]
+	_ = x[Or-16]
+	_ = x[Repeat-17]
+	_ = x[Return-18]
+	_ = x[Then-19]
+	_ = x[True-20]
+	_ = x[Until-21]
+	_ = x[While-22]
+	_ = x[BinaryOperator-23]
+	_ = x[UnaryOperator-24]
+	_ = x[Assign-25]
+	_ = x[Number-26]
+	_ = x[String-27]
+	_ = x[Name-28]
+	_ = x[ParLeft-29]
+	_ = x[ParRight-30]
+	_ = x[CurlyLeft-31]
+	_ = x[CurlyRight-32]
+	_ = x[BracketLeft-33]
+	_ = x[BracketRight-34]
+	_ = x[SemiColon-35]
+	_ = x[Colon-36]
+	_ = x[DoubleColon-37]
+	_ = x[Comma-38]
+	_ = x[Dot-39]
+	_ = x[DoubleDot-40]
+	_ = x[Ellipsis-41]
+	_ = x[Error-42]
 }
 
-const _Type_name = "TypeUnknownAndBreakDoElseElseifEndFalseForFunctionIfInLocalNilNotOrRepeatReturnThenTrueUntilWhileBinaryOperatorUnaryOperatorAssignNumberStringNameParLeftParRightCurlyLeftCurlyRightBracketLeftBracketRightSemiColonColonCommaDotDoubleDotEllipsisError"
+const _Type_name = "TypeUnknownAndBreakDoElseElseifEndFalseForFunctionGotoIfInLocalNilNotOrRepeatReturnThenTrueUntilWhileBinaryOperatorUnaryOperatorAssignNumberStringNameParLeftParRightCurlyLeftCurlyRightBracketLeftBracketRightSemiColonColonDoubleColonCommaDotDoubleDotEllipsisError"
 
-var _Type_index = [...]uint8{0, 11, 14, 19, 21, 25, 31, 34, 39, 42, 50, 52, 54, 59, 62, 65, 67, 73, 79, 83, 87, 92, 97, 111, 124, 130, 136, 142, 146, 153, 161, 170, 180, 191, 203, 212, 217, 222, 225, 234, 242, 247}
+var _Type_index = [...]uint16{0, 11, 14, 19, 21, 25, 31, 34, 39, 42, 50, 54, 56, 58, 63, 66, 69, 71, 77, 83, 87, 91, 96, 101, 115, 128, 134, 140, 146, 150, 157, 165, 174, 184, 195, 207, 216, 221, 232, 237, 240, 249, 257, 262}
 
 func (i Type) String() string {
 	if i >= Type(len(_Type_index)-1) {
@@ -3,6 +3,7 @@ package lua
 import (
 	"github.com/spf13/afero"
 	"io"
+	"math/rand"
 	"os"
 	"strings"
 
@@ -16,8 +17,15 @@ type Engine struct {
 	stdout io.Writer
 	stderr io.Writer
 
+	fs          afero.Fs
 	workingDir  string
 	scannerType ScannerType
+
+	clock Clock
+	env   Env
+	exit  func(code int)
+
+	randomSource rand.Source
 }
 
 func EvalString(in string) error {
@@ -36,33 +44,57 @@ func NewEngine(opts ...Option) Engine {
 		opt(&e)
 	}
 
-	if e.workingDir == "" {
-		sysWd, _ := os.Getwd()
-		e.workingDir = sysWd
+	if e.fs == nil {
+		e.fs = afero.NewOsFs()
+		if e.workingDir == "" {
+			sysWd, _ := os.Getwd()
+			e.workingDir = sysWd
+		}
+	}
+	fs := e.fs
+	if e.workingDir != "" {
+		fs = afero.NewBasePathFs(fs, e.workingDir)
 	}
 
-	e.engine = engine.New(
+	engineOpts := []engine.Option{
 		engine.WithStdin(e.stdin),
 		engine.WithStdout(e.stdout),
 		engine.WithStderr(e.stderr),
-		engine.WithFs(afero.NewBasePathFs(afero.NewOsFs(), e.workingDir)),
-	)
+		engine.WithFs(fs),
+	}
+	if e.randomSource != nil {
+		engineOpts = append(engineOpts, engine.WithRandomSource(e.randomSource))
+	}
+	if e.clock != nil {
+		engineOpts = append(engineOpts, engine.WithClock(e.clock))
+	}
+	if e.env != nil {
+		engineOpts = append(engineOpts, engine.WithEnv(e.env))
+	}
+	if e.exit != nil {
+		engineOpts = append(engineOpts, engine.WithExit(e.exit))
+	}
+	e.engine = engine.New(engineOpts...)
 
 	return e
 }
 
-func (e Engine) EvalString(source string) (Values, error) {
-	return e.Eval(strings.NewReader(source))
+// EvalString works like Eval, but evaluates the given string.
+func (e Engine) EvalString(source string, args ...Value) (Values, error) {
+	return e.Eval(strings.NewReader(source), args...)
 }
 
-func (e Engine) EvalFile(path string) (Values, error) {
-	results, err := e.engine.EvalFile(path)
+// EvalFile works like Eval, but evaluates the file with the given path,
+// relative to the working directory of the engine.
+func (e Engine) EvalFile(path string, args ...Value) (Values, error) {
+	internalArgs, err := valuesToInternal(args...)
 	if err != nil {
-		if luaErr, ok := err.(engine.Error); ok {
-			return nil, errorFromInternal(luaErr)
-		}
 		return nil, err
 	}
+	results, err := e.engine.EvalFile(path, internalArgs...)
+	if err != nil {
+		return nil, errorFromEngine(err)
+	}
 	return valuesFromInternal(results...), nil
 }
 
@@ -71,14 +103,15 @@ func (e Engine) EvalFile(path string) (Values, error) {
 // error will be of type Error.
 //
 // The parsed source will be evaluated as chunk, and all values that the chunk may return are returned
-// as Values.
-func (e Engine) Eval(source io.Reader) (Values, error) {
-	results, err := e.engine.Eval(source)
+// as Values. The given arguments are passed to the chunk, which can access them with '...'.
+func (e Engine) Eval(source io.Reader, args ...Value) (Values, error) {
+	internalArgs, err := valuesToInternal(args...)
 	if err != nil {
-		if luaErr, ok := err.(engine.Error); ok {
-			return nil, errorFromInternal(luaErr)
-		}
 		return nil, err
 	}
+	results, err := e.engine.Eval(source, internalArgs...)
+	if err != nil {
+		return nil, errorFromEngine(err)
+	}
 	return valuesFromInternal(results...), nil
 }
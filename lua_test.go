@@ -2,8 +2,11 @@ package lua
 
 import (
 	"bytes"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
 	"testing"
+	"time"
 )
 
 func TestLua5_3_4(t *testing.T) {
@@ -23,3 +26,88 @@ func TestLua5_3_4(t *testing.T) {
 	assert.NoError(err)
 	assert.Len(results, 0)
 }
+
+func TestWithRandomSource(t *testing.T) {
+	assert := assert.New(t)
+
+	random := func(seed int64) Values {
+		e := NewEngine(WithRandomSource(rand.NewSource(seed)))
+		results, err := e.EvalString(`return math.random(1000000), math.random(1000000)`)
+		assert.NoError(err)
+		return results
+	}
+
+	assert.Equal(random(42), random(42))
+	assert.NotEqual(random(42), random(43))
+}
+
+func TestEngine_EvalString_Args(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+	results, err := e.EvalString(`return select("#", ...), ...`, String("a"), Integer(2))
+	assert.NoError(err)
+	assert.Equal(Values{Integer(2), String("a"), Integer(2)}, results)
+
+	results, err = e.EvalString(`return select("#", ...)`)
+	assert.NoError(err)
+	assert.Equal(Values{Integer(0)}, results)
+}
+
+func TestWithFs_Require(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(afero.WriteFile(fs, "/lib/greet.lua", []byte(`return { hello = function(name) return "hello " .. name end }`), 0644))
+	assert.NoError(afero.WriteFile(fs, "/lib/util/init.lua", []byte(`return 42`), 0644))
+
+	e := NewEngine(
+		WithFs(fs),
+		WithWorkingDirectory("/lib"),
+	)
+	results, err := e.EvalString(`return require("greet").hello("lua"), require("util"), package.searchpath("greet", package.path)`)
+	assert.NoError(err)
+	assert.Equal(Values{String("hello lua"), Integer(42), String("./greet.lua")}, results)
+
+	results, err = e.EvalString(`return pcall(require, "missing")`)
+	assert.NoError(err)
+	assert.Equal(False, results[0])
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+type mapEnv map[string]string
+
+func (e mapEnv) LookupEnv(key string) (string, bool) {
+	val, ok := e[key]
+	return val, ok
+}
+
+func TestOsOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(afero.WriteFile(fs, "/work/a.txt", []byte("a"), 0644))
+
+	exitCode := -1
+	e := NewEngine(
+		WithFs(fs),
+		WithWorkingDirectory("/work"),
+		WithClock(fixedClock(time.Unix(1606850863, 0).UTC())),
+		WithEnv(mapEnv{"USER": "lua"}),
+		WithExit(func(code int) { exitCode = code }),
+	)
+
+	results, err := e.EvalString(`return os.time(), os.getenv("USER"), os.getenv("HOME"), os.rename("a.txt", "b.txt")`)
+	assert.NoError(err)
+	assert.Equal(Values{Integer(1606850863), String("lua"), Nil, True}, results)
+	exists, err := afero.Exists(fs, "/work/b.txt")
+	assert.NoError(err)
+	assert.True(exists)
+
+	_, err = e.EvalString(`os.exit(7)`)
+	assert.Equal(ExitError{Code: 7}, err)
+	assert.Equal(7, exitCode)
+}
@@ -1,6 +1,11 @@
 package lua
 
-import "io"
+import (
+	"github.com/spf13/afero"
+	"io"
+	"math/rand"
+	"time"
+)
 
 type Option func(*Engine)
 
@@ -34,8 +39,62 @@ func WithStderr(stderr io.Writer) Option {
 	}
 }
 
+// WithRandomSource sets the source of the pseudo-random numbers that math.random
+// generates. Use a source with a fixed seed to make math.random deterministic.
+func WithRandomSource(src rand.Source) Option {
+	return func(e *Engine) {
+		e.randomSource = src
+	}
+}
+
 func WithWorkingDirectory(dir string) Option {
 	return func(e *Engine) {
 		e.workingDir = dir
 	}
 }
+
+// WithFs sets the file system, that Lua code accesses, e.g. with require,
+// loadfile or os.remove. By default, the file system of the operating
+// system is used. If a working directory is set as well, paths are
+// relative to that directory in the given file system.
+func WithFs(fs afero.Fs) Option {
+	return func(e *Engine) {
+		e.fs = fs
+	}
+}
+
+// Clock provides the current time for os.time, os.date and os.clock. The
+// location of the returned time is the local time zone for os.date.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock sets the clock of the engine. By default, the system clock is
+// used.
+func WithClock(clock Clock) Option {
+	return func(e *Engine) {
+		e.clock = clock
+	}
+}
+
+// Env provides the environment variables, that os.getenv looks up.
+type Env interface {
+	LookupEnv(key string) (string, bool)
+}
+
+// WithEnv sets the environment variables of the engine. By default, the
+// environment variables of the process are used.
+func WithEnv(env Env) Option {
+	return func(e *Engine) {
+		e.env = env
+	}
+}
+
+// WithExit sets the function that os.exit calls with the exit code. By
+// default, this is os.Exit. If the function returns, the evaluation is
+// aborted, and an ExitError is returned.
+func WithExit(exit func(code int)) Option {
+	return func(e *Engine) {
+		e.exit = exit
+	}
+}
@@ -0,0 +1,668 @@
+package lua
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+var (
+	valueType   = reflect.TypeOf((*Value)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	bytesType   = reflect.TypeOf([]byte(nil))
+	emptyIfType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// ToValue converts the given Go value into a Lua value, using reflection.
+//
+//   - nil and nil pointers become Nil
+//   - bools, numbers and strings become their Lua counterparts, as does []byte
+//   - Values are returned unchanged
+//   - slices and arrays are copied into a new table as sequence, maps are
+//     copied into a new table with converted keys and values
+//   - functions become Lua functions, whose arguments are converted with
+//     FromValue and whose results are converted with ToValue. If the last
+//     result of a function is an error, it is raised as Lua error
+//   - structs and pointers to structs become userdata, whose exported fields
+//     and methods can be accessed from Lua. A field can be renamed with a
+//     `lua:"name"` tag, or hidden with `lua:"-"`. Methods have to be called
+//     with a colon, e.g. obj:Method(). Structs that are not passed by pointer
+//     are copied, so that changes from Lua are not visible to the caller
+//
+// Other values, like channels or complex numbers, can't be converted, and
+// neither can maps and slices that contain themselves.
+func ToValue(v interface{}) (Value, error) {
+	if v == nil {
+		return Nil, nil
+	}
+	switch val := v.(type) {
+	case Value:
+		return val, nil
+	case GoFunc:
+		return Function{newFunction("?", val)}, nil
+	case func(Values) (Values, error):
+		return Function{newFunction("?", val)}, nil
+	}
+	return toValue(reflect.ValueOf(v), nil)
+}
+
+// toValue converts the given value. visiting holds the maps and slices that
+// are currently being converted, to detect values that contain themselves.
+func toValue(rv reflect.Value, visiting map[interface{}]bool) (Value, error) {
+	if rv.Type().Implements(valueType) {
+		if rv.Kind() == reflect.Interface && rv.IsNil() {
+			return Nil, nil
+		}
+		return rv.Interface().(Value), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return True, nil
+		}
+		return False, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Integer(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			// too large for an integer
+			return Number(rv.Uint()), nil
+		}
+		return Integer(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return Number(rv.Float()), nil
+	case reflect.String:
+		return String(rv.String()), nil
+	case reflect.Interface:
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		return toValue(rv.Elem(), visiting)
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		if rv.Elem().Kind() == reflect.Struct {
+			return structToUserdata(rv), nil
+		}
+		return toValue(rv.Elem(), visiting)
+	case reflect.Struct:
+		// copy the struct, so that its fields are addressable
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		return structToUserdata(ptr), nil
+	case reflect.Slice:
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		if rv.Type() == bytesType {
+			return String(rv.Bytes()), nil
+		}
+		// slices that share their backing array are only the same value
+		// if they have the same length
+		ptr := struct {
+			ptr uintptr
+			len int
+		}{rv.Pointer(), rv.Len()}
+		if visiting[ptr] {
+			return nil, fmt.Errorf("value of type %s contains itself", rv.Type())
+		}
+		if visiting == nil {
+			visiting = make(map[interface{}]bool)
+		}
+		visiting[ptr] = true
+		defer delete(visiting, ptr)
+		return sequenceToTable(rv, visiting)
+	case reflect.Array:
+		return sequenceToTable(rv, visiting)
+	case reflect.Map:
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		ptr := rv.Pointer()
+		if visiting[ptr] {
+			return nil, fmt.Errorf("value of type %s contains itself", rv.Type())
+		}
+		if visiting == nil {
+			visiting = make(map[interface{}]bool)
+		}
+		visiting[ptr] = true
+		defer delete(visiting, ptr)
+		return mapToTable(rv, visiting)
+	case reflect.Func:
+		if rv.IsNil() {
+			return Nil, nil
+		}
+		return Function{newFunction(funcName(rv), reflectFunc(rv))}, nil
+	}
+	return nil, fmt.Errorf("cannot convert value of type %s", rv.Type())
+}
+
+func sequenceToTable(rv reflect.Value, visiting map[interface{}]bool) (Value, error) {
+	tbl := NewTable()
+	for i := 0; i < rv.Len(); i++ {
+		val, err := toValue(rv.Index(i), visiting)
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %w", i, err)
+		}
+		if err := tbl.Set(Integer(i+1), val); err != nil {
+			return nil, fmt.Errorf("index %d: %w", i, err)
+		}
+	}
+	return tbl, nil
+}
+
+func mapToTable(rv reflect.Value, visiting map[interface{}]bool) (Value, error) {
+	tbl := NewTable()
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := toValue(iter.Key(), visiting)
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+		}
+		val, err := toValue(iter.Value(), visiting)
+		if err != nil {
+			return nil, fmt.Errorf("value of %v: %w", iter.Key(), err)
+		}
+		if err := tbl.Set(key, val); err != nil {
+			return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+		}
+	}
+	return tbl, nil
+}
+
+// funcName returns the name of the given function without its package, which
+// is used in error messages.
+func funcName(fn reflect.Value) string {
+	f := runtime.FuncForPC(fn.Pointer())
+	if f == nil {
+		return "?"
+	}
+	name := f.Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// reflectFunc creates a GoFunc that calls the given function, converting
+// the arguments and results.
+func reflectFunc(fn reflect.Value) GoFunc {
+	typ := fn.Type()
+	returnsError := typ.NumOut() > 0 && typ.Out(typ.NumOut()-1) == errorType
+
+	return func(args Values) (Values, error) {
+		numIn := typ.NumIn()
+		if typ.IsVariadic() && len(args) > numIn-1 {
+			numIn = len(args)
+		} else if typ.IsVariadic() {
+			numIn--
+		}
+
+		in := make([]reflect.Value, numIn)
+		for i := range in {
+			var paramType reflect.Type
+			if typ.IsVariadic() && i >= typ.NumIn()-1 {
+				paramType = typ.In(typ.NumIn() - 1).Elem()
+			} else {
+				paramType = typ.In(i)
+			}
+
+			arg := reflect.New(paramType)
+			if err := decode(args.Get(i), arg.Elem(), nil); err != nil {
+				return nil, NewArgumentError(i+1, "%v", err)
+			}
+			in[i] = arg.Elem()
+		}
+
+		out, err := callRecovered(fn, in)
+		if err != nil {
+			return nil, err
+		}
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return nil, err
+			}
+			out = out[:len(out)-1]
+		}
+
+		results := make(Values, len(out))
+		for i, o := range out {
+			val, err := toValue(o, nil)
+			if err != nil {
+				return nil, fmt.Errorf("result #%d: %w", i+1, err)
+			}
+			results[i] = val
+		}
+		return results, nil
+	}
+}
+
+// callRecovered calls the given function with the given arguments. If the
+// function panics, the panic is returned as error, so that it is raised as
+// Lua error instead of crashing the program.
+func callRecovered(fn reflect.Value, in []reflect.Value) (out []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in %s: %v", funcName(fn), r)
+		}
+	}()
+	return fn.Call(in), nil
+}
+
+// structMetatables caches the metatable for every struct type that was
+// converted into a userdata.
+var structMetatables sync.Map // map[reflect.Type]*value.Table
+
+func structToUserdata(ptr reflect.Value) Userdata {
+	mt, ok := structMetatables.Load(ptr.Type())
+	if !ok {
+		mt, _ = structMetatables.LoadOrStore(ptr.Type(), newStructMetatable(ptr.Type()))
+	}
+	u := value.NewUserdata(ptr.Interface())
+	u.Metatable = mt.(*value.Table)
+	return Userdata{u}
+}
+
+// newStructMetatable creates the metatable for userdata values holding a pointer
+// of the given type. The metatable is shared by all such userdata values, which
+// is why it is protected by a __metatable field.
+func newStructMetatable(ptrType reflect.Type) *value.Table {
+	structType := ptrType.Elem()
+	fields := structFields(structType)
+	methods := make(map[string]*value.Function)
+	for i := 0; i < ptrType.NumMethod(); i++ {
+		method := ptrType.Method(i)
+		methods[method.Name] = newFunction(method.Name, reflectFunc(method.Func))
+	}
+
+	self := func(args Values) (reflect.Value, error) {
+		u, err := args.CheckUserdata(1)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.ValueOf(u.Value())
+		if ptr.Type() != ptrType {
+			return reflect.Value{}, NewArgumentError(1, "%s expected, got %s", ptrType, ptr.Type())
+		}
+		return ptr, nil
+	}
+
+	mt := value.NewTable()
+	mt.Set(value.NewString("__metatable"), value.NewString(structType.String()))
+	mt.Set(value.NewString("__index"), newFunction("__index", func(args Values) (Values, error) {
+		ptr, err := self(args)
+		if err != nil {
+			return nil, err
+		}
+		name, err := args.CheckString(2)
+		if err != nil {
+			return nil, err
+		}
+
+		if index, ok := fields[string(name)]; ok {
+			field := ptr.Elem().FieldByIndex(index)
+			if field.Kind() == reflect.Struct {
+				// expose the field itself, not a copy of it
+				field = field.Addr()
+			}
+			val, err := toValue(field, nil)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			return Values{val}, nil
+		}
+		if method, ok := methods[string(name)]; ok {
+			return Values{Function{method}}, nil
+		}
+		return Values{Nil}, nil
+	}))
+	mt.Set(value.NewString("__newindex"), newFunction("__newindex", func(args Values) (Values, error) {
+		ptr, err := self(args)
+		if err != nil {
+			return nil, err
+		}
+		name, err := args.CheckString(2)
+		if err != nil {
+			return nil, err
+		}
+
+		index, ok := fields[string(name)]
+		if !ok {
+			return nil, fmt.Errorf("%s has no field '%s'", structType, name)
+		}
+		if err := decode(args.Get(2), ptr.Elem().FieldByIndex(index), nil); err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		return nil, nil
+	}))
+	mt.Set(value.NewString("__tostring"), newFunction("__tostring", func(args Values) (Values, error) {
+		ptr, err := self(args)
+		if err != nil {
+			return nil, err
+		}
+		return Values{String(fmt.Sprintf("%s: %p", structType, ptr.Interface()))}, nil
+	}))
+	return mt
+}
+
+// structFields returns the index of every exported field of the given struct
+// type, by the name that the field has in Lua. Fields of embedded structs are
+// promoted, unless they are shadowed.
+func structFields(typ reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	var embedded []reflect.StructField
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, field)
+			continue
+		}
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		name := fieldName(field)
+		if name == "" {
+			continue
+		}
+		fields[name] = field.Index
+	}
+
+	for _, field := range embedded {
+		if fieldName(field) == "" {
+			continue
+		}
+		for name, index := range structFields(field.Type) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = append([]int{field.Index[0]}, index...)
+			}
+		}
+	}
+	return fields
+}
+
+// fieldName returns the name of the given field in Lua, or the empty
+// string if the field is hidden with `lua:"-"`.
+func fieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("lua")
+	if tag == "-" {
+		return ""
+	}
+	if tag != "" {
+		return tag
+	}
+	return field.Name
+}
+
+// FromValue decodes the given Lua value into the Go value that target points to.
+// It is the counterpart of ToValue.
+//
+//   - numbers, strings and booleans are decoded into Go numbers, strings and
+//     bools. Like in Lua, numbers and numeric strings are interchangeable.
+//     Decoding a number with a fractional part, or a number that doesn't fit,
+//     into an integer fails
+//   - tables are decoded into slices, arrays, maps and structs. Struct fields
+//     are looked up by the name given in a `lua:"name"` tag, or by the field
+//     name. Fields that are not present in the table are left untouched
+//   - userdata created with ToValue are decoded into the Go value they hold,
+//     if it is assignable to the target
+//   - into an interface{}, Lua values are decoded as nil, bool, int64 for
+//     integers, float64 for floats, string, []interface{} for sequences,
+//     map[interface{}]interface{} for other tables and the held value for
+//     userdata
+//   - into a Value, the Lua value is stored as is
+//
+// Lua functions can't be decoded into Go functions, use Engine.Call instead.
+// Tables that contain themselves can't be decoded into slices, arrays, maps,
+// structs or an interface{}.
+func FromValue(v Value, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, but is %T", target)
+	}
+	return decode(v, rv.Elem(), nil)
+}
+
+// decode decodes the given value into the given target. visiting holds the
+// tables whose contents are currently being decoded, to detect tables that
+// contain themselves.
+func decode(v Value, target reflect.Value, visiting map[*value.Table]bool) error {
+	if v == nil {
+		v = Nil
+	}
+
+	if u, ok := v.(Userdata); ok {
+		held := reflect.ValueOf(u.Value())
+		if held.IsValid() && held.Type().AssignableTo(target.Type()) {
+			target.Set(held)
+			return nil
+		}
+		if held.IsValid() && held.Kind() == reflect.Ptr && held.Type().Elem().AssignableTo(target.Type()) {
+			target.Set(held.Elem())
+			return nil
+		}
+	}
+	if reflect.TypeOf(v).AssignableTo(target.Type()) && target.Type() != emptyIfType {
+		target.Set(reflect.ValueOf(v))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		if tbl, ok := v.(Table); ok {
+			if visiting[tbl.table] {
+				return fmt.Errorf("table contains itself")
+			}
+			if visiting == nil {
+				visiting = make(map[*value.Table]bool)
+			}
+			visiting[tbl.table] = true
+			defer delete(visiting, tbl.table)
+		}
+	}
+
+	switch target.Kind() {
+	case reflect.Interface:
+		if target.NumMethod() != 0 {
+			break
+		}
+		val, err := decodeInterface(v, visiting)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			target.Set(reflect.Zero(target.Type()))
+		} else {
+			target.Set(reflect.ValueOf(val))
+		}
+		return nil
+	case reflect.Ptr:
+		if v == Nil {
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		return decode(v, target.Elem(), visiting)
+	case reflect.Bool:
+		switch v {
+		case True:
+			target.SetBool(true)
+			return nil
+		case False:
+			target.SetBool(false)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := toNumberValue(v); ok {
+			i, ok := toInteger(n)
+			if !ok || target.OverflowInt(i) {
+				return fmt.Errorf("number %v has no %s representation", n, target.Type())
+			}
+			target.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, ok := toNumberValue(v); ok {
+			var u uint64
+			switch n := n.(type) {
+			case Integer:
+				ok = n >= 0
+				u = uint64(n)
+			case Number:
+				ok = float64(n) == math.Trunc(float64(n)) && n >= 0 && n < math.MaxUint64
+				u = uint64(n)
+			}
+			if !ok || target.OverflowUint(u) {
+				return fmt.Errorf("number %v has no %s representation", n, target.Type())
+			}
+			target.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, ok := toNumber(v); ok {
+			target.SetFloat(n)
+			return nil
+		}
+	case reflect.String:
+		switch val := v.(type) {
+		case String:
+			target.SetString(string(val))
+			return nil
+		case Number:
+			target.SetString(value.NewNumber(float64(val)).String())
+			return nil
+		case Integer:
+			target.SetString(strconv.FormatInt(int64(val), 10))
+			return nil
+		}
+	case reflect.Slice:
+		if v == Nil {
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		if s, ok := v.(String); ok && target.Type() == bytesType {
+			target.SetBytes([]byte(s))
+			return nil
+		}
+		if tbl, ok := v.(Table); ok {
+			n := sequenceLength(tbl)
+			slice := reflect.MakeSlice(target.Type(), n, n)
+			for i := 0; i < n; i++ {
+				if err := decode(tbl.Get(Integer(i+1)), slice.Index(i), visiting); err != nil {
+					return fmt.Errorf("index %d: %w", i+1, err)
+				}
+			}
+			target.Set(slice)
+			return nil
+		}
+	case reflect.Array:
+		if tbl, ok := v.(Table); ok {
+			for i := 0; i < target.Len(); i++ {
+				if err := decode(tbl.Get(Integer(i+1)), target.Index(i), visiting); err != nil {
+					return fmt.Errorf("index %d: %w", i+1, err)
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if v == Nil {
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		if tbl, ok := v.(Table); ok {
+			m := reflect.MakeMap(target.Type())
+			var err error
+			tbl.Range(func(key, val Value) bool {
+				k := reflect.New(target.Type().Key()).Elem()
+				if err = decode(key, k, visiting); err != nil {
+					err = fmt.Errorf("key %v: %w", key, err)
+					return false
+				}
+				elem := reflect.New(target.Type().Elem()).Elem()
+				if err = decode(val, elem, visiting); err != nil {
+					err = fmt.Errorf("value of %v: %w", key, err)
+					return false
+				}
+				m.SetMapIndex(k, elem)
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			target.Set(m)
+			return nil
+		}
+	case reflect.Struct:
+		if tbl, ok := v.(Table); ok {
+			for name, index := range structFields(target.Type()) {
+				val := tbl.Get(String(name))
+				if val == Nil {
+					continue
+				}
+				if err := decode(val, target.FieldByIndex(index), visiting); err != nil {
+					return fmt.Errorf("field %s: %w", name, err)
+				}
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s expected, got %s", target.Type(), typeName(v))
+}
+
+func decodeInterface(v Value, visiting map[*value.Table]bool) (interface{}, error) {
+	switch val := v.(type) {
+	case nilType:
+		return nil, nil
+	case boolType:
+		return val == True, nil
+	case Number:
+		return float64(val), nil
+	case Integer:
+		return int64(val), nil
+	case String:
+		return string(val), nil
+	case Userdata:
+		return val.Value(), nil
+	case Table:
+		if n := sequenceLength(val); n > 0 && n == tableSize(val) {
+			var slice []interface{}
+			if err := decode(val, reflect.ValueOf(&slice).Elem(), visiting); err != nil {
+				return nil, err
+			}
+			return slice, nil
+		}
+		var m map[interface{}]interface{}
+		if err := decode(val, reflect.ValueOf(&m).Elem(), visiting); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return v, nil
+}
+
+// sequenceLength returns the number of consecutive non-nil values in the
+// given table, starting at index 1.
+func sequenceLength(tbl Table) int {
+	n := 0
+	for tbl.Get(Integer(n+1)) != Nil {
+		n++
+	}
+	return n
+}
+
+func tableSize(tbl Table) int {
+	size := 0
+	tbl.Range(func(Value, Value) bool {
+		size++
+		return true
+	})
+	return size
+}
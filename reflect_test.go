@@ -0,0 +1,236 @@
+package lua
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	City string `lua:"city"`
+}
+
+type person struct {
+	Name    string   `lua:"name"`
+	Age     int      `lua:"age"`
+	Tags    []string `lua:"tags"`
+	Address address  `lua:"address"`
+	Secret  string   `lua:"-"`
+	private int
+}
+
+func (p *person) Greet(greeting string) string {
+	return greeting + ", " + p.Name
+}
+
+func (p person) Initial() string {
+	return p.Name[:1]
+}
+
+func (p person) Older(years int) (int, error) {
+	if years < 0 {
+		return 0, fmt.Errorf("years must not be negative")
+	}
+	return p.Age + years, nil
+}
+
+func TestToValue(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout bytes.Buffer
+	e := NewEngine(WithStdout(&stdout))
+
+	p := &person{
+		Name:    "Alice",
+		Age:     30,
+		Tags:    []string{"a", "b"},
+		Address: address{City: "Berlin"},
+		Secret:  "hidden",
+	}
+	e.Register("get", func(args Values) (Values, error) {
+		val, err := ToValue(p)
+		return Values{val}, err
+	})
+
+	results, err := e.EvalString(`local p = get()
+p.age = p.age + 1
+p.address.city = "Paris"
+print(p.name, p.age, p.tags[2], p.Secret, p.private)
+print(p:Greet("Hello"), p:Older(5))
+print(pcall(p.Older, p, -1))
+print(pcall(function() p.unknown = 1 end))
+return type(p), getmetatable(p)`)
+	assert.NoError(err)
+	assert.Equal(Values{String("userdata"), String("lua.person")}, results)
+	assert.Equal(31, p.Age)
+	assert.Equal("Paris", p.Address.City)
+	assert.Equal(`Alice	31	b	nil	nil
+Hello, Alice	36
+false	years must not be negative
+false	lua.person has no field 'unknown'
+`, stdout.String())
+}
+
+func TestToValue_Func(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+
+	join, err := ToValue(func(sep string, parts ...string) string {
+		return strings.Join(parts, sep)
+	})
+	assert.NoError(err)
+	e.Register("get", func(args Values) (Values, error) {
+		return Values{join}, nil
+	})
+
+	results, err := e.EvalString(`local join = get()
+return join("-", "a", "b", 3), join(",")`)
+	assert.NoError(err)
+	assert.Equal(Values{String("a-b-3"), String("")}, results)
+
+	_, err = e.EvalString(`get()(true)`)
+	assert.EqualError(err, "bad argument #1 to 'func1' (string expected, got boolean)")
+}
+
+func TestToValue_FuncPanic(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+
+	p, err := ToValue(&person{})
+	assert.NoError(err)
+	e.Register("get", func(args Values) (Values, error) {
+		return Values{p}, nil
+	})
+
+	// a panic in a Go method is raised as Lua error, which can be caught
+	results, err := e.EvalString(`local ok, err = pcall(get().Initial, get())
+return ok, err`)
+	assert.NoError(err)
+	assert.Len(results, 2)
+	assert.Equal(False, results[0])
+	assert.Contains(string(results[1].(String)), "panic in Initial")
+
+	_, err = e.EvalString(`get():Initial()`)
+	assert.Error(err)
+}
+
+func TestToValue_Collections(t *testing.T) {
+	assert := assert.New(t)
+
+	val, err := ToValue([]int{1, 2, 3})
+	assert.NoError(err)
+	tbl := val.(Table)
+	assert.Equal(Integer(2), tbl.Get(Integer(2)))
+
+	val, err = ToValue(map[string]interface{}{"a": 1, "b": []byte("x"), "c": nil})
+	assert.NoError(err)
+	tbl = val.(Table)
+	assert.Equal(Integer(1), tbl.Get(String("a")))
+	assert.Equal(String("x"), tbl.Get(String("b")))
+	assert.Equal(Nil, tbl.Get(String("c")))
+
+	_, err = ToValue(make(chan int))
+	assert.Error(err)
+}
+
+func TestToValue_Cycle(t *testing.T) {
+	assert := assert.New(t)
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	_, err := ToValue(m)
+	assert.EqualError(err, "value of self: value of type map[string]interface {} contains itself")
+
+	s := []interface{}{nil}
+	s[0] = s
+	_, err = ToValue(s)
+	assert.EqualError(err, "index 0: value of type []interface {} contains itself")
+
+	// values that are referenced more than once, but don't contain
+	// themselves, are converted
+	shared := []int{1}
+	val, err := ToValue(map[string]interface{}{"a": shared, "b": shared, "c": shared[:0]})
+	assert.NoError(err)
+	assert.Equal(Integer(1), val.(Table).Get(String("b")).(Table).Get(Integer(1)))
+}
+
+func TestFromValue(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+	results, err := e.EvalString(`return {
+	name = "Bob",
+	age = "42",
+	tags = {"x", "y"},
+	address = {city = "Rome"},
+	Secret = "ignored"
+}`)
+	assert.NoError(err)
+
+	var p person
+	assert.NoError(FromValue(results[0], &p))
+	assert.Equal(person{
+		Name:    "Bob",
+		Age:     42,
+		Tags:    []string{"x", "y"},
+		Address: address{City: "Rome"},
+	}, p)
+
+	var generic interface{}
+	assert.NoError(FromValue(results[0], &generic))
+	assert.Equal(map[interface{}]interface{}{
+		"name":    "Bob",
+		"age":     "42",
+		"tags":    []interface{}{"x", "y"},
+		"address": map[interface{}]interface{}{"city": "Rome"},
+		"Secret":  "ignored",
+	}, generic)
+
+	var n int
+	assert.EqualError(FromValue(Number(1.5), &n), "number 1.5 has no int representation")
+	assert.EqualError(FromValue(True, &n), "int expected, got boolean")
+	assert.Error(FromValue(Number(1), n))
+
+	var tbl Table
+	assert.NoError(FromValue(results[0], &tbl))
+	assert.Equal(results[0], tbl)
+}
+
+func TestFromValue_Cycle(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+	results, err := e.EvalString(`local t = {} t[1] = t
+local shared = {1}
+return t, {a = {t}}, {shared, shared}`)
+	assert.NoError(err)
+
+	var generic interface{}
+	assert.EqualError(FromValue(results[0], &generic), "index 1: table contains itself")
+	assert.EqualError(FromValue(results[1], &generic), "value of a: index 1: index 1: table contains itself")
+
+	var nested [][]int
+	assert.NoError(FromValue(results[2], &nested))
+	assert.Equal([][]int{{1}, {1}}, nested)
+}
+
+func TestFromValue_Userdata(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &person{Name: "Carol"}
+	val, err := ToValue(p)
+	assert.NoError(err)
+
+	var ptr *person
+	assert.NoError(FromValue(val, &ptr))
+	assert.Same(p, ptr)
+
+	var copied person
+	assert.NoError(FromValue(val, &copied))
+	assert.Equal(*p, copied)
+}
@@ -0,0 +1,94 @@
+package lua
+
+import (
+	"fmt"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// Table is a Lua table. Tables are passed by reference, so changes to a Table
+// in Go are visible in Lua and vice versa. Two Tables are equal if they refer
+// to the same Lua table.
+//
+// All operations on a Table are raw, i.e. they don't invoke metamethods.
+// The zero value is not a valid table, use NewTable to create one.
+type Table struct {
+	table *value.Table
+}
+
+func (Table) _val() {}
+
+// NewTable creates a new, empty table.
+func NewTable() Table {
+	return Table{value.NewTable()}
+}
+
+// Get returns the value stored under the given key, or Nil if there is no
+// such value.
+func (t Table) Get(key Value) Value {
+	k, err := valueToInternal(key)
+	if err != nil {
+		return Nil
+	}
+	val, ok := t.table.Get(k)
+	if !ok {
+		return Nil
+	}
+	return valueFromInternal(val)
+}
+
+// Set stores the given value under the given key. Setting a value to Nil
+// removes the key from the table. An error is returned if the key is Nil or
+// NaN, or if one of the values can't be stored in a Lua table. Like in Lua,
+// a float key with an integral value is converted to an integer key.
+func (t Table) Set(key, val Value) error {
+	if key == nil || key == Nil {
+		return fmt.Errorf("table index is nil")
+	}
+	k, err := valueToInternal(key)
+	if err != nil {
+		return fmt.Errorf("key: %w", err)
+	}
+	if err := value.CheckKey(k); err != nil {
+		return err
+	}
+	v, err := valueToInternal(val)
+	if err != nil {
+		return fmt.Errorf("value: %w", err)
+	}
+	t.table.Set(k, v)
+	return nil
+}
+
+// Len returns the length of the table, as the Lua length operator would
+// without invoking a __len metamethod.
+func (t Table) Len() int {
+	return int(t.table.Length().(value.Integer))
+}
+
+// Range calls fn for every key-value pair in the table, in the order in
+// which Lua's next function would return them. If fn returns false, the
+// iteration stops. The table must not be modified while iterating over it,
+// except for setting existing keys.
+func (t Table) Range(fn func(key, val Value) bool) {
+	for k, v, _ := t.table.Next(value.Nil); k != value.Nil; k, v, _ = t.table.Next(k) {
+		if !fn(valueFromInternal(k), valueFromInternal(v)) {
+			return
+		}
+	}
+}
+
+// Metatable returns the metatable of this table, and false if the table
+// has no metatable.
+func (t Table) Metatable() (Table, bool) {
+	if t.table.Metatable == nil {
+		return Table{}, false
+	}
+	return Table{t.table.Metatable}, true
+}
+
+// SetMetatable sets the metatable of this table. Passing the zero Table
+// removes the metatable.
+func (t Table) SetMetatable(mt Table) {
+	t.table.Metatable = mt.table
+}
@@ -0,0 +1,89 @@
+package lua
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
+
+// Userdata is a Lua userdata value, which wraps an arbitrary Go value. This can
+// be used to pass host objects, such as database handles, into scripts without
+// copying them into tables. Scripts can only interact with a userdata through
+// its metatable, e.g. with the __index, __newindex, __call, __tostring
+// or __gc metamethods.
+//
+// There are two kinds of userdata. A full userdata, created with
+// Engine.NewUserdata, is a unique object with its own metatable. A light
+// userdata, created with NewLightUserdata, is only a reference to a Go value
+// and has no metatable of its own.
+type Userdata struct {
+	v value.Value
+}
+
+func (Userdata) _val() {}
+
+// NewUserdata creates a new full userdata that wraps the given Go value, with
+// the given metatable. Passing the zero Table creates a userdata without
+// metatable.
+//
+// If the metatable has a __gc field when the userdata is created, the userdata
+// is marked for finalization. When a marked userdata becomes unreachable, its
+// __gc metamethod is called with the userdata as argument. This happens the
+// next time the engine evaluates code, calls a function, or when
+// collectgarbage is called.
+func (e Engine) NewUserdata(v interface{}, mt Table) Userdata {
+	return Userdata{e.engine.NewUserdata(v, mt.table)}
+}
+
+// NewLightUserdata creates a new light userdata, that references the given Go
+// value. Two light userdata values are equal if the referenced values are equal,
+// so the value must be comparable, usually a pointer. An error is returned
+// if the value is not comparable, e.g. a map, slice or function.
+func NewLightUserdata(v interface{}) (Userdata, error) {
+	if v != nil && !reflect.TypeOf(v).Comparable() {
+		return Userdata{}, fmt.Errorf("light userdata value of type %T is not comparable", v)
+	}
+	return Userdata{value.NewLightUserdata(v)}, nil
+}
+
+// Value returns the Go value that this userdata wraps.
+func (u Userdata) Value() interface{} {
+	switch v := u.v.(type) {
+	case *value.Userdata:
+		return v.Value
+	case value.LightUserdata:
+		return v.Value
+	}
+	return nil
+}
+
+// IsLight returns whether this is a light userdata.
+func (u Userdata) IsLight() bool {
+	_, ok := u.v.(value.LightUserdata)
+	return ok
+}
+
+// Metatable returns the metatable of this userdata, and false if the userdata
+// has no metatable. Light userdata never have a metatable of their own.
+func (u Userdata) Metatable() (Table, bool) {
+	full, ok := u.v.(*value.Userdata)
+	if !ok || full.Metatable == nil {
+		return Table{}, false
+	}
+	return Table{full.Metatable}, true
+}
+
+// SetMetatable sets the metatable of this userdata. Passing the zero Table
+// removes the metatable. Setting a metatable doesn't mark the userdata for
+// finalization, so __gc is only called if the userdata was marked by
+// NewUserdata. An error is returned if this is a light userdata,
+// since light userdata can't have a metatable of their own.
+func (u Userdata) SetMetatable(mt Table) error {
+	full, ok := u.v.(*value.Userdata)
+	if !ok {
+		return fmt.Errorf("cannot set the metatable of a light userdata")
+	}
+	full.Metatable = mt.table
+	return nil
+}
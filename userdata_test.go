@@ -0,0 +1,145 @@
+package lua
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counter struct {
+	count int
+}
+
+func newCounterMetatable(e Engine) Table {
+	mt := NewTable()
+	methods := NewTable()
+	_ = methods.Set(String("inc"), e.NewFunction("inc", func(args Values) (Values, error) {
+		u, err := args.CheckUserdata(1)
+		if err != nil {
+			return nil, err
+		}
+		c := u.Value().(*counter)
+		c.count++
+		return Values{Number(c.count)}, nil
+	}))
+	_ = mt.Set(String("__index"), methods)
+	_ = mt.Set(String("__newindex"), e.NewFunction("__newindex", func(args Values) (Values, error) {
+		u, err := args.CheckUserdata(1)
+		if err != nil {
+			return nil, err
+		}
+		n, err := args.CheckNumber(3)
+		if err != nil {
+			return nil, err
+		}
+		u.Value().(*counter).count = int(n)
+		return nil, nil
+	}))
+	_ = mt.Set(String("__tostring"), e.NewFunction("__tostring", func(args Values) (Values, error) {
+		return Values{String("counter")}, nil
+	}))
+	return mt
+}
+
+func TestUserdata(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout bytes.Buffer
+	e := NewEngine(WithStdout(&stdout))
+
+	c := &counter{}
+	u := e.NewUserdata(c, newCounterMetatable(e))
+	assert.False(u.IsLight())
+	assert.Equal(c, u.Value())
+	e.Register("get", func(args Values) (Values, error) {
+		return Values{u}, nil
+	})
+
+	results, err := e.EvalString(`local c = get()
+c:inc()
+c:inc()
+print(type(c), tostring(c), c == get())
+c.count = 10
+return c:inc(), c`)
+	assert.NoError(err)
+	assert.Equal(Values{Number(11), u}, results)
+	assert.Equal(11, c.count)
+	assert.Equal("userdata\tcounter\ttrue\n", stdout.String())
+
+	plain := e.NewUserdata(c, Table{})
+	_, ok := plain.Metatable()
+	assert.False(ok)
+	e.Register("plain", func(args Values) (Values, error) {
+		return Values{plain}, nil
+	})
+	_, err = e.EvalString(`plain().x = 1`)
+	assert.EqualError(err, "attempt to index a userdata value")
+	results, err = e.EvalString(`return plain() == get(), getmetatable(plain())`)
+	assert.NoError(err)
+	assert.Equal(Values{False, Nil}, results)
+}
+
+func TestLightUserdata(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+
+	c := &counter{}
+	u, err := NewLightUserdata(c)
+	assert.NoError(err)
+	assert.True(u.IsLight())
+	assert.Error(u.SetMetatable(NewTable()))
+	e.Register("get", func(args Values) (Values, error) {
+		u, err := NewLightUserdata(c)
+		return Values{u}, err
+	})
+
+	results, err := e.EvalString(`return get() == get(), type(get())`)
+	assert.NoError(err)
+	assert.Equal(Values{True, String("userdata")}, results)
+
+	for _, v := range []interface{}{map[string]int{}, []int{1}, func() {}} {
+		_, err = NewLightUserdata(v)
+		assert.Error(err, "%T", v)
+	}
+}
+
+func TestUserdata_Gc(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+
+	collected := make(chan interface{}, 1)
+	mt := NewTable()
+	_ = mt.Set(String("__gc"), e.NewFunction("__gc", func(args Values) (Values, error) {
+		u, err := args.CheckUserdata(1)
+		if err != nil {
+			return nil, err
+		}
+		collected <- u.Value()
+		return nil, nil
+	}))
+	e.Register("new", func(args Values) (Values, error) {
+		return Values{e.NewUserdata("handle", mt)}, nil
+	})
+
+	_, err := e.EvalString(`new()`)
+	assert.NoError(err)
+
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		_, err := e.EvalString(`collectgarbage()`)
+		assert.NoError(err)
+		select {
+		case v := <-collected:
+			assert.Equal("handle", v)
+			return
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	assert.Fail("__gc was not called")
+}
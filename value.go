@@ -1,6 +1,10 @@
 package lua
 
-import "github.com/tsatke/lua/internal/engine/value"
+import (
+	"fmt"
+
+	"github.com/tsatke/lua/internal/engine/value"
+)
 
 type Value interface {
 	_val()
@@ -14,14 +18,42 @@ type boolType uint8
 
 func (boolType) _val() {}
 
+// Number is a Lua number of the float subtype.
 type Number float64
 
 func (Number) _val() {}
 
+// Integer is a Lua number of the integer subtype. Integer and Number are
+// different values, however, Lua considers Integer(1) and Number(1) equal.
+type Integer int64
+
+func (Integer) _val() {}
+
 type String string
 
 func (String) _val() {}
 
+// Function is a Lua function, which can be called from Go with Engine.Call.
+// Two Functions are equal if they refer to the same Lua function.
+type Function struct {
+	fn *value.Function
+}
+
+func (Function) _val() {}
+
+// Name returns the name of the function. Anonymous functions don't have a name.
+func (f Function) Name() string {
+	return f.fn.Name
+}
+
+// Thread is an opaque handle to a Lua thread, i.e. a coroutine. It can be
+// passed back to the engine, but it can not be resumed from Go.
+type Thread struct {
+	v value.Value
+}
+
+func (Thread) _val() {}
+
 var (
 	Nil   = nilType(0)
 	False = boolType(0)
@@ -35,41 +67,134 @@ func (v Values) Count() int {
 }
 
 func (v Values) Get(index int) Value {
-	if index > len(v) {
+	if index < 0 || index >= len(v) {
 		return Nil
 	}
 	return v[index]
 }
 
+func valueFromInternal(v value.Value) Value {
+	if v == nil {
+		return Nil
+	}
+
+	switch v.Type() {
+	case value.TypeBoolean:
+		if !v.(value.Boolean) {
+			return False
+		}
+		return True
+	case value.TypeNumber:
+		if i, ok := v.(value.Integer); ok {
+			return Integer(i)
+		}
+		return Number(v.(value.Number))
+	case value.TypeString:
+		return String(v.(value.String))
+	case value.TypeFunction:
+		return Function{v.(*value.Function)}
+	case value.TypeUserdata:
+		return Userdata{v}
+	case value.TypeThread:
+		return Thread{v}
+	case value.TypeTable:
+		return Table{v.(*value.Table)}
+	}
+	return Nil
+}
+
 func valuesFromInternal(vs ...value.Value) Values {
 	var vals Values
+	for _, v := range vs {
+		vals = append(vals, valueFromInternal(v))
+	}
+	return vals
+}
+
+func valueToInternal(v Value) (value.Value, error) {
+	switch v := v.(type) {
+	case nil, nilType:
+		return value.Nil, nil
+	case boolType:
+		return value.Boolean(v == True), nil
+	case Number:
+		return value.NewNumber(float64(v)), nil
+	case Integer:
+		return value.NewInteger(int64(v)), nil
+	case String:
+		return value.NewString(string(v)), nil
+	case Function:
+		if v.fn == nil {
+			return nil, fmt.Errorf("uninitialized function")
+		}
+		return v.fn, nil
+	case Userdata:
+		if v.v == nil {
+			return nil, fmt.Errorf("uninitialized userdata")
+		}
+		return v.v, nil
+	case Thread:
+		if v.v == nil {
+			return nil, fmt.Errorf("uninitialized thread")
+		}
+		return v.v, nil
+	case Table:
+		if v.table == nil {
+			return nil, fmt.Errorf("uninitialized table, use NewTable to create a table")
+		}
+		return v.table, nil
+	}
+	return nil, fmt.Errorf("unsupported value of type %T", v)
+}
 
+func valuesToInternal(vs ...Value) ([]value.Value, error) {
+	var vals []value.Value
 	for _, v := range vs {
-		var val Value
-		switch v.Type() {
-		case value.TypeNil:
-			val = Nil
-		case value.TypeBoolean:
-			if !v.(value.Boolean) {
-				val = False
-			} else {
-				val = True
-			}
-		case value.TypeNumber:
-			val = Number(v.(value.Number))
-		case value.TypeString:
-			val = String(v.(value.String))
-		case value.TypeFunction:
-			fallthrough
-		case value.TypeUserdata:
-			fallthrough
-		case value.TypeThread:
-			fallthrough
-		case value.TypeTable:
-			panic("unsupported")
+		val, err := valueToInternal(v)
+		if err != nil {
+			return nil, err
 		}
 		vals = append(vals, val)
 	}
+	return vals, nil
+}
 
-	return vals
+// toNumberValue converts the given value to a number, if it is a number or a
+// string that can be converted to a number. The result is either an Integer
+// or a Number.
+func toNumberValue(v Value) (Value, bool) {
+	switch val := v.(type) {
+	case Number, Integer:
+		return val, true
+	case String:
+		if n, ok := value.ParseNumber(string(val)); ok {
+			return valueFromInternal(n), true
+		}
+	}
+	return nil, false
+}
+
+// toNumber converts the given value to a float, if it is a number or a
+// string that can be converted to a number.
+func toNumber(v Value) (float64, bool) {
+	switch n, _ := toNumberValue(v); n := n.(type) {
+	case Number:
+		return float64(n), true
+	case Integer:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toInteger converts the given value to an integer, if it is a number or a
+// string that can be converted to a number, and has an exact integer
+// representation.
+func toInteger(v Value) (int64, bool) {
+	switch n, _ := toNumberValue(v); n := n.(type) {
+	case Number:
+		return value.FloatToInteger(float64(n))
+	case Integer:
+		return int64(n), true
+	}
+	return 0, false
 }
@@ -0,0 +1,149 @@
+package lua
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+	results, err := e.EvalString(`return {1, 2, x = "y"}`)
+	assert.NoError(err)
+	assert.Len(results, 1)
+
+	tbl, ok := results[0].(Table)
+	assert.True(ok)
+	assert.Equal(Integer(1), tbl.Get(Integer(1)))
+	assert.Equal(String("y"), tbl.Get(String("x")))
+	assert.Equal(Nil, tbl.Get(String("z")))
+
+	count := 0
+	tbl.Range(func(key, val Value) bool {
+		count++
+		return true
+	})
+	assert.Equal(3, count)
+
+	assert.NoError(tbl.Set(String("z"), True))
+	assert.Error(tbl.Set(Nil, True))
+	assert.Error(tbl.Set(Number(math.NaN()), True))
+	assert.Equal(Integer(2), tbl.Get(Number(2)))
+	assert.NoError(tbl.Set(Number(3), Integer(3)))
+	assert.Equal(Integer(3), tbl.Get(Integer(3)))
+	assert.Equal(3, tbl.Len())
+
+	e.Register("get", func(args Values) (Values, error) {
+		return Values{tbl}, nil
+	})
+	results, err = e.EvalString(`local t = get()
+t.w = 5
+return t.z, t.x`)
+	assert.NoError(err)
+	assert.Equal(Values{True, String("y")}, results)
+	assert.Equal(Integer(5), tbl.Get(String("w")))
+
+	_, ok = tbl.Metatable()
+	assert.False(ok)
+	mt := NewTable()
+	assert.NoError(mt.Set(String("__index"), NewTable()))
+	tbl.SetMetatable(mt)
+	got, ok := tbl.Metatable()
+	assert.True(ok)
+	assert.Equal(mt, got)
+	tbl.SetMetatable(Table{})
+	_, ok = tbl.Metatable()
+	assert.False(ok)
+}
+
+func TestEngine_Call(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+	results, err := e.EvalString(`local count = 0
+return function(n)
+	count = count + n
+	return count
+end`)
+	assert.NoError(err)
+	fn, ok := results[0].(Function)
+	assert.True(ok)
+
+	results, err = e.Call(fn, Number(2))
+	assert.NoError(err)
+	assert.Equal(Values{Number(2)}, results)
+	results, err = e.Call(fn, Number(3))
+	assert.NoError(err)
+	assert.Equal(Values{Number(5)}, results)
+
+	results, err = e.EvalString(`return function() error({code = 42}) end`)
+	assert.NoError(err)
+	_, err = e.Call(results[0].(Function))
+	assert.IsType(Error{}, err)
+	luaErr := err.(Error)
+	assert.Equal("(error object is a table value)", luaErr.Message)
+	assert.Equal(Integer(42), luaErr.Value.(Table).Get(String("code")))
+
+	double := e.NewFunction("double", func(args Values) (Values, error) {
+		n, err := args.CheckNumber(1)
+		if err != nil {
+			return nil, err
+		}
+		return Values{n * 2}, nil
+	})
+	results, err = e.Call(double, Number(21))
+	assert.NoError(err)
+	assert.Equal(Values{Number(42)}, results)
+
+	e.Register("apply", func(args Values) (Values, error) {
+		fn, err := args.CheckFunction(1)
+		if err != nil {
+			return nil, err
+		}
+		return e.Call(fn, args[1:]...)
+	})
+	e.Register("getDouble", func(args Values) (Values, error) {
+		return Values{double}, nil
+	})
+	results, err = e.EvalString(`return apply(getDouble(), 4), apply(function(a, b) return a .. b end, "a", "b")`)
+	assert.NoError(err)
+	assert.Equal(Values{Number(8), String("ab")}, results)
+}
+
+func TestThread(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+	results, err := e.EvalString(`return coroutine.create(function() return 1 end)`)
+	assert.NoError(err)
+	co, ok := results[0].(Thread)
+	assert.True(ok)
+
+	e.Register("get", func(args Values) (Values, error) {
+		return Values{co}, nil
+	})
+	results, err = e.EvalString(`return coroutine.resume(get())`)
+	assert.NoError(err)
+	assert.Equal(Values{True, Integer(1)}, results)
+}
+
+func TestEngine_Call_PropagatesErrorValue(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine()
+	e.Register("apply", func(args Values) (Values, error) {
+		fn, err := args.CheckFunction(1)
+		if err != nil {
+			return nil, err
+		}
+		return e.Call(fn)
+	})
+	results, err := e.EvalString(`return pcall(apply, function() error({code = 42}) end)`)
+	assert.NoError(err)
+	assert.Len(results, 2)
+	assert.Equal(False, results[0])
+	assert.Equal(Integer(42), results[1].(Table).Get(String("code")))
+}